@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/mcp"
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// loadOCIConfig builds the server-wide registry configuration from environment variables:
+//   - OCI_DEFAULT_REGISTRY: registry used for references without one (default: Docker Hub)
+//   - OCI_REGISTRY_ALIASES: comma-separated prefix=replacement rewrite rules
+//   - OCI_REGISTRY_MIRRORS: comma-separated upstream=endpoint mirrors, tried before the upstream and
+//     reached with the credentials the Docker config has for them
+//   - OCI_RETRY_MAX_ATTEMPTS: attempts per registry request, including the first (1 disables retries)
+//   - OCI_RETRY_MAX_WAIT: longest Retry-After waited for before retrying (default: 30s)
+//   - OCI_INSECURE_REGISTRIES: comma-separated registries reached over plain HTTP
+//   - OCI_CERTS_DIR: per-registry CA bundles and client certificates, laid out like /etc/docker/certs.d
+//   - OCI_TLS_SKIP_VERIFY: comma-separated registries whose TLS certificates are not verified
+//   - OCI_CACHE_*: content cache settings, see loadContentCache
+//   - OCI_TAG_CACHE_TTL: how long tag resolutions and tag lists are reused (default: 30s, 0 disables)
+func loadOCIConfig() (*oci.Config, error) {
+	cfg := &oci.Config{
+		DefaultRegistry: strings.TrimSpace(os.Getenv("OCI_DEFAULT_REGISTRY")),
+	}
+
+	aliases, err := oci.ParseAliases(os.Getenv("OCI_REGISTRY_ALIASES"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing OCI_REGISTRY_ALIASES: %w", err)
+	}
+	cfg.Aliases = aliases
+
+	mirrors, err := oci.ParseMirrors(os.Getenv("OCI_REGISTRY_MIRRORS"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing OCI_REGISTRY_MIRRORS: %w", err)
+	}
+	cfg.Mirrors = mirrors
+	// Mirrors are reached with the server's own credentials for them, never
+	// with the caller's credentials for the upstream.
+	cfg.MirrorKeychain = authn.DefaultKeychain
+
+	if attempts := strings.TrimSpace(os.Getenv("OCI_RETRY_MAX_ATTEMPTS")); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid OCI_RETRY_MAX_ATTEMPTS %q: must be a positive number", attempts)
+		}
+		cfg.Retry.MaxAttempts = n
+	}
+	if wait := strings.TrimSpace(os.Getenv("OCI_RETRY_MAX_WAIT")); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid OCI_RETRY_MAX_WAIT %q: must be a positive duration such as 30s", wait)
+		}
+		cfg.Retry.MaxWait = d
+	}
+
+	cfg.InsecureRegistries = splitList(os.Getenv("OCI_INSECURE_REGISTRIES"))
+
+	var tlsSettings []oci.RegistryTLS
+	if dir := strings.TrimSpace(os.Getenv("OCI_CERTS_DIR")); dir != "" {
+		tlsSettings, err = oci.LoadCertsDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("loading OCI_CERTS_DIR: %w", err)
+		}
+	}
+	for _, host := range splitList(os.Getenv("OCI_TLS_SKIP_VERIFY")) {
+		tlsSettings = append(tlsSettings, oci.RegistryTLS{Host: host, InsecureSkipVerify: true})
+	}
+	if len(tlsSettings) > 0 {
+		cfg.Transport, err = oci.NewTLSTransport(tlsSettings)
+		if err != nil {
+			return nil, fmt.Errorf("configuring registry TLS: %w", err)
+		}
+	}
+
+	cfg.ContentCache, err = loadContentCache()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.TagCache, err = loadTagCache()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadContentCache builds the cache of manifests and blobs fetched by digest
+// from environment variables, with sizes in bytes or with a KiB, MiB or GiB suffix:
+//   - OCI_CACHE_MAX_BYTES: in-memory cache size (default: 64MiB, 0 disables the cache)
+//   - OCI_CACHE_MAX_ENTRY_BYTES: largest manifest or blob that is cached (default: 8MiB)
+//   - OCI_CACHE_DIR: directory persisting cached content across restarts (default: memory only)
+//   - OCI_CACHE_MAX_DISK_BYTES: on-disk cache size (default: 1GiB)
+func loadContentCache() (*oci.ContentCache, error) {
+	opts := oci.ContentCacheOptions{Dir: strings.TrimSpace(os.Getenv("OCI_CACHE_DIR"))}
+
+	for _, setting := range []struct {
+		env    string
+		target *int64
+	}{
+		{"OCI_CACHE_MAX_BYTES", &opts.MaxBytes},
+		{"OCI_CACHE_MAX_ENTRY_BYTES", &opts.MaxEntryBytes},
+		{"OCI_CACHE_MAX_DISK_BYTES", &opts.MaxDiskBytes},
+	} {
+		value := strings.TrimSpace(os.Getenv(setting.env))
+		if value == "" {
+			continue
+		}
+		n, err := parseByteSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", setting.env, value, err)
+		}
+		if n == 0 && setting.env == "OCI_CACHE_MAX_BYTES" {
+			return nil, nil
+		}
+		*setting.target = n
+	}
+
+	cache, err := oci.NewContentCache(opts)
+	if err != nil {
+		return nil, fmt.Errorf("configuring content cache: %w", err)
+	}
+	return cache, nil
+}
+
+// loadTagCache builds the cache of tag resolutions and tag lists, whose TTL is
+// read from OCI_TAG_CACHE_TTL.
+func loadTagCache() (*oci.TagCache, error) {
+	value := strings.TrimSpace(os.Getenv("OCI_TAG_CACHE_TTL"))
+	if value == "" {
+		return oci.NewTagCache(0), nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return nil, fmt.Errorf("invalid OCI_TAG_CACHE_TTL %q: must be a duration such as 30s", value)
+	}
+	if ttl == 0 {
+		return nil, nil
+	}
+	return oci.NewTagCache(ttl), nil
+}
+
+// byteSizeSuffixes are the units accepted by parseByteSize.
+var byteSizeSuffixes = []struct {
+	suffix string
+	scale  int64
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"B", 1},
+}
+
+// parseByteSize parses a non-negative size such as 512, 64MiB or 1GiB.
+func parseByteSize(value string) (int64, error) {
+	scale := int64(1)
+	for _, unit := range byteSizeSuffixes {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, scale = strings.TrimSpace(number), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/scale {
+		return 0, errors.New("must be a size in bytes such as 512, 64MiB or 1GiB")
+	}
+	return n * scale, nil
+}
+
+// loadLocalRoots returns the directories whose OCI image layouts and archives
+// can be read with oci-layout:// and oci-archive:// references, from the
+// comma-separated OCI_LOCAL_ROOTS environment variable. Without any, local
+// references are rejected.
+func loadLocalRoots() []string {
+	return splitList(os.Getenv("OCI_LOCAL_ROOTS"))
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadRegisterOptions selects the tools the server registers from environment variables:
+//   - MCP_TOOLS: comma-separated tools to register (default: all tools)
+//   - MCP_EXCLUDE_TOOLS: comma-separated tools not to register
+//   - MCP_TOOL_PREFIX: prefix added to the registered tool names
+func loadRegisterOptions() mcp.RegisterOptions {
+	return mcp.RegisterOptions{
+		Include: splitList(os.Getenv("MCP_TOOLS")),
+		Exclude: splitList(os.Getenv("MCP_EXCLUDE_TOOLS")),
+		Prefix:  strings.TrimSpace(os.Getenv("MCP_TOOL_PREFIX")),
+	}
+}
+
+// loadTimeoutConfig builds the tool call timeouts from environment variables:
+//   - MCP_TIMEOUT: timeout for registry operations per tool call (default: 30s)
+//   - MCP_MAX_TIMEOUT: upper bound for the timeout_seconds tool argument (default: 5m)
+//   - MCP_TOOL_TIMEOUTS: comma-separated tool=duration overrides of MCP_TIMEOUT
+//   - MCP_REGISTRY_TIMEOUTS: comma-separated registry=duration timeouts for slow registries
+func loadTimeoutConfig() (mcp.TimeoutConfig, error) {
+	var cfg mcp.TimeoutConfig
+
+	for _, setting := range []struct {
+		env    string
+		target *time.Duration
+	}{
+		{"MCP_TIMEOUT", &cfg.Default},
+		{"MCP_MAX_TIMEOUT", &cfg.Max},
+	} {
+		value := strings.TrimSpace(os.Getenv(setting.env))
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid %s %q: must be a positive duration such as 90s", setting.env, value)
+		}
+		*setting.target = d
+	}
+
+	tools, err := mcp.ParseTimeouts(os.Getenv("MCP_TOOL_TIMEOUTS"))
+	if err != nil {
+		return cfg, fmt.Errorf("parsing MCP_TOOL_TIMEOUTS: %w", err)
+	}
+	cfg.Tools = tools
+
+	registries, err := mcp.ParseTimeouts(os.Getenv("MCP_REGISTRY_TIMEOUTS"))
+	if err != nil {
+		return cfg, fmt.Errorf("parsing MCP_REGISTRY_TIMEOUTS: %w", err)
+	}
+	cfg.Registries = registries
+
+	return cfg, nil
+}
+
+// loadWatchOptions builds the polling of subscribed tag resources from environment variables:
+//   - MCP_WATCH_INTERVAL: how often subscribed tags are polled (default: 1m)
+//   - MCP_WATCH_MAX_BACKOFF: longest delay between polls of a failing tag (default: 15m)
+//   - MCP_WATCH_REGISTRY_INTERVALS: comma-separated registry=duration overrides of MCP_WATCH_INTERVAL
+//   - MCP_WATCH_MAX_SUBSCRIPTIONS: most tag subscriptions of all clients together (default: 1024)
+func loadWatchOptions() (mcp.WatchOptions, error) {
+	var opts mcp.WatchOptions
+
+	for _, setting := range []struct {
+		env    string
+		target *time.Duration
+	}{
+		{"MCP_WATCH_INTERVAL", &opts.Interval},
+		{"MCP_WATCH_MAX_BACKOFF", &opts.MaxBackoff},
+	} {
+		value := strings.TrimSpace(os.Getenv(setting.env))
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return opts, fmt.Errorf("invalid %s %q: must be a positive duration such as 5m", setting.env, value)
+		}
+		*setting.target = d
+	}
+
+	if limit := strings.TrimSpace(os.Getenv("MCP_WATCH_MAX_SUBSCRIPTIONS")); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return opts, fmt.Errorf("invalid MCP_WATCH_MAX_SUBSCRIPTIONS %q: must be a positive number", limit)
+		}
+		opts.MaxWatches = n
+	}
+
+	registries, err := mcp.ParseTimeouts(os.Getenv("MCP_WATCH_REGISTRY_INTERVALS"))
+	if err != nil {
+		return opts, fmt.Errorf("parsing MCP_WATCH_REGISTRY_INTERVALS: %w", err)
+	}
+	opts.Registries = registries
+
+	return opts, nil
+}
+
+// loadWebhookHosts parses MCP_WEBHOOK_REGISTRY_HOSTS, a comma-separated list of
+// host=registry pairs mapping the registry hosts named by webhooks to the
+// registries clients reference, such as an alias.
+func loadWebhookHosts() (map[string]string, error) {
+	hosts := map[string]string{}
+	for _, entry := range strings.Split(os.Getenv("MCP_WEBHOOK_REGISTRY_HOSTS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, registry, ok := strings.Cut(entry, "=")
+		host, registry = strings.TrimSpace(host), strings.TrimSpace(registry)
+		if !ok || host == "" || registry == "" {
+			return nil, fmt.Errorf("invalid MCP_WEBHOOK_REGISTRY_HOSTS entry %q: expected host=registry", entry)
+		}
+		reg, err := name.NewRegistry(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MCP_WEBHOOK_REGISTRY_HOSTS entry %q: %w", entry, err)
+		}
+		hosts[reg.RegistryStr()] = registry
+	}
+	return hosts, nil
+}
+
+// loadWebhookPath returns the path registry webhooks are received on from
+// MCP_WEBHOOK_PATH (default: /webhook).
+func loadWebhookPath() (string, error) {
+	path := strings.TrimSpace(os.Getenv("MCP_WEBHOOK_PATH"))
+	if path == "" {
+		return "/webhook", nil
+	}
+	if !strings.HasPrefix(path, "/") || path == mcpEndpointPath {
+		return "", fmt.Errorf("invalid MCP_WEBHOOK_PATH %q: must be an absolute path other than %s", path, mcpEndpointPath)
+	}
+	return path, nil
+}
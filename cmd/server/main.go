@@ -3,8 +3,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -14,7 +16,7 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/authn"
 	mcpserver "github.com/mark3labs/mcp-go/server"
 
 	"github.com/StacklokLabs/ocireg-mcp/pkg/mcp"
@@ -25,6 +27,10 @@ const (
 	// Transport types
 	transportSSE            = "sse"
 	transportStreamableHTTP = "streamable-http"
+	transportStdio          = "stdio"
+
+	// mcpEndpointPath is the path of the streamable HTTP transport
+	mcpEndpointPath = "/mcp"
 )
 
 var (
@@ -50,11 +56,17 @@ func setupContextWithGracefulShutdown() (context.Context, context.CancelFunc) {
 	return ctx, cancel
 }
 
+// newClientFactory returns a client factory that creates per-request clients
+// sharing the given server-wide registry configuration.
+func newClientFactory(cfg *oci.Config) mcp.ClientFactory {
+	return func(headers http.Header) oci.Backend {
+		return oci.NewBackend(createOCIClientFromHeaders(cfg, headers))
+	}
+}
+
 // createOCIClientFromHeaders creates an OCI client using authentication from HTTP headers
 // Priority: Authorization header > OCI_TOKEN env > OCI_USERNAME/PASSWORD env > default keychain
-func createOCIClientFromHeaders(headers http.Header) *oci.Client {
-	var ociClientOptions []remote.Option
-
+func createOCIClientFromHeaders(cfg *oci.Config, headers http.Header) *oci.Client {
 	// Priority 1: Check for bearer token from HTTP Authorization header (highest priority)
 	authHeader := headers.Get("Authorization")
 	if authHeader != "" {
@@ -62,11 +74,18 @@ func createOCIClientFromHeaders(headers http.Header) *oci.Client {
 		if strings.HasPrefix(authHeader, bearerPrefix) {
 			token := strings.TrimPrefix(authHeader, bearerPrefix)
 			log.Println("Authentication: Using bearer token from Authorization header")
-			ociClientOptions = append(ociClientOptions, oci.WithBearerToken(token))
-			return oci.NewClient(ociClientOptions...)
+			return oci.NewClientWithConfig(cfg).WithAuth(&authn.Bearer{Token: token})
 		}
 	}
 
+	return createOCIClientFromEnv(cfg)
+}
+
+// createOCIClientFromEnv creates an OCI client using authentication from the environment
+// Priority: OCI_TOKEN env > OCI_USERNAME/PASSWORD env > default keychain
+func createOCIClientFromEnv(cfg *oci.Config) *oci.Client {
+	client := oci.NewClientWithConfig(cfg)
+
 	// Priority 2: Check for authentication from environment variables
 	token := os.Getenv("OCI_TOKEN")
 	username := os.Getenv("OCI_USERNAME")
@@ -75,49 +94,153 @@ func createOCIClientFromHeaders(headers http.Header) *oci.Client {
 	switch {
 	case token != "":
 		log.Println("Authentication: Using bearer token from OCI_TOKEN environment variable")
-		ociClientOptions = append(ociClientOptions, oci.WithBearerToken(token))
+		return client.WithAuth(&authn.Bearer{Token: token})
 	case username != "" && password != "":
 		log.Println("Authentication: Using username/password from environment variables")
-		ociClientOptions = append(ociClientOptions, oci.WithBasicAuth(username, password))
+		return client.WithAuth(&authn.Basic{Username: username, Password: password})
 	default:
 		// Priority 3: If no explicit credentials, use the default keychain
 		// This will use credentials from the Docker config file
 		log.Println("Authentication: Using default keychain (Docker config)")
-		ociClientOptions = append(ociClientOptions, oci.WithDefaultKeychain())
+		return client.WithKeychain(authn.DefaultKeychain)
 	}
+}
 
-	return oci.NewClient(ociClientOptions...)
+// logOCIConfig logs the registry configuration that differs from the defaults
+func logOCIConfig(cfg *oci.Config) {
+	if cfg.DefaultRegistry != "" {
+		log.Printf("Using default registry %s", cfg.DefaultRegistry)
+	}
+	if len(cfg.InsecureRegistries) > 0 {
+		log.Printf("Using plain HTTP for registries %s", strings.Join(cfg.InsecureRegistries, ", "))
+	}
+	if skipVerify := os.Getenv("OCI_TLS_SKIP_VERIFY"); skipVerify != "" {
+		log.Printf("WARNING: TLS certificate verification disabled for registries %s", skipVerify)
+	}
+	if dir := os.Getenv("OCI_CACHE_DIR"); dir != "" && cfg.ContentCache != nil {
+		log.Printf("Caching registry content on disk in %s", dir)
+	}
 }
 
-// setupServer creates and configures the MCP server with tools
-func setupServer(serverName, serverVersion string) *mcpserver.MCPServer {
-	// Create the tool provider with a factory that creates clients per-request
-	toolProvider := mcp.NewToolProviderWithFactory(createOCIClientFromHeaders)
+// setupServer creates and configures the MCP server with tools. Local
+// references are served from local, or rejected when it is nil. The returned
+// watcher serves resource subscriptions; it is nil for the SSE transport,
+// which does not support them.
+func setupServer(
+	serverName, serverVersion, transport string, cfg *oci.Config, local *oci.LocalStore, timeouts mcp.TimeoutConfig,
+) (*mcpserver.MCPServer, *mcp.TagWatcher, error) {
+	if local == nil {
+		local = oci.NewLocalStore()
+	}
+	providerOptions := []mcp.ToolProviderOption{
+		mcp.WithTimeouts(timeouts),
+		mcp.WithBackendDecorator(func(b oci.Backend) oci.Backend { return oci.WithLocalStore(b, local) }),
+	}
+
+	// Pagination cursors are signed; replicas behind a load balancer must share the key
+	if secret := os.Getenv("MCP_CURSOR_SECRET"); secret != "" {
+		providerOptions = append(providerOptions, mcp.WithCursorKey([]byte(secret)))
+	}
+
+	// Resources larger than the limit are rejected rather than truncated
+	if limit := strings.TrimSpace(os.Getenv("MCP_RESOURCE_MAX_BYTES")); limit != "" {
+		n, err := parseByteSize(limit)
+		if err != nil || n == 0 {
+			return nil, nil, fmt.Errorf("invalid MCP_RESOURCE_MAX_BYTES %q: must be a positive size", limit)
+		}
+		providerOptions = append(providerOptions, mcp.WithResourceMaxBytes(n))
+	}
+
+	var toolProvider *mcp.ToolProvider
+	if transport == transportStdio {
+		// There are no HTTP headers over stdio, so a single client authenticates from the environment
+		toolProvider = mcp.NewToolProvider(oci.NewBackend(createOCIClientFromEnv(cfg)), providerOptions...)
+	} else {
+		// Create the tool provider with a factory that creates clients per-request;
+		// names are parsed with the shared config without creating a client
+		providerOptions = append(providerOptions, mcp.WithNameResolver(cfg))
+		toolProvider = mcp.NewToolProviderWithFactory(newClientFactory(cfg), providerOptions...)
+	}
 
 	// Create the MCP server with protocol-level pagination for tools/list responses
-	server := mcpserver.NewMCPServer(serverName, serverVersion,
-		mcpserver.WithPaginationLimit(100),
-	)
-
-	// Add the tools to the server
-	for _, tool := range toolProvider.GetTools() {
-		switch tool.Name {
-		case mcp.GetImageInfoToolName:
-			server.AddTool(tool, toolProvider.GetImageInfo)
-		case mcp.ListTagsToolName:
-			server.AddTool(tool, toolProvider.ListTags)
-		case mcp.GetImageManifestToolName:
-			server.AddTool(tool, toolProvider.GetImageManifest)
-		case mcp.GetImageConfigToolName:
-			server.AddTool(tool, toolProvider.GetImageConfig)
-		case mcp.ListReferrersToolName:
-			server.AddTool(tool, toolProvider.ListReferrers)
-		case mcp.GetReferrerContentToolName:
-			server.AddTool(tool, toolProvider.GetReferrerContent)
+	serverOptions := []mcpserver.ServerOption{mcpserver.WithPaginationLimit(100)}
+
+	// Subscription requests are intercepted before the server, which SSE requests bypass
+	subscribe := transport != transportSSE
+	var watchOptions mcp.WatchOptions
+	hooks := &mcpserver.Hooks{}
+	if subscribe {
+		var err error
+		if watchOptions, err = loadWatchOptions(); err != nil {
+			return nil, nil, err
 		}
+		watchOptions.OnError = func(err error) { log.Printf("Resource subscriptions: %v", err) }
+		serverOptions = append(serverOptions, mcpserver.WithResourceCapabilities(true, false), mcpserver.WithHooks(hooks))
+	}
+	server := mcpserver.NewMCPServer(serverName, serverVersion, serverOptions...)
+
+	// Add the selected tools to the server
+	if err := toolProvider.Register(server, loadRegisterOptions()); err != nil {
+		return nil, nil, fmt.Errorf("registering tools: %w", err)
+	}
+
+	if !subscribe {
+		return server, nil, nil
+	}
+	watcher := mcp.NewTagWatcher(toolProvider, server, watchOptions)
+	watcher.AddHooks(hooks)
+	return server, watcher, nil
+}
+
+// newStreamableHTTPServer creates the streamable HTTP transport, serving MCP on
+// /mcp with resource subscriptions answered by watcher, and registry webhooks
+// on webhookPath when webhook is not nil.
+func newStreamableHTTPServer(
+	mcpServer *mcpserver.MCPServer, watcher *mcp.TagWatcher, webhookPath string, webhook http.Handler,
+) transportServer {
+	httpServer := &http.Server{ReadHeaderTimeout: 30 * time.Second}
+	streamable := mcpserver.NewStreamableHTTPServer(mcpServer, mcpserver.WithStreamableHTTPServer(httpServer))
+
+	mux := http.NewServeMux()
+	mux.Handle(mcpEndpointPath, watcher.Middleware(streamable))
+	if webhook != nil {
+		mux.Handle(webhookPath, webhook)
 	}
+	httpServer.Handler = mux
+	return streamable
+}
 
-	return server
+// newWebhookHandler returns the path and receiver of registry webhooks,
+// invalidating the tag cache of cfg and notifying the subscribers of watcher.
+// Events name repositories as resolved with cfg, after the host mapping of
+// MCP_WEBHOOK_REGISTRY_HOSTS.
+// The handler is nil when MCP_WEBHOOK_SECRET is not set.
+func newWebhookHandler(transport string, cfg *oci.Config, watcher *mcp.TagWatcher) (string, http.Handler, error) {
+	path, err := loadWebhookPath()
+	if err != nil {
+		return "", nil, err
+	}
+	secret := os.Getenv("MCP_WEBHOOK_SECRET")
+	if secret == "" {
+		return path, nil, nil
+	}
+	if transport != transportStreamableHTTP {
+		log.Printf("WARNING: MCP_WEBHOOK_SECRET is ignored, registry webhooks require the streamable-http transport")
+		return path, nil, nil
+	}
+	hosts, err := loadWebhookHosts()
+	if err != nil {
+		return "", nil, err
+	}
+	log.Printf("Receiving registry webhooks on %s", path)
+	return path, mcp.NewWebhookHandler(mcp.WebhookOptions{
+		Secret:   secret,
+		TagCache: cfg.TagCache,
+		Watcher:  watcher,
+		OnEvents: func(events []oci.RegistryEvent) { log.Printf("Received %d registry events by webhook", len(events)) },
+		Hosts:    hosts,
+		Names:    cfg,
+	}), nil
 }
 
 // transportServer is an interface for MCP transport servers
@@ -142,6 +265,18 @@ func startServer(server transportServer, port int, serverName, serverVersion, tr
 	return serverErrCh
 }
 
+// serveStdio serves the MCP protocol over stdin and stdout until stdin is
+// closed or ctx is cancelled, with resource subscriptions answered by watcher.
+// Errors are logged to stderr.
+func serveStdio(
+	ctx context.Context, mcpServer *mcpserver.MCPServer, watcher *mcp.TagWatcher, stdin io.Reader, stdout io.Writer,
+) error {
+	stdin, stdout = watcher.FilterStdio(ctx, stdin, stdout)
+	stdio := mcpserver.NewStdioServer(mcpServer)
+	stdio.SetErrorLogger(log.New(os.Stderr, "", log.LstdFlags))
+	return stdio.Listen(ctx, stdin, stdout)
+}
+
 // shutdownServer attempts to gracefully shut down the server
 func shutdownServer(server transportServer) {
 	// Create a context with a timeout for the graceful shutdown
@@ -211,7 +346,7 @@ func getMCPServerPort() int {
 
 // getDefaultTransport returns the transport to use based on MCP_TRANSPORT environment variable.
 // If the environment variable is not set, returns "streamable-http".
-// Valid values are "sse" and "streamable-http".
+// Valid values are "sse", "streamable-http" and "stdio".
 func getDefaultTransport() string {
 	defaultTransport := transportStreamableHTTP
 
@@ -224,7 +359,7 @@ func getDefaultTransport() string {
 	transport := strings.ToLower(strings.TrimSpace(transportEnv))
 
 	// Validate the transport value
-	if transport != transportSSE && transport != transportStreamableHTTP {
+	if transport != transportSSE && transport != transportStreamableHTTP && transport != transportStdio {
 		log.Printf("Invalid MCP_TRANSPORT value, using default: %s", defaultTransport)
 		return defaultTransport
 	}
@@ -239,8 +374,12 @@ func main() {
 	// Parse command-line flags
 	port := flag.Int("port", envPort, "Port to listen on (must be between 0 and 65535)")
 	transport := flag.String("transport", getDefaultTransport(),
-		"Transport protocol: 'sse' or 'streamable-http'. Also via MCP_TRANSPORT env var")
+		"Transport protocol: 'sse', 'streamable-http' or 'stdio'. Also via MCP_TRANSPORT env var")
 	flag.Parse()
+	*transport = strings.ToLower(*transport)
+
+	// Over stdio, stdout carries the protocol stream; logs must only go to stderr
+	log.SetOutput(os.Stderr)
 
 	// Validate command-line port
 	if !validatePort(*port) {
@@ -256,20 +395,59 @@ func main() {
 	serverName := "ocireg-mcp"
 	serverVersion := version
 
+	// Load registry configuration shared by all requests
+	ociConfig, err := loadOCIConfig()
+	if err != nil {
+		log.Fatalf("Invalid registry configuration: %v", err)
+	}
+	logOCIConfig(ociConfig)
+
+	// Local image layouts and archives; extracted archives are removed on exit
+	localStore := oci.NewLocalStore(loadLocalRoots()...)
+	defer localStore.Close()
+
+	timeouts, err := loadTimeoutConfig()
+	if err != nil {
+		log.Fatalf("Invalid timeout configuration: %v", err)
+	}
+
 	// Setup the MCP server
-	mcpServer := setupServer(serverName, serverVersion)
+	mcpServer, watcher, err := setupServer(serverName, serverVersion, *transport, ociConfig, localStore, timeouts)
+	if err != nil {
+		log.Fatalf("Invalid tool configuration: %v", err)
+	}
+
+	// Registry webhooks are received next to the streamable HTTP endpoint
+	webhookPath, webhook, err := newWebhookHandler(*transport, ociConfig, watcher)
+	if err != nil {
+		log.Fatalf("Invalid webhook configuration: %v", err)
+	}
+
+	// Poll the tags of subscribed resources in the background
+	if watcher != nil {
+		go watcher.Run(ctx)
+	}
+
+	if *transport == transportStdio {
+		log.Printf("Starting %s v%s (stdio transport)", serverName, serverVersion)
+		err := serveStdio(ctx, mcpServer, watcher, os.Stdin, os.Stdout)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	}
 
 	// Create the appropriate transport server
 	var server transportServer
-	switch strings.ToLower(*transport) {
+	switch *transport {
 	case transportStreamableHTTP:
 		log.Println("Using streamable-http transport")
-		server = mcpserver.NewStreamableHTTPServer(mcpServer)
+		server = newStreamableHTTPServer(mcpServer, watcher, webhookPath, webhook)
 	case transportSSE:
 		log.Println("Using SSE transport")
 		server = mcpserver.NewSSEServer(mcpServer)
 	default:
-		log.Fatalf("Invalid transport: %s. Must be 'sse' or 'streamable-http'", *transport)
+		log.Fatalf("Invalid transport: %s. Must be 'sse', 'streamable-http' or 'stdio'", *transport)
 	}
 
 	// Start the server
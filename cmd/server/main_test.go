@@ -2,10 +2,20 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/mcp"
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
 )
 
 func TestGetMCPServerPort(t *testing.T) {
@@ -96,3 +106,378 @@ func TestGetMCPServerPort(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadOCIConfig(t *testing.T) {
+	t.Setenv("OCI_DEFAULT_REGISTRY", "registry.corp.example")
+	t.Setenv("OCI_REGISTRY_ALIASES", "internal/=registry.corp.example/")
+
+	cfg, err := loadOCIConfig()
+	if err != nil {
+		t.Fatalf("loadOCIConfig() error = %v", err)
+	}
+	if cfg.DefaultRegistry != "registry.corp.example" {
+		t.Errorf("DefaultRegistry = %q, want %q", cfg.DefaultRegistry, "registry.corp.example")
+	}
+	if len(cfg.Aliases) != 1 || cfg.Aliases[0].Prefix != "internal/" {
+		t.Errorf("Aliases = %+v, want one alias for internal/", cfg.Aliases)
+	}
+
+	t.Setenv("OCI_REGISTRY_MIRRORS", "docker.io=mirror.corp.example/dockerhub, ghcr.io=mirror.corp.example/ghcr")
+	cfg, err = loadOCIConfig()
+	if err != nil {
+		t.Fatalf("loadOCIConfig() error = %v", err)
+	}
+	if len(cfg.Mirrors) != 2 || cfg.Mirrors[0].Endpoint != "mirror.corp.example/dockerhub" {
+		t.Errorf("Mirrors = %+v, want two mirrors starting with mirror.corp.example/dockerhub", cfg.Mirrors)
+	}
+	if cfg.MirrorKeychain == nil {
+		t.Error("MirrorKeychain = nil, want the default keychain")
+	}
+
+	t.Setenv("OCI_REGISTRY_MIRRORS", "docker.io")
+	if _, err := loadOCIConfig(); err == nil {
+		t.Error("loadOCIConfig() expected error for invalid mirror")
+	}
+	t.Setenv("OCI_REGISTRY_MIRRORS", "")
+
+	t.Setenv("OCI_RETRY_MAX_ATTEMPTS", "2")
+	cfg, err = loadOCIConfig()
+	if err != nil {
+		t.Fatalf("loadOCIConfig() error = %v", err)
+	}
+	if cfg.Retry.MaxAttempts != 2 {
+		t.Errorf("Retry.MaxAttempts = %d, want 2", cfg.Retry.MaxAttempts)
+	}
+
+	t.Setenv("OCI_RETRY_MAX_WAIT", "1m")
+	cfg, err = loadOCIConfig()
+	if err != nil {
+		t.Fatalf("loadOCIConfig() error = %v", err)
+	}
+	if cfg.Retry.MaxWait != time.Minute {
+		t.Errorf("Retry.MaxWait = %v, want 1m", cfg.Retry.MaxWait)
+	}
+	t.Setenv("OCI_RETRY_MAX_WAIT", "soon")
+	if _, err := loadOCIConfig(); err == nil {
+		t.Error("loadOCIConfig() expected error for invalid retry wait")
+	}
+	t.Setenv("OCI_RETRY_MAX_WAIT", "")
+
+	t.Setenv("OCI_RETRY_MAX_ATTEMPTS", "0")
+	if _, err := loadOCIConfig(); err == nil {
+		t.Error("loadOCIConfig() expected error for non-positive retry attempts")
+	}
+	t.Setenv("OCI_RETRY_MAX_ATTEMPTS", "")
+
+	t.Setenv("OCI_INSECURE_REGISTRIES", "dev.corp.example:5000, ")
+	t.Setenv("OCI_TLS_SKIP_VERIFY", "harbor.corp.example")
+	cfg, err = loadOCIConfig()
+	if err != nil {
+		t.Fatalf("loadOCIConfig() error = %v", err)
+	}
+	if len(cfg.InsecureRegistries) != 1 || cfg.InsecureRegistries[0] != "dev.corp.example:5000" {
+		t.Errorf("InsecureRegistries = %v, want [dev.corp.example:5000]", cfg.InsecureRegistries)
+	}
+	if cfg.Transport == nil {
+		t.Error("Transport = nil, want a TLS transport for harbor.corp.example")
+	}
+
+	t.Setenv("OCI_LOCAL_ROOTS", "/srv/images, /mnt/airgap")
+	if roots := loadLocalRoots(); len(roots) != 2 || roots[1] != "/mnt/airgap" {
+		t.Errorf("loadLocalRoots() = %v, want [/srv/images /mnt/airgap]", roots)
+	}
+
+	t.Setenv("OCI_CERTS_DIR", t.TempDir()+"/missing")
+	if _, err := loadOCIConfig(); err == nil {
+		t.Error("loadOCIConfig() expected error for missing certs directory")
+	}
+	t.Setenv("OCI_CERTS_DIR", "")
+
+	t.Setenv("OCI_REGISTRY_ALIASES", "missing-replacement")
+	if _, err := loadOCIConfig(); err == nil {
+		t.Error("loadOCIConfig() expected error for invalid alias")
+	}
+}
+
+func TestLoadTimeoutConfig(t *testing.T) {
+	cfg, err := loadTimeoutConfig()
+	if err != nil {
+		t.Fatalf("loadTimeoutConfig() error = %v", err)
+	}
+	if cfg.Default != 0 || cfg.Max != 0 || len(cfg.Tools) != 0 || len(cfg.Registries) != 0 {
+		t.Errorf("loadTimeoutConfig() = %+v, want defaults", cfg)
+	}
+
+	t.Setenv("MCP_TIMEOUT", "45s")
+	t.Setenv("MCP_MAX_TIMEOUT", "10m")
+	t.Setenv("MCP_TOOL_TIMEOUTS", "list_tags=2m")
+	t.Setenv("MCP_REGISTRY_TIMEOUTS", "registry.corp.example=3m")
+	cfg, err = loadTimeoutConfig()
+	if err != nil {
+		t.Fatalf("loadTimeoutConfig() error = %v", err)
+	}
+	if cfg.Default != 45*time.Second || cfg.Max != 10*time.Minute {
+		t.Errorf("Default, Max = %v, %v, want 45s, 10m", cfg.Default, cfg.Max)
+	}
+	if cfg.Tools["list_tags"] != 2*time.Minute {
+		t.Errorf("Tools = %v, want list_tags=2m", cfg.Tools)
+	}
+	if cfg.Registries["registry.corp.example"] != 3*time.Minute {
+		t.Errorf("Registries = %v, want registry.corp.example=3m", cfg.Registries)
+	}
+
+	t.Setenv("MCP_TIMEOUT", "0s")
+	if _, err := loadTimeoutConfig(); err == nil {
+		t.Error("loadTimeoutConfig() expected error for non-positive timeout")
+	}
+	t.Setenv("MCP_TIMEOUT", "")
+
+	t.Setenv("MCP_TOOL_TIMEOUTS", "list_tags")
+	if _, err := loadTimeoutConfig(); err == nil {
+		t.Error("loadTimeoutConfig() expected error for invalid tool timeout")
+	}
+}
+
+func TestLoadContentCache(t *testing.T) {
+	cfg, err := loadOCIConfig()
+	if err != nil {
+		t.Fatalf("loadOCIConfig() error = %v", err)
+	}
+	if cfg.ContentCache == nil {
+		t.Error("ContentCache = nil, want an in-memory cache by default")
+	}
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv("OCI_CACHE_DIR", dir)
+	t.Setenv("OCI_CACHE_MAX_ENTRY_BYTES", "4MiB")
+	t.Setenv("OCI_CACHE_MAX_DISK_BYTES", "2GiB")
+	if cache, err := loadContentCache(); err != nil || cache == nil {
+		t.Fatalf("loadContentCache() = %v, %v, want a cache", cache, err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("cache directory not created: %v", err)
+	}
+
+	t.Setenv("OCI_CACHE_MAX_BYTES", "0")
+	if cache, err := loadContentCache(); err != nil || cache != nil {
+		t.Errorf("loadContentCache() = %v, %v, want caching disabled", cache, err)
+	}
+
+	t.Setenv("OCI_CACHE_MAX_BYTES", "lots")
+	if _, err := loadContentCache(); err == nil {
+		t.Error("loadContentCache() expected error for invalid size")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":   512,
+		"64MiB": 64 << 20,
+		"1 GiB": 1 << 30,
+		"16KiB": 16 << 10,
+		"100B":  100,
+		"0":     0,
+	}
+	for value, want := range tests {
+		if got, err := parseByteSize(value); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "-1", "1.5MiB", "64MB", "99999999999GiB"} {
+		if _, err := parseByteSize(value); err == nil {
+			t.Errorf("parseByteSize(%q) expected error", value)
+		}
+	}
+}
+
+func TestLoadTagCache(t *testing.T) {
+	if cache, err := loadTagCache(); err != nil || cache == nil {
+		t.Errorf("loadTagCache() = %v, %v, want a cache by default", cache, err)
+	}
+
+	t.Setenv("OCI_TAG_CACHE_TTL", "2m")
+	if cache, err := loadTagCache(); err != nil || cache == nil {
+		t.Errorf("loadTagCache() = %v, %v, want a cache", cache, err)
+	}
+
+	t.Setenv("OCI_TAG_CACHE_TTL", "0")
+	if cache, err := loadTagCache(); err != nil || cache != nil {
+		t.Errorf("loadTagCache() = %v, %v, want caching disabled", cache, err)
+	}
+
+	t.Setenv("OCI_TAG_CACHE_TTL", "soon")
+	if _, err := loadTagCache(); err == nil {
+		t.Error("loadTagCache() expected error for invalid TTL")
+	}
+}
+
+func TestLoadWatchOptions(t *testing.T) {
+	opts, err := loadWatchOptions()
+	if err != nil {
+		t.Fatalf("loadWatchOptions() error = %v", err)
+	}
+	if opts.Interval != 0 || opts.MaxBackoff != 0 || opts.MaxWatches != 0 || len(opts.Registries) != 0 {
+		t.Errorf("loadWatchOptions() = %+v, want defaults", opts)
+	}
+
+	t.Setenv("MCP_WATCH_INTERVAL", "30s")
+	t.Setenv("MCP_WATCH_MAX_BACKOFF", "1h")
+	t.Setenv("MCP_WATCH_REGISTRY_INTERVALS", "docker.io=5m")
+	t.Setenv("MCP_WATCH_MAX_SUBSCRIPTIONS", "100")
+	opts, err = loadWatchOptions()
+	if err != nil {
+		t.Fatalf("loadWatchOptions() error = %v", err)
+	}
+	if opts.Interval != 30*time.Second || opts.MaxBackoff != time.Hour {
+		t.Errorf("Interval, MaxBackoff = %v, %v, want 30s, 1h", opts.Interval, opts.MaxBackoff)
+	}
+	if opts.Registries["docker.io"] != 5*time.Minute {
+		t.Errorf("Registries = %v, want docker.io=5m", opts.Registries)
+	}
+	if opts.MaxWatches != 100 {
+		t.Errorf("MaxWatches = %d, want 100", opts.MaxWatches)
+	}
+
+	t.Setenv("MCP_WATCH_MAX_SUBSCRIPTIONS", "none")
+	if _, err := loadWatchOptions(); err == nil {
+		t.Error("loadWatchOptions() expected error for invalid subscription limit")
+	}
+	t.Setenv("MCP_WATCH_MAX_SUBSCRIPTIONS", "")
+
+	t.Setenv("MCP_WATCH_INTERVAL", "soon")
+	if _, err := loadWatchOptions(); err == nil {
+		t.Error("loadWatchOptions() expected error for invalid interval")
+	}
+}
+
+func TestNewWebhookHandler(t *testing.T) {
+	cfg := &oci.Config{TagCache: oci.NewTagCache(0)}
+	path, handler, err := newWebhookHandler(transportStreamableHTTP, cfg, nil)
+	if err != nil || path != "/webhook" || handler != nil {
+		t.Errorf("newWebhookHandler() = %q, %v, %v, want /webhook and no handler without a secret", path, handler, err)
+	}
+
+	t.Setenv("MCP_WEBHOOK_SECRET", "s3cret")
+	t.Setenv("MCP_WEBHOOK_PATH", "/hooks/registry")
+	path, handler, err = newWebhookHandler(transportStreamableHTTP, cfg, nil)
+	if err != nil || path != "/hooks/registry" || handler == nil {
+		t.Fatalf("newWebhookHandler() = %q, %v, %v, want a handler on /hooks/registry", path, handler, err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"events":[]}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("webhook status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	if _, handler, _ := newWebhookHandler(transportStdio, cfg, nil); handler != nil {
+		t.Error("newWebhookHandler() returned a handler for the stdio transport")
+	}
+
+	t.Setenv("MCP_WEBHOOK_REGISTRY_HOSTS", "registry.internal:5000")
+	if _, _, err := newWebhookHandler(transportStreamableHTTP, cfg, nil); err == nil {
+		t.Error("newWebhookHandler() expected error for an invalid registry host mapping")
+	}
+	t.Setenv("MCP_WEBHOOK_REGISTRY_HOSTS", "")
+
+	t.Setenv("MCP_WEBHOOK_PATH", "/mcp")
+	if _, _, err := newWebhookHandler(transportStreamableHTTP, cfg, nil); err == nil {
+		t.Error("newWebhookHandler() expected error for a path clashing with the MCP endpoint")
+	}
+}
+
+func TestLoadWebhookHosts(t *testing.T) {
+	t.Setenv("MCP_WEBHOOK_REGISTRY_HOSTS", " registry.internal:5000=registry.corp.example, docker.io=mirror ")
+	hosts, err := loadWebhookHosts()
+	if err != nil {
+		t.Fatalf("loadWebhookHosts() error = %v", err)
+	}
+	want := map[string]string{"registry.internal:5000": "registry.corp.example", "index.docker.io": "mirror"}
+	if !maps.Equal(hosts, want) {
+		t.Errorf("loadWebhookHosts() = %v, want %v", hosts, want)
+	}
+
+	for _, spec := range []string{"registry.internal", "=registry.corp.example", "UPPER CASE=registry.corp.example"} {
+		t.Setenv("MCP_WEBHOOK_REGISTRY_HOSTS", spec)
+		if _, err := loadWebhookHosts(); err == nil {
+			t.Errorf("loadWebhookHosts() expected error for %q", spec)
+		}
+	}
+}
+
+func TestSetupServer_ToolSelection(t *testing.T) {
+	t.Setenv("MCP_TOOLS", "get_image_info, list_tags,resolve_reference")
+	t.Setenv("MCP_EXCLUDE_TOOLS", "list_tags")
+	t.Setenv("MCP_TOOL_PREFIX", "oci_")
+
+	server, _, err := setupServer("test", "0.0.0", transportStreamableHTTP, &oci.Config{}, nil, mcp.TimeoutConfig{})
+	if err != nil {
+		t.Fatalf("setupServer() error = %v", err)
+	}
+	tools := server.ListTools()
+	if len(tools) != 2 || tools["oci_get_image_info"] == nil || tools["oci_resolve_reference"] == nil {
+		t.Errorf("registered tools = %v, want oci_get_image_info and oci_resolve_reference", slices.Collect(maps.Keys(tools)))
+	}
+
+	t.Setenv("MCP_EXCLUDE_TOOLS", "list_tag")
+	if _, _, err := setupServer("test", "0.0.0", transportStreamableHTTP, &oci.Config{}, nil, mcp.TimeoutConfig{}); err == nil {
+		t.Error("setupServer() expected error for unknown tool")
+	}
+	t.Setenv("MCP_EXCLUDE_TOOLS", "")
+
+	t.Setenv("MCP_RESOURCE_MAX_BYTES", "1MiB")
+	if _, _, err := setupServer("test", "0.0.0", transportStreamableHTTP, &oci.Config{}, nil, mcp.TimeoutConfig{}); err != nil {
+		t.Errorf("setupServer() error = %v", err)
+	}
+	t.Setenv("MCP_RESOURCE_MAX_BYTES", "0")
+	if _, _, err := setupServer("test", "0.0.0", transportStreamableHTTP, &oci.Config{}, nil, mcp.TimeoutConfig{}); err == nil {
+		t.Error("setupServer() expected error for a zero resource size limit")
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	t.Setenv("OCI_TOKEN", "token")
+	server, watcher, err := setupServer("test", "0.0.0", transportStdio, &oci.Config{}, nil, mcp.TimeoutConfig{})
+	if err != nil {
+		t.Fatalf("setupServer() error = %v", err)
+	}
+
+	stdin := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18",` +
+			`"capabilities":{},"clientInfo":{"name":"test","version":"0.0.0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"resources/subscribe","params":{"uri":"oci://ghcr.io/org/app@sha256:` +
+			strings.Repeat("a", 64) + `"}}`,
+	}, "\n") + "\n")
+	var stdout bytes.Buffer
+	if err := serveStdio(t.Context(), server, watcher, stdin, &stdout); err != nil {
+		t.Fatalf("serveStdio() error = %v", err)
+	}
+
+	// Every line written to stdout is a JSON-RPC message.
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("stdout has %d lines, want 3 responses: %s", len(lines), stdout.String())
+	}
+	for _, line := range lines {
+		var msg struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Result  json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.JSONRPC != "2.0" || msg.Result == nil {
+			t.Errorf("stdout line %q is not a JSON-RPC result", line)
+		}
+	}
+	if !strings.Contains(stdout.String(), `"name":"get_image_info"`) {
+		t.Errorf("tools/list response does not list get_image_info: %s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), `"subscribe":true`) {
+		t.Errorf("initialize response does not advertise resource subscriptions: %s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), `{"jsonrpc":"2.0","id":3,"result":{}}`) {
+		t.Errorf("resources/subscribe was not answered: %s", stdout.String())
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/mark3labs/mcp-go v0.48.0
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/mod v0.35.0
+	golang.org/x/sync v0.20.0
 )
 
 require (
@@ -31,7 +32,6 @@ require (
 	github.com/vbatts/tar-split v0.12.2 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	golang.org/x/sync v0.20.0 // indirect
 	golang.org/x/sys v0.43.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gotest.tools/v3 v3.5.2 // indirect
@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// newStructuredErrorResult creates an error tool result carrying an ErrorResult payload.
+func newStructuredErrorResult(payload ErrorResult) *mcp.CallToolResult {
+	result := mcp.NewToolResultStructured(payload, payload.Error)
+	result.IsError = true
+	return result
+}
+
+// newErrorPayload classifies err and builds the ErrorResult for a failed
+// operation described by message.
+func newErrorPayload(ctx context.Context, message string, err error) ErrorResult {
+	class := oci.ClassifyError(ctx, err)
+	return ErrorResult{
+		Error:             fmt.Sprintf("%s: %v", message, err),
+		Category:          string(class.Category),
+		Code:              class.Code,
+		StatusCode:        class.StatusCode,
+		Retryable:         class.Retryable,
+		RetryAfterSeconds: int(math.Ceil(class.RetryAfter.Seconds())),
+	}
+}
+
+// toolErrorResult builds a classified error result for a failed operation.
+func toolErrorResult(ctx context.Context, message string, err error) *mcp.CallToolResult {
+	return newStructuredErrorResult(newErrorPayload(ctx, message, err))
+}
+
+// invalidArgumentResult builds the error result for a request with missing or
+// invalid arguments.
+func invalidArgumentResult(message string) *mcp.CallToolResult {
+	return newStructuredErrorResult(ErrorResult{
+		Error:    message,
+		Category: string(oci.ErrorCategoryInvalidArgument),
+	})
+}
@@ -0,0 +1,242 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// Match kinds reported by the find_tags_by_digest tool.
+const (
+	tagMatchDirect     = "direct"
+	tagMatchIndexChild = "index-child"
+)
+
+// maxScannedTags bounds the number of tags a single find_tags_by_digest call
+// resolves. Tags past it, in listing order, are reported as skipped.
+const maxScannedTags = 1000
+
+// legacyCosignTagPattern matches tags written by the legacy cosign tag scheme,
+// which never point at images and are not scanned.
+var legacyCosignTagPattern = regexp.MustCompile(`^sha256-[a-f0-9]{64}\.(sig|att|sbom)$`)
+
+// parseDigestTarget determines the repository and digest to search for from
+// either image_ref or repository plus digest. Tag references are resolved to
+// their current digest. On failure it returns the error result to send.
+func parseDigestTarget(
+	ctx context.Context, client oci.Backend, req mcp.CallToolRequest,
+) (string, v1.Hash, *mcp.CallToolResult) {
+	imageRef := mcp.ParseString(req, "image_ref", "")
+	if imageRef != "" {
+		repo, digest, err := oci.ResolveDigest(ctx, client, imageRef)
+		if err != nil {
+			return "", v1.Hash{}, lookupErrorResult(ctx, "failed to resolve image_ref", err,
+				suggestAlternatives(client, imageRef))
+		}
+		return repo, digest, nil
+	}
+
+	repository := mcp.ParseString(req, "repository", "")
+	digestStr := mcp.ParseString(req, "digest", "")
+	if repository == "" || digestStr == "" {
+		return "", v1.Hash{}, invalidArgumentResult("image_ref, or repository and digest, are required")
+	}
+
+	digest, err := v1.NewHash(digestStr)
+	if err != nil {
+		return "", v1.Hash{}, invalidArgumentResult(fmt.Sprintf("invalid digest: %v", err))
+	}
+
+	repo, _, err := oci.RepositoryOf(client, repository)
+	if err != nil {
+		return "", v1.Hash{}, toolErrorResult(ctx, "invalid repository", err)
+	}
+
+	return repo, digest, nil
+}
+
+// tagScan searches the tags of a repository for a digest. Tags are resolved
+// through the backend, so repeated scans are served by its tag and content
+// caches; within a scan, each index is fetched once however many tags point
+// at it.
+type tagScan struct {
+	client     oci.ContentReader
+	repository string
+	digest     v1.Hash
+
+	mu      sync.Mutex
+	indexes map[v1.Hash]*indexFetch
+}
+
+// indexFetch is an index manifest fetched once for a scan.
+type indexFetch struct {
+	once  sync.Once
+	index *v1.IndexManifest
+	err   error
+}
+
+// index returns the index manifest with the given digest.
+func (s *tagScan) index(ctx context.Context, digest v1.Hash) (*v1.IndexManifest, error) {
+	s.mu.Lock()
+	fetch, ok := s.indexes[digest]
+	if !ok {
+		fetch = &indexFetch{}
+		s.indexes[digest] = fetch
+	}
+	s.mu.Unlock()
+
+	fetch.once.Do(func() {
+		var raw []byte
+		raw, _, fetch.err = s.client.GetManifest(ctx, s.repository+"@"+digest.String())
+		if fetch.err == nil {
+			fetch.index, fetch.err = v1.ParseIndexManifest(bytes.NewReader(raw))
+		}
+	})
+	return fetch.index, fetch.err
+}
+
+// match checks whether a tag resolves to, or indexes, the searched digest. It
+// returns nil when it does not.
+func (s *tagScan) match(ctx context.Context, tag string) (*DigestTagMatch, error) {
+	_, desc, err := s.client.HeadReference(ctx, s.repository+":"+tag)
+	if err != nil {
+		return nil, err
+	}
+
+	match := &DigestTagMatch{
+		Tag:       tag,
+		Digest:    desc.Digest.String(),
+		MediaType: string(desc.MediaType),
+		Match:     tagMatchDirect,
+	}
+	if desc.Digest == s.digest {
+		return match, nil
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, nil
+	}
+
+	idx, err := s.index(ctx, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range idx.Manifests {
+		if child.Digest == s.digest {
+			match.Match = tagMatchIndexChild
+			if child.Platform != nil {
+				match.Platform = child.Platform.String()
+			}
+			return match, nil
+		}
+	}
+	return nil, nil
+}
+
+// scanTags resolves up to maxScannedTags of tags, skipping legacy cosign tags,
+// and fills in the matches, scanned and failed tags of result. Tags that fail
+// once ctx is done are reported as unscanned rather than failed.
+func (s *tagScan) scanTags(ctx context.Context, tags []string, result *FindTagsByDigestResult) {
+	var candidates []string
+	for _, tag := range tags {
+		if !legacyCosignTagPattern.MatchString(tag) {
+			candidates = append(candidates, tag)
+		}
+	}
+	if len(candidates) > maxScannedTags {
+		result.SkippedTags = len(candidates) - maxScannedTags
+		candidates = candidates[:maxScannedTags]
+	}
+
+	matches := make([]*DigestTagMatch, len(candidates))
+	errs := make([]error, len(candidates))
+	forEachConcurrently(len(candidates), defaultConcurrency, func(i int) {
+		matches[i], errs[i] = s.match(ctx, candidates[i])
+	})
+
+	for i, tag := range candidates {
+		switch {
+		case errs[i] != nil && ctx.Err() != nil:
+			result.UnscannedTags = append(result.UnscannedTags, tag)
+		case errs[i] != nil:
+			result.FailedTags = append(result.FailedTags, tag)
+		case matches[i] != nil:
+			result.Tags = append(result.Tags, *matches[i])
+			result.ScannedTags++
+		default:
+			result.ScannedTags++
+		}
+	}
+}
+
+// listingContext bounds listing tags to half of the time left in ctx, so the
+// tags listed by then can still be scanned.
+func listingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, time.Now().Add(time.Until(deadline)/2))
+}
+
+// FindTagsByDigest handles the find_tags_by_digest tool.
+func (p *ToolProvider) FindTagsByDigest(
+	ctx context.Context, req mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	client := p.getClient(req)
+
+	registry := repositoryRegistry(client, mcp.ParseString(req, "repository", ""))
+	if imageRef := mcp.ParseString(req, "image_ref", ""); imageRef != "" {
+		registry = referenceRegistry(client, imageRef)
+	}
+	reqCtx, trace, cancel := p.callContext(ctx, req, registry)
+	defer cancel()
+
+	repository, digest, errResult := parseDigestTarget(reqCtx, client, req)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	// Scan the tags listed before a timeout rather than failing the call.
+	listCtx, cancelList := listingContext(reqCtx)
+	tags, err := client.ListTags(listCtx, repository)
+	cancelList()
+	partial := errors.Is(err, oci.ErrIncomplete)
+	if err != nil && !partial {
+		return lookupErrorResult(reqCtx, "failed to find tags", err, func(ctx context.Context) []string {
+			return client.SuggestRepositories(ctx, repository)
+		}), nil
+	}
+
+	result := FindTagsByDigestResult{
+		Repository: repository,
+		Digest:     digest.String(),
+		Tags:       []DigestTagMatch{},
+	}
+	scan := &tagScan{client: client, repository: repository, digest: digest, indexes: map[v1.Hash]*indexFetch{}}
+	scan.scanTags(reqCtx, tags, &result)
+	result.Partial = partial || len(result.UnscannedTags) > 0
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
+	}
+
+	note := partialNote(partial)
+	if len(result.UnscannedTags) > 0 {
+		note = fmt.Sprintf("; partial, timed out with %d tags unscanned", len(result.UnscannedTags))
+	}
+	fallback := fmt.Sprintf("Tags in %s resolving to %s (%d found, %d scanned, %d failed, %d skipped%s):\n\n```json\n%s\n```",
+		repository, digest, len(result.Tags), result.ScannedTags, len(result.FailedTags), result.SkippedTags,
+		note, string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
+}
@@ -0,0 +1,180 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+func TestFindTagsByDigest_MissingArguments(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"repository": "docker.io/library/alpine",
+	}
+
+	result, err := provider.FindTagsByDigest(t.Context(), req)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	assert.True(t, ok)
+	assert.Contains(t, textContent.Text, "repository and digest")
+}
+
+func TestFindTagsByDigest_ImageRef(t *testing.T) {
+	host := pushRandomImages(t, "app:v1", "app:v2")
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"image_ref": host + "/app:v2",
+	}
+
+	result, err := provider.FindTagsByDigest(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	found, ok := result.StructuredContent.(FindTagsByDigestResult)
+	require.True(t, ok)
+	assert.Equal(t, 2, found.ScannedTags)
+	require.Len(t, found.Tags, 1)
+	assert.Equal(t, "v2", found.Tags[0].Tag)
+	assert.Equal(t, tagMatchDirect, found.Tags[0].Match)
+}
+
+func TestFindTagsByDigest_IndexChild(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	repo := strings.TrimPrefix(server.URL, "http://") + "/app"
+
+	idx, err := random.Index(64, 1, 2)
+	require.NoError(t, err)
+	idxManifest, err := idx.IndexManifest()
+	require.NoError(t, err)
+	for _, tag := range []string{"multi", "latest"} {
+		ref, err := name.ParseReference(repo + ":" + tag)
+		require.NoError(t, err)
+		require.NoError(t, remote.WriteIndex(ref, idx))
+	}
+	pushRandomImage(t, repo+":other")
+
+	provider := NewToolProvider(oci.NewClient())
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"repository": repo,
+		"digest":     idxManifest.Manifests[0].Digest.String(),
+	}
+	result, err := provider.FindTagsByDigest(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	found, ok := result.StructuredContent.(FindTagsByDigestResult)
+	require.True(t, ok)
+	assert.Equal(t, 3, found.ScannedTags)
+	require.Len(t, found.Tags, 2)
+	for _, match := range found.Tags {
+		assert.Equal(t, tagMatchIndexChild, match.Match)
+		assert.True(t, types.MediaType(match.MediaType).IsIndex())
+	}
+}
+
+// scanBackend resolves every tag to its own digest, except target, and fails
+// to resolve broken.
+type scanBackend struct {
+	oci.ContentReader
+	target v1.Hash
+}
+
+func (b scanBackend) HeadReference(_ context.Context, imageRef string) (name.Reference, *v1.Descriptor, error) {
+	switch {
+	case strings.HasSuffix(imageRef, ":broken"):
+		return nil, nil, errors.New("manifest unknown")
+	case strings.HasSuffix(imageRef, ":target"):
+		return nil, &v1.Descriptor{Digest: b.target, MediaType: types.OCIManifestSchema1}, nil
+	}
+	digest, _, err := v1.SHA256(strings.NewReader(imageRef))
+	return nil, &v1.Descriptor{Digest: digest, MediaType: types.OCIManifestSchema1}, err
+}
+
+func TestTagScan(t *testing.T) {
+	target := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)}
+	tags := []string{"target", "broken", "sha256-" + strings.Repeat("b", 64) + ".sig"}
+	for i := range maxScannedTags {
+		tags = append(tags, fmt.Sprintf("v%d", i))
+	}
+
+	scan := &tagScan{client: scanBackend{target: target}, repository: "registry.example/app", digest: target,
+		indexes: map[v1.Hash]*indexFetch{}}
+	var result FindTagsByDigestResult
+	scan.scanTags(t.Context(), tags, &result)
+
+	// Legacy cosign tags are ignored, and failures are not counted as scanned.
+	assert.Equal(t, maxScannedTags-1, result.ScannedTags)
+	assert.Equal(t, []string{"broken"}, result.FailedTags)
+	assert.Equal(t, 2, result.SkippedTags)
+	require.Len(t, result.Tags, 1)
+	assert.Equal(t, "target", result.Tags[0].Tag)
+}
+
+func TestFindTagsByDigest_PartialOnTimeout(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v2/app/tags/list" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/app/tags/list?n=2&last=v2>; rel="next"`)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"app","tags":["v1","v2"]}`))
+		case r.URL.Path == "/v2/app/manifests/v1":
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.Header().Set("Content-Type", string(types.OCIManifestSchema1))
+			w.Header().Set("Content-Length", "2")
+		default:
+			// The rest of the listing and v2 never answer.
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	provider := NewToolProvider(oci.NewClient())
+	req := mcp.CallToolRequest{}
+	req.Params.Name = FindTagsByDigestToolName
+	req.Params.Arguments = map[string]interface{}{
+		"repository":      strings.TrimPrefix(server.URL, "http://") + "/app",
+		"digest":          digest,
+		"timeout_seconds": 0.2,
+	}
+
+	result, err := provider.FindTagsByDigest(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	found, ok := result.StructuredContent.(FindTagsByDigestResult)
+	require.True(t, ok)
+	assert.True(t, found.Partial)
+	require.Len(t, found.Tags, 1)
+	assert.Equal(t, "v1", found.Tags[0].Tag)
+	assert.Equal(t, 1, found.ScannedTags)
+	assert.Equal(t, []string{"v2"}, found.UnscannedTags)
+	assert.Empty(t, found.FailedTags)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "1 tags unscanned")
+}
@@ -0,0 +1,50 @@
+package mcp
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// resultMetaKey is the _meta key under which tool results report how their
+// registry requests were served.
+const resultMetaKey = "ocireg"
+
+// toolRetryBudget is the total number of retried registry requests allowed per
+// tool call, on top of the per-request retry limit.
+const toolRetryBudget = 10
+
+// ResultMetadata is reported in the _meta of tool results.
+type ResultMetadata struct {
+	// Endpoints lists the registries that served the call, including mirrors
+	// serving on behalf of an upstream registry.
+	Endpoints []oci.EndpointUse `json:"endpoints,omitempty"`
+	// Retries is the number of registry requests that were retried.
+	Retries int `json:"retries,omitempty"`
+	// RateLimit is the most constrained pull rate limit reported by the
+	// registries, such as Docker Hub's RateLimit-* headers.
+	RateLimit *oci.RateLimit `json:"rateLimit,omitempty"`
+	// CacheHits and CacheMisses count the content fetched by digest, tag
+	// resolutions and tag lists that were and were not served from cache.
+	CacheHits   int `json:"cacheHits,omitempty"`
+	CacheMisses int `json:"cacheMisses,omitempty"`
+}
+
+// withTraceMeta attaches the trace recorded during a tool call to the result's _meta.
+func withTraceMeta(result *mcp.CallToolResult, trace *oci.Trace) *mcp.CallToolResult {
+	meta := ResultMetadata{Endpoints: trace.Endpoints(), Retries: trace.Retries(), RateLimit: trace.RateLimit()}
+	meta.CacheHits, meta.CacheMisses = trace.Cache()
+	if len(meta.Endpoints) == 0 && meta.Retries == 0 && meta.RateLimit == nil &&
+		meta.CacheHits == 0 && meta.CacheMisses == 0 {
+		return result
+	}
+
+	if result.Meta == nil {
+		result.Meta = &mcp.Meta{}
+	}
+	if result.Meta.AdditionalFields == nil {
+		result.Meta.AdditionalFields = map[string]any{}
+	}
+	result.Meta.AdditionalFields[resultMetaKey] = meta
+	return result
+}
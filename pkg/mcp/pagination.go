@@ -1,6 +1,9 @@
 package mcp
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -34,40 +37,82 @@ func isValidSortOrder(order string) bool {
 	}
 }
 
-// listTagsCursor represents the pagination state for list_tags.
-type listTagsCursor struct {
-	Offset int    `json:"o"`
+// listCursor represents the pagination state for list tools. Cursors are keyed
+// on the last item returned rather than an offset, so items added or removed
+// between calls do not shift later pages.
+type listCursor struct {
+	After  string `json:"a"`
 	Sort   string `json:"s"`
+	Scope  string `json:"r"`
+	Filter string `json:"f,omitempty"`
 }
 
-// encodeCursor encodes pagination state into an opaque cursor string.
-func encodeCursor(offset int, sortOrder string) string {
-	c := listTagsCursor{Offset: offset, Sort: sortOrder}
-	data, _ := json.Marshal(c)
-	return base64.RawURLEncoding.EncodeToString(data)
+// cursorCodec encodes and authenticates pagination cursors with an HMAC so
+// clients cannot forge or tamper with them.
+type cursorCodec struct {
+	key []byte
 }
 
-// decodeCursor decodes an opaque cursor string into pagination state.
-func decodeCursor(cursorStr string) (listTagsCursor, error) {
-	data, err := base64.RawURLEncoding.DecodeString(cursorStr)
+// newCursorCodec creates a cursorCodec. If key is empty a random key is
+// generated, which means cursors do not survive a server restart.
+func newCursorCodec(key []byte) *cursorCodec {
+	if len(key) == 0 {
+		// crypto/rand.Read never returns an error.
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	return &cursorCodec{key: key}
+}
+
+// sign returns the HMAC of the given cursor payload.
+func (c *cursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encode encodes pagination state into an opaque, signed cursor string.
+func (c *cursorCodec) encode(cursor listCursor) string {
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// decode verifies and decodes an opaque cursor string into pagination state.
+func (c *cursorCodec) decode(cursorStr string) (listCursor, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(cursorStr, ".")
+	if !ok {
+		return listCursor{}, fmt.Errorf("invalid cursor: malformed")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
 	if err != nil {
-		return listTagsCursor{}, fmt.Errorf("invalid cursor: %w", err)
+		return listCursor{}, fmt.Errorf("invalid cursor: %w", err)
 	}
 
-	var c listTagsCursor
-	if err := json.Unmarshal(data, &c); err != nil {
-		return listTagsCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return listCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	if !hmac.Equal(mac, c.sign(payload)) {
+		return listCursor{}, fmt.Errorf("invalid cursor: signature mismatch (cursor was not issued by this server)")
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return listCursor{}, fmt.Errorf("invalid cursor: %w", err)
 	}
 
-	if c.Offset < 0 {
-		return listTagsCursor{}, fmt.Errorf("invalid cursor: negative offset")
+	if cursor.After == "" {
+		return listCursor{}, fmt.Errorf("invalid cursor: missing position")
 	}
 
-	if !isValidSortOrder(c.Sort) {
-		return listTagsCursor{}, fmt.Errorf("invalid cursor: unrecognized sort order %q", c.Sort)
+	if !isValidSortOrder(cursor.Sort) {
+		return listCursor{}, fmt.Errorf("invalid cursor: unrecognized sort order %q", cursor.Sort)
 	}
 
-	return c, nil
+	return cursor, nil
 }
 
 // sortTags returns a sorted copy of the tags slice according to the given order.
@@ -75,16 +120,10 @@ func sortTags(tags []string, order string) []string {
 	sorted := make([]string, len(tags))
 	copy(sorted, tags)
 
-	switch order {
-	case SortAlphabetical:
-		sort.Strings(sorted)
-	case SortAlphabeticalDesc:
-		sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
-	case SortSemver:
-		sortSemver(sorted, false)
-	case SortSemverDesc:
-		sortSemver(sorted, true)
-	}
+	less := tagLess(order)
+	sort.Slice(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
 
 	return sorted
 }
@@ -97,47 +136,68 @@ func ensureVPrefix(tag string) string {
 	return tag
 }
 
-// sortSemver sorts tags with valid semver first, non-semver appended alphabetically.
-func sortSemver(tags []string, descending bool) {
-	var semverTags, nonSemverTags []string
+// tagLess returns the strict ordering function for the given sort order.
+// Semver orders place valid semver tags first and non-semver tags after them
+// alphabetically; tags with equal precedence (e.g. "1.0.0" and "v1.0.0") are
+// ordered by their raw string so the ordering is total.
+func tagLess(order string) func(a, b string) bool {
+	switch order {
+	case SortAlphabeticalDesc:
+		return func(a, b string) bool { return a > b }
+	case SortSemver:
+		return func(a, b string) bool { return semverLess(a, b, false) }
+	case SortSemverDesc:
+		return func(a, b string) bool { return semverLess(a, b, true) }
+	default:
+		return func(a, b string) bool { return a < b }
+	}
+}
 
-	for _, tag := range tags {
-		if semver.IsValid(ensureVPrefix(tag)) {
-			semverTags = append(semverTags, tag)
-		} else {
-			nonSemverTags = append(nonSemverTags, tag)
-		}
+// semverLess compares two tags using semver precedence.
+func semverLess(a, b string, descending bool) bool {
+	va, vb := ensureVPrefix(a), ensureVPrefix(b)
+	aValid, bValid := semver.IsValid(va), semver.IsValid(vb)
+
+	if aValid != bValid {
+		return aValid
 	}
 
-	sort.Slice(semverTags, func(i, j int) bool {
-		cmp := semver.Compare(ensureVPrefix(semverTags[i]), ensureVPrefix(semverTags[j]))
-		if descending {
-			return cmp > 0
+	if aValid {
+		if cmp := semver.Compare(va, vb); cmp != 0 {
+			if descending {
+				return cmp > 0
+			}
+			return cmp < 0
 		}
-		return cmp < 0
-	})
+	}
 
 	if descending {
-		sort.Sort(sort.Reverse(sort.StringSlice(nonSemverTags)))
-	} else {
-		sort.Strings(nonSemverTags)
+		return a > b
 	}
-
-	copy(tags, semverTags)
-	copy(tags[len(semverTags):], nonSemverTags)
+	return a < b
 }
 
-// paginateTags returns a page of tags from the given offset with the given limit.
-// nextOffset is 0 when there are no more pages.
-func paginateTags(tags []string, offset, limit int) (page []string, nextOffset int) {
-	if offset >= len(tags) {
-		return nil, 0
+// paginateAfter returns up to limit items from sorted that come strictly after
+// the given item in the ordering defined by less. An empty after starts from the
+// beginning. hasMore reports whether items remain beyond the returned page.
+func paginateAfter(
+	sorted []string, after string, limit int, less func(a, b string) bool,
+) (page []string, hasMore bool) {
+	start := 0
+	if after != "" {
+		start = sort.Search(len(sorted), func(i int) bool {
+			return less(after, sorted[i])
+		})
+	}
+
+	if start >= len(sorted) {
+		return nil, false
 	}
 
-	end := offset + limit
-	if end >= len(tags) {
-		return tags[offset:], 0
+	end := start + limit
+	if end >= len(sorted) {
+		return sorted[start:], false
 	}
 
-	return tags[offset:end], end
+	return sorted[start:end], true
 }
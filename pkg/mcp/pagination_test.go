@@ -1,6 +1,8 @@
 package mcp
 
 import (
+	"encoding/base64"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,40 +10,47 @@ import (
 )
 
 func TestCursorRoundTrip(t *testing.T) {
+	codec := newCursorCodec(nil)
 	tests := []struct {
 		name   string
-		offset int
-		sort   string
+		cursor listCursor
 	}{
-		{"zero offset alphabetical", 0, SortAlphabetical},
-		{"positive offset semver", 100, SortSemver},
-		{"large offset desc", 9999, SortAlphabeticalDesc},
+		{"alphabetical", listCursor{After: "v1.0.0", Sort: SortAlphabetical, Scope: "index.docker.io/library/alpine"}},
+		{"semver", listCursor{After: "3.19", Sort: SortSemver, Scope: "ghcr.io/org/app"}},
+		{"with filter", listCursor{After: "team/api", Sort: SortAlphabeticalDesc, Scope: "registry.example.com", Filter: "team/"}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cursor := encodeCursor(tt.offset, tt.sort)
-			decoded, err := decodeCursor(cursor)
+			decoded, err := codec.decode(codec.encode(tt.cursor))
 			require.NoError(t, err)
-			assert.Equal(t, tt.offset, decoded.Offset)
-			assert.Equal(t, tt.sort, decoded.Sort)
+			assert.Equal(t, tt.cursor, decoded)
 		})
 	}
 }
 
 func TestDecodeCursor_Errors(t *testing.T) {
+	codec := newCursorCodec([]byte("test-key"))
+	valid := codec.encode(listCursor{After: "a", Sort: SortAlphabetical, Scope: "repo"})
+	payload, _, _ := strings.Cut(valid, ".")
+
 	tests := []struct {
 		name   string
 		cursor string
 	}{
-		{"bad base64", "!!!not-base64!!!"},
-		{"bad json", "bm90LWpzb24"},
-		{"negative offset", encodeCursor(-1, SortAlphabetical)},
+		{"no signature", payload},
+		{"bad base64", "!!!not-base64!!!.AAAA"},
+		{"tampered payload", base64.RawURLEncoding.EncodeToString(
+			[]byte(`{"a":"z","s":"alphabetical","r":"repo"}`)) + valid[len(payload):]},
+		{"foreign key", newCursorCodec([]byte("other-key")).encode(
+			listCursor{After: "a", Sort: SortAlphabetical, Scope: "repo"})},
+		{"missing position", codec.encode(listCursor{Sort: SortAlphabetical, Scope: "repo"})},
+		{"bad sort", codec.encode(listCursor{After: "a", Sort: "random", Scope: "repo"})},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := decodeCursor(tt.cursor)
+			_, err := codec.decode(tt.cursor)
 			assert.Error(t, err)
 		})
 	}
@@ -105,44 +114,62 @@ func TestSortTags_Single(t *testing.T) {
 	assert.Equal(t, []string{"v1.0.0"}, result)
 }
 
-func TestPaginateTags_FirstPage(t *testing.T) {
+func TestPaginateAfter_FirstPage(t *testing.T) {
 	tags := []string{"a", "b", "c", "d", "e"}
-	page, next := paginateTags(tags, 0, 2)
+	page, more := paginateAfter(tags, "", 2, tagLess(SortAlphabetical))
 	assert.Equal(t, []string{"a", "b"}, page)
-	assert.Equal(t, 2, next)
+	assert.True(t, more)
 }
 
-func TestPaginateTags_MiddlePage(t *testing.T) {
+func TestPaginateAfter_MiddlePage(t *testing.T) {
 	tags := []string{"a", "b", "c", "d", "e"}
-	page, next := paginateTags(tags, 2, 2)
+	page, more := paginateAfter(tags, "b", 2, tagLess(SortAlphabetical))
 	assert.Equal(t, []string{"c", "d"}, page)
-	assert.Equal(t, 4, next)
+	assert.True(t, more)
 }
 
-func TestPaginateTags_LastPage(t *testing.T) {
+func TestPaginateAfter_LastPage(t *testing.T) {
 	tags := []string{"a", "b", "c", "d", "e"}
-	page, next := paginateTags(tags, 4, 2)
+	page, more := paginateAfter(tags, "d", 2, tagLess(SortAlphabetical))
 	assert.Equal(t, []string{"e"}, page)
-	assert.Equal(t, 0, next)
+	assert.False(t, more)
 }
 
-func TestPaginateTags_ExactBoundary(t *testing.T) {
+func TestPaginateAfter_ExactBoundary(t *testing.T) {
 	tags := []string{"a", "b", "c", "d"}
-	page, next := paginateTags(tags, 0, 4)
+	page, more := paginateAfter(tags, "", 4, tagLess(SortAlphabetical))
 	assert.Equal(t, []string{"a", "b", "c", "d"}, page)
-	assert.Equal(t, 0, next)
+	assert.False(t, more)
+}
+
+func TestPaginateAfter_StableWhenTagsInserted(t *testing.T) {
+	less := tagLess(SortAlphabetical)
+	page, _ := paginateAfter([]string{"a", "c", "e", "g"}, "", 2, less)
+	assert.Equal(t, []string{"a", "c"}, page)
+
+	// A tag sorting before the cursor position is added between calls; the
+	// next page must neither repeat "c" nor skip "e".
+	page, more := paginateAfter([]string{"a", "b", "c", "e", "g"}, "c", 2, less)
+	assert.Equal(t, []string{"e", "g"}, page)
+	assert.False(t, more)
+}
+
+func TestPaginateAfter_DeletedCursorTag(t *testing.T) {
+	tags := []string{"a", "b", "d", "e"}
+	page, _ := paginateAfter(tags, "c", 2, tagLess(SortAlphabetical))
+	assert.Equal(t, []string{"d", "e"}, page)
 }
 
-func TestPaginateTags_LimitExceedsRemaining(t *testing.T) {
-	tags := []string{"a", "b", "c"}
-	page, next := paginateTags(tags, 1, 100)
-	assert.Equal(t, []string{"b", "c"}, page)
-	assert.Equal(t, 0, next)
+func TestPaginateAfter_SemverDesc(t *testing.T) {
+	sorted := sortTags([]string{"v1.0.0", "v3.0.0", "v2.0.0", "latest"}, SortSemverDesc)
+	page, more := paginateAfter(sorted, "v3.0.0", 2, tagLess(SortSemverDesc))
+	assert.Equal(t, []string{"v2.0.0", "v1.0.0"}, page)
+	assert.True(t, more)
 }
 
-func TestPaginateTags_OffsetBeyondEnd(t *testing.T) {
+func TestPaginateAfter_BeyondEnd(t *testing.T) {
 	tags := []string{"a", "b"}
-	page, next := paginateTags(tags, 10, 2)
+	page, more := paginateAfter(tags, "z", 2, tagLess(SortAlphabetical))
 	assert.Nil(t, page)
-	assert.Equal(t, 0, next)
+	assert.False(t, more)
 }
@@ -0,0 +1,48 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ProbeRegistry handles the probe_registry tool.
+func (p *ToolProvider) ProbeRegistry(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	registry := mcp.ParseString(req, "registry", "")
+	if registry == "" {
+		return invalidArgumentResult("registry is required"), nil
+	}
+	repository := mcp.ParseString(req, "repository", "")
+
+	client := p.getClient(req)
+
+	reqCtx, trace, cancel := p.callContext(ctx, req, registryHost(client, registry))
+	defer cancel()
+
+	probe, err := client.ProbeRegistry(reqCtx, registry, repository)
+	if err != nil {
+		return toolErrorResult(reqCtx, "failed to probe registry", err), nil
+	}
+
+	result := ProbeRegistryResult{
+		Registry:        probe.Registry,
+		APIVersion:      probe.APIVersion,
+		AuthScheme:      probe.AuthScheme,
+		Realm:           probe.Realm,
+		Service:         probe.Service,
+		AnonymousAccess: probe.AnonymousAccess,
+		Referrers:       probe.Referrers,
+		Catalog:         probe.Catalog,
+		Notes:           probe.Notes,
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
+	}
+
+	fallback := fmt.Sprintf("Capabilities of %s:\n\n```json\n%s\n```", result.Registry, string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
+}
@@ -0,0 +1,45 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// GetDockerHubRateLimit handles the get_docker_hub_rate_limit tool.
+func (p *ToolProvider) GetDockerHubRateLimit(
+	ctx context.Context, req mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	client := p.getClient(req)
+
+	reqCtx, trace, cancel := p.callContext(ctx, req, name.DefaultRegistry)
+	defer cancel()
+
+	limit, err := client.DockerHubRateLimit(reqCtx)
+	if err != nil {
+		return toolErrorResult(reqCtx, "failed to check Docker Hub rate limit", err), nil
+	}
+
+	result := RateLimitResult{Registry: "docker.io", Limited: limit != nil}
+	if limit != nil {
+		result.Limit = limit.Limit
+		result.Remaining = limit.Remaining
+		result.WindowSeconds = limit.WindowSeconds
+		result.Source = limit.Source
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
+	}
+
+	summary := "Docker Hub reports no pull rate limit for these credentials"
+	if result.Limited {
+		summary = fmt.Sprintf("Docker Hub pulls remaining: %d of %d", result.Remaining, result.Limit)
+	}
+	fallback := fmt.Sprintf("%s:\n\n```json\n%s\n```", summary, string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
+}
@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ParseReference handles the parse_reference tool. It does not contact any registry.
+func (p *ToolProvider) ParseReference(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	imageRef := mcp.ParseString(req, "image_ref", "")
+	if imageRef == "" {
+		return invalidArgumentResult("image_ref is required"), nil
+	}
+
+	strict := mcp.ParseBoolean(req, "strict", false)
+	names := p.nameResolver(req)
+
+	result := ParseReferenceResult{Input: imageRef, Strict: strict}
+
+	info, err := names.AnalyzeReference(imageRef, strict)
+	if err != nil {
+		result.Error = err.Error()
+		for _, s := range names.SuggestReferenceFixes(imageRef, strict) {
+			result.Suggestions = append(result.Suggestions, ReferenceSuggestion{
+				Reference: s.Reference,
+				Reason:    s.Reason,
+			})
+		}
+	} else {
+		result.Valid = true
+		result.FullyQualified = info.Reference.Name()
+		result.Registry = info.Registry
+		result.Repository = info.Repository
+		result.Tag = info.Tag
+		result.Digest = info.Digest
+		result.Alias = info.Alias
+		result.DefaultsApplied = ReferenceDefaults{
+			Registry:  info.DefaultRegistryApplied,
+			Namespace: info.DefaultNamespaceApplied,
+			Tag:       info.DefaultTagApplied,
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
+	}
+
+	status := "valid"
+	if !result.Valid {
+		status = "invalid"
+	}
+	fallback := fmt.Sprintf("Reference %s is %s:\n\n```json\n%s\n```", imageRef, status, string(resultJSON))
+	return mcp.NewToolResultStructured(result, fallback), nil
+}
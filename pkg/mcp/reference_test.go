@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+func TestParseReference_Valid(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": "alpine"}
+
+	result, err := provider.ParseReference(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	parsed, ok := result.StructuredContent.(ParseReferenceResult)
+	require.True(t, ok)
+	assert.True(t, parsed.Valid)
+	assert.Equal(t, "index.docker.io/library/alpine:latest", parsed.FullyQualified)
+	assert.Equal(t, ReferenceDefaults{Registry: true, Namespace: true, Tag: true}, parsed.DefaultsApplied)
+}
+
+func TestParseReference_InvalidWithSuggestion(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": "alpine", "strict": true}
+
+	result, err := provider.ParseReference(t.Context(), req)
+	require.NoError(t, err)
+
+	parsed, ok := result.StructuredContent.(ParseReferenceResult)
+	require.True(t, ok)
+	assert.False(t, parsed.Valid)
+	assert.NotEmpty(t, parsed.Error)
+	require.NotEmpty(t, parsed.Suggestions)
+	assert.Equal(t, "docker.io/library/alpine:latest", parsed.Suggestions[0].Reference)
+}
+
+func TestParseReference_NameResolver(t *testing.T) {
+	cfg := &oci.Config{Aliases: []oci.Alias{{Prefix: "internal/", Replacement: "registry.corp.example/"}}}
+	provider := NewToolProviderWithFactory(func(http.Header) oci.Backend {
+		t.Fatal("parse_reference created a backend")
+		return nil
+	}, WithNameResolver(cfg))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": "internal/api:v1"}
+	result, err := provider.ParseReference(t.Context(), req)
+	require.NoError(t, err)
+
+	parsed, ok := result.StructuredContent.(ParseReferenceResult)
+	require.True(t, ok)
+	assert.Equal(t, "registry.corp.example/api:v1", parsed.FullyQualified)
+	assert.Equal(t, "internal/", parsed.Alias)
+}
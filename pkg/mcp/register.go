@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterOptions selects the tools Register adds to a server and how they
+// are named.
+type RegisterOptions struct {
+	// Include lists the tools to register. When empty, all tools are
+	// registered.
+	Include []string
+	// Exclude lists tools not to register, even if included.
+	Exclude []string
+	// Prefix is prepended to the names of the registered tools, e.g. "oci_"
+	// to avoid clashes with other tools of the server. Include, Exclude and
+	// the per-tool timeouts use the unprefixed names.
+	Prefix string
+	// NoResources skips registering the resource templates.
+	NoResources bool
+}
+
+// Register adds the provider's tools selected by opts and its resource
+// templates to server. It fails without adding anything if Include or Exclude
+// name an unknown tool.
+func (p *ToolProvider) Register(server *mcpserver.MCPServer, opts RegisterOptions) error {
+	tools, err := p.selectTools(opts)
+	if err != nil {
+		return err
+	}
+	server.AddTools(tools...)
+	if !opts.NoResources {
+		server.AddResourceTemplates(p.ServerResourceTemplates()...)
+	}
+	return nil
+}
+
+// selectTools returns the tools selected by opts, renamed with its prefix.
+func (p *ToolProvider) selectTools(opts RegisterOptions) ([]mcpserver.ServerTool, error) {
+	all := p.ServerTools()
+	names := make([]string, len(all))
+	for i, tool := range all {
+		names[i] = tool.Tool.Name
+	}
+	for _, name := range slices.Concat(opts.Include, opts.Exclude) {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("unknown tool %q, available tools: %s", name, strings.Join(names, ", "))
+		}
+	}
+
+	var tools []mcpserver.ServerTool
+	for _, tool := range all {
+		name := tool.Tool.Name
+		if len(opts.Include) > 0 && !slices.Contains(opts.Include, name) || slices.Contains(opts.Exclude, name) {
+			continue
+		}
+		if opts.Prefix != "" {
+			tool.Tool.Name = opts.Prefix + name
+			tool.Handler = withToolName(name, tool.Handler)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// withToolName restores the unprefixed tool name of requests before handler
+// sees them, so per-tool settings apply to prefixed tools too.
+func withToolName(name string, handler mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		req.Params.Name = name
+		return handler(ctx, req)
+	}
+}
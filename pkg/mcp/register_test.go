@@ -0,0 +1,69 @@
+package mcp
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// registeredTools returns the sorted names of the tools registered on server.
+func registeredTools(server *mcpserver.MCPServer) []string {
+	return slices.Sorted(maps.Keys(server.ListTools()))
+}
+
+func TestRegister(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	server := mcpserver.NewMCPServer("test", "0.0.0")
+	require.NoError(t, provider.Register(server, RegisterOptions{}))
+	assert.Len(t, server.ListTools(), len(provider.GetTools()))
+	for _, tool := range provider.ServerTools() {
+		assert.NotNil(t, tool.Handler, "tool %q has no handler", tool.Tool.Name)
+	}
+
+	server = mcpserver.NewMCPServer("test", "0.0.0")
+	require.NoError(t, provider.Register(server, RegisterOptions{
+		Include: []string{GetImageInfoToolName, ListTagsToolName, ParseReferenceToolName},
+		Exclude: []string{ListTagsToolName},
+		Prefix:  "oci_",
+	}))
+	assert.Equal(t, []string{"oci_get_image_info", "oci_parse_reference"}, registeredTools(server))
+
+	server = mcpserver.NewMCPServer("test", "0.0.0")
+	err := provider.Register(server, RegisterOptions{Exclude: []string{"get_image"}})
+	require.ErrorContains(t, err, `unknown tool "get_image"`)
+	assert.Empty(t, server.ListTools())
+}
+
+func TestRegister_Prefix(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+	server := mcpserver.NewMCPServer("test", "0.0.0")
+	require.NoError(t, provider.Register(server, RegisterOptions{Prefix: "oci_"}))
+
+	tool := server.GetTool("oci_parse_reference")
+	require.NotNil(t, tool)
+	// Handlers see the unprefixed name, which per-tool timeouts are keyed by.
+	var seen string
+	handler := withToolName(ParseReferenceToolName, func(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		seen = req.Params.Name
+		return nil, nil
+	})
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "oci_parse_reference"
+	_, err := handler(t.Context(), req)
+	require.NoError(t, err)
+	assert.Equal(t, ParseReferenceToolName, seen)
+
+	req.Params.Arguments = map[string]interface{}{"image_ref": "alpine"}
+	result, err := tool.Handler(t.Context(), req)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// maxResolveReferences is the maximum number of references accepted by a
+// single resolve_reference call.
+const maxResolveReferences = 100
+
+// defaultConcurrency bounds the number of registry requests a single tool call
+// issues in parallel.
+const defaultConcurrency = 8
+
+// forEachConcurrently calls fn for every index in [0, n) using at most limit
+// goroutines, returning once all calls have completed.
+func forEachConcurrently(n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// parseReferenceList collects references from the image_ref and image_refs
+// arguments, preserving order.
+func parseReferenceList(req mcp.CallToolRequest) []string {
+	var refs []string
+	if ref := mcp.ParseString(req, "image_ref", ""); ref != "" {
+		refs = append(refs, ref)
+	}
+	for _, ref := range req.GetStringSlice("image_refs", nil) {
+		if ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// resolveOne resolves a single reference for the resolve_reference tool.
+func resolveOne(ctx context.Context, client oci.Backend, imageRef string) ResolvedReference {
+	resolved := ResolvedReference{Reference: imageRef}
+
+	ctx, trace := oci.WithTrace(ctx)
+	ref, desc, err := client.HeadReference(ctx, imageRef)
+	if ref != nil {
+		resolved.Registry = ref.Context().RegistryStr()
+		resolved.Repository = ref.Context().RepositoryStr()
+		if tag, ok := ref.(name.Tag); ok {
+			resolved.Tag = tag.TagStr()
+		}
+	} else {
+		resolved.Repository, _, _ = oci.RepositoryOf(client, imageRef)
+	}
+	if err != nil {
+		resolved.Error = err.Error()
+		resolved.ErrorCategory = string(oci.ClassifyError(ctx, err).Category)
+		return resolved
+	}
+
+	resolved.Digest = desc.Digest.String()
+	resolved.MediaType = string(desc.MediaType)
+	resolved.Size = desc.Size
+	if endpoints := trace.Endpoints(); len(endpoints) > 0 {
+		resolved.ServedBy = endpoints[0].Registry
+	}
+	return resolved
+}
+
+// ResolveReference handles the resolve_reference tool.
+func (p *ToolProvider) ResolveReference(
+	ctx context.Context, req mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	refs := parseReferenceList(req)
+	if len(refs) == 0 {
+		return invalidArgumentResult("image_ref or image_refs is required"), nil
+	}
+	if len(refs) > maxResolveReferences {
+		return invalidArgumentResult(fmt.Sprintf(
+			"too many references: %d (max %d)", len(refs), maxResolveReferences,
+		)), nil
+	}
+
+	client := p.getClient(req)
+
+	registries := make([]string, len(refs))
+	for i, ref := range refs {
+		registries[i] = referenceRegistry(client, ref)
+	}
+	reqCtx, trace, cancel := p.callContext(ctx, req, registries...)
+	defer cancel()
+
+	resolved := make([]ResolvedReference, len(refs))
+	forEachConcurrently(len(refs), defaultConcurrency, func(i int) {
+		resolved[i] = resolveOne(reqCtx, client, refs[i])
+	})
+
+	result := ResolveReferenceResult{References: resolved}
+	for _, r := range resolved {
+		if r.Error != "" {
+			result.Failed++
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
+	}
+
+	fallback := fmt.Sprintf("Resolved %d reference(s), %d failed:\n\n```json\n%s\n```",
+		len(resolved), result.Failed, string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
+}
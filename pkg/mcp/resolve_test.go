@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+func TestForEachConcurrently(t *testing.T) {
+	var running, peak, calls atomic.Int32
+	forEachConcurrently(20, 3, func(int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		calls.Add(1)
+		running.Add(-1)
+	})
+
+	assert.Equal(t, int32(20), calls.Load())
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+}
+
+func TestResolveReference_MissingRefs(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	result, err := provider.ResolveReference(t.Context(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	assert.True(t, ok)
+	assert.Contains(t, textContent.Text, "image_ref or image_refs is required")
+}
+
+func TestResolveReference_Batch(t *testing.T) {
+	host := pushRandomImages(t, "app:v1")
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"image_ref":  host + "/app:v1",
+		"image_refs": []interface{}{host + "/app:missing", "INVALID::ref"},
+	}
+
+	result, err := provider.ResolveReference(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	resolved, ok := result.StructuredContent.(ResolveReferenceResult)
+	require.True(t, ok)
+	require.Len(t, resolved.References, 3)
+	assert.Equal(t, 2, resolved.Failed)
+
+	found := resolved.References[0]
+	assert.Equal(t, host, found.Registry)
+	assert.Equal(t, "app", found.Repository)
+	assert.Equal(t, "v1", found.Tag)
+	assert.Contains(t, found.Digest, "sha256:")
+	assert.NotEmpty(t, found.MediaType)
+	assert.Positive(t, found.Size)
+	assert.Empty(t, found.Error)
+
+	missing := resolved.References[1]
+	assert.Equal(t, "missing", missing.Tag)
+	assert.Empty(t, missing.Digest)
+	assert.NotEmpty(t, missing.Error)
+	assert.Equal(t, string(oci.ErrorCategoryNotFound), missing.ErrorCategory)
+
+	assert.Contains(t, resolved.References[2].Error, "parsing image reference")
+	assert.Equal(t, string(oci.ErrorCategoryInvalidArgument), resolved.References[2].ErrorCategory)
+}
+
+func TestResolveReference_Mirror(t *testing.T) {
+	upstream := pushRandomImages(t, "app:v1", "app:v2")
+	mirror := pushRandomImages(t, "proxy/app:v1")
+	client := oci.NewClientWithConfig(&oci.Config{
+		Mirrors: []oci.Mirror{{Upstream: upstream, Endpoint: mirror + "/proxy"}},
+	})
+	provider := NewToolProvider(oci.NewBackend(client))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"image_refs": []interface{}{upstream + "/app:v1", upstream + "/app:v2"},
+	}
+
+	result, err := provider.ResolveReference(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	resolved, ok := result.StructuredContent.(ResolveReferenceResult)
+	require.True(t, ok)
+	require.Len(t, resolved.References, 2)
+	assert.Equal(t, mirror, resolved.References[0].ServedBy)
+	assert.Equal(t, upstream, resolved.References[1].ServedBy, "falls back to the upstream when the mirror lacks the tag")
+
+	require.NotNil(t, result.Meta)
+	meta, ok := result.Meta.AdditionalFields[resultMetaKey].(ResultMetadata)
+	require.True(t, ok)
+	assert.ElementsMatch(t, []oci.EndpointUse{
+		{Registry: mirror, Upstream: upstream},
+		{Registry: upstream},
+	}, meta.Endpoints)
+}
@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// Resource templates served by the provider. Repositories are fully qualified,
+// e.g. oci://index.docker.io/library/alpine@sha256:..., like the URIs of the
+// content returned by get_referrer_content.
+const (
+	// ContentResourceTemplate addresses a manifest, index, config or other
+	// blob by digest.
+	ContentResourceTemplate = "oci://{+repository}@{+digest}"
+	// ManifestResourceTemplate addresses the manifest or index a tag points at.
+	ManifestResourceTemplate = "oci://{+repository}:{tag}/manifest"
+	// ConfigResourceTemplate addresses the config of the image a tag points
+	// at; for an index, of its linux/amd64 image.
+	ConfigResourceTemplate = "oci://{+repository}:{tag}/config"
+)
+
+// defaultResourceMaxBytes is the default size limit of resource contents (4MiB).
+const defaultResourceMaxBytes = 4 << 20
+
+// WithResourceMaxBytes sets the largest manifest or blob served as a resource.
+// Larger content is rejected rather than truncated, so clients never see
+// partial JSON. A non-positive limit uses 4MiB.
+func WithResourceMaxBytes(n int64) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.resourceMaxBytes = n
+	}
+}
+
+// ServerResourceTemplates returns the resource templates provided by this MCP
+// server, each with the handler serving it.
+func (p *ToolProvider) ServerResourceTemplates() []mcpserver.ServerResourceTemplate {
+	return []mcpserver.ServerResourceTemplate{
+		{
+			Template: mcp.NewResourceTemplate(ContentResourceTemplate, "OCI content by digest",
+				mcp.WithTemplateDescription("A manifest, index, image config or other blob of a repository, "+
+					"addressed by digest. Manifests are served with their media type; JSON blobs as "+
+					"application/json and binary blobs base64-encoded."),
+			),
+			Handler: p.readContentResource,
+		},
+		{
+			Template: mcp.NewResourceTemplate(ManifestResourceTemplate, "OCI manifest by tag",
+				mcp.WithTemplateDescription("The manifest or index a tag points at, with its media type."),
+			),
+			Handler: p.readManifestResource,
+		},
+		{
+			Template: mcp.NewResourceTemplate(ConfigResourceTemplate, "OCI image config by tag",
+				mcp.WithTemplateDescription("The config of the image a tag points at; for an index, "+
+					"of its linux/amd64 image."),
+			),
+			Handler: p.readConfigResource,
+		},
+	}
+}
+
+// readContentResource serves ContentResourceTemplate: the manifest with the
+// requested digest or, when there is none, the blob.
+func (p *ToolProvider) readContentResource(
+	ctx context.Context, req mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	repository, digest := resourceArgument(req, "repository"), resourceArgument(req, "digest")
+	client := p.backendFor(req.Header)
+	reqCtx, cancel := p.resourceContext(ctx, repositoryRegistry(client, repository))
+	defer cancel()
+
+	raw, desc, err := client.GetManifest(reqCtx, repository+"@"+digest)
+	if err == nil {
+		return p.manifestContents(req.Params.URI, raw, desc)
+	}
+	// Registries asked for a blob digest as a manifest answer with various
+	// client errors, not only 404, so any of them means trying the blob.
+	if status := oci.ClassifyError(reqCtx, err).StatusCode; !oci.IsNotFound(err) && (status < 400 || status >= 500) {
+		return nil, resourceError(reqCtx, req.Params.URI, err)
+	}
+
+	blob, err := client.GetBlob(reqCtx, repository, digest, p.maxResourceBytes())
+	if err != nil {
+		return nil, resourceError(reqCtx, req.Params.URI, err)
+	}
+	return []mcp.ResourceContents{blobContents(req.Params.URI, blob)}, nil
+}
+
+// readManifestResource serves ManifestResourceTemplate.
+func (p *ToolProvider) readManifestResource(
+	ctx context.Context, req mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	repository, tag := resourceArgument(req, "repository"), resourceArgument(req, "tag")
+	client := p.backendFor(req.Header)
+	reqCtx, cancel := p.resourceContext(ctx, repositoryRegistry(client, repository))
+	defer cancel()
+
+	raw, desc, err := client.GetManifest(reqCtx, repository+":"+tag)
+	if err != nil {
+		return nil, resourceError(reqCtx, req.Params.URI, err)
+	}
+	return p.manifestContents(req.Params.URI, raw, desc)
+}
+
+// readConfigResource serves ConfigResourceTemplate.
+func (p *ToolProvider) readConfigResource(
+	ctx context.Context, req mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	repository, tag := resourceArgument(req, "repository"), resourceArgument(req, "tag")
+	client := p.backendFor(req.Header)
+	reqCtx, cancel := p.resourceContext(ctx, repositoryRegistry(client, repository))
+	defer cancel()
+
+	img, err := oci.GetImage(reqCtx, client, repository+":"+tag)
+	if err != nil {
+		return nil, resourceError(reqCtx, req.Params.URI, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, resourceError(reqCtx, req.Params.URI, err)
+	}
+	if manifest.Config.Size > p.maxResourceBytes() {
+		return nil, resourceError(reqCtx, req.Params.URI, oci.ErrBlobTooLarge)
+	}
+	raw, err := img.RawConfigFile()
+	if err != nil {
+		return nil, resourceError(reqCtx, req.Params.URI, err)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		Meta:     map[string]any{"digest": manifest.Config.Digest.String()},
+		URI:      req.Params.URI,
+		MIMEType: string(manifest.Config.MediaType),
+		Text:     string(raw),
+	}}, nil
+}
+
+// manifestContents returns a manifest as resource contents with its media type.
+func (p *ToolProvider) manifestContents(uri string, raw []byte, desc v1.Descriptor) ([]mcp.ResourceContents, error) {
+	if int64(len(raw)) > p.maxResourceBytes() {
+		return nil, fmt.Errorf("reading %s: %w", uri, oci.ErrBlobTooLarge)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		Meta:     map[string]any{"digest": desc.Digest.String()},
+		URI:      uri,
+		MIMEType: string(desc.MediaType),
+		Text:     string(raw),
+	}}, nil
+}
+
+// blobContents returns a blob as resource contents. Registries do not record
+// the media type of blobs, so JSON and other text is served as such and
+// anything else base64-encoded.
+func blobContents(uri string, blob []byte) mcp.ResourceContents {
+	switch {
+	case json.Valid(blob):
+		return mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(blob)}
+	case utf8.Valid(blob):
+		return mcp.TextResourceContents{URI: uri, MIMEType: "text/plain", Text: string(blob)}
+	default:
+		return mcp.BlobResourceContents{
+			URI:      uri,
+			MIMEType: "application/octet-stream",
+			Blob:     base64.StdEncoding.EncodeToString(blob),
+		}
+	}
+}
+
+// resourceArgument returns a variable matched from a resource URI.
+func resourceArgument(req mcp.ReadResourceRequest, name string) string {
+	switch v := req.Params.Arguments[name].(type) {
+	case string:
+		return v
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// resourceContext prepares the context for reading a resource from a registry
+// host, with the server's timeout for it and the same retry budget as tools.
+func (p *ToolProvider) resourceContext(ctx context.Context, registry string) (context.Context, context.CancelFunc) {
+	reqCtx, cancel := context.WithTimeout(ctx, p.timeouts.timeout("", []string{registry}, 0))
+	return oci.WithRetryBudget(reqCtx, toolRetryBudget), cancel
+}
+
+// resourceError describes a failure to read a resource, with the same
+// classification as tool errors.
+func resourceError(ctx context.Context, uri string, err error) error {
+	if errors.Is(err, oci.ErrBlobTooLarge) {
+		return fmt.Errorf("reading %s: %w", uri, err)
+	}
+	return fmt.Errorf("reading %s (%s): %w", uri, oci.ClassifyError(ctx, err).Category, err)
+}
+
+// maxResourceBytes returns the size limit of resource contents.
+func (p *ToolProvider) maxResourceBytes() int64 {
+	if p.resourceMaxBytes <= 0 {
+		return defaultResourceMaxBytes
+	}
+	return p.resourceMaxBytes
+}
+
+// backendFor returns the backend serving a request with the given headers.
+func (p *ToolProvider) backendFor(header http.Header) oci.Backend {
+	client := p.client
+	if p.clientFactory != nil {
+		client = p.clientFactory(header)
+	}
+	if p.decorate != nil {
+		return p.decorate(client)
+	}
+	return client
+}
@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// resourceResponse is a resources/read response.
+type resourceResponse struct {
+	Result struct {
+		Contents []struct {
+			URI      string         `json:"uri"`
+			MIMEType string         `json:"mimeType"`
+			Text     string         `json:"text"`
+			Blob     string         `json:"blob"`
+			Meta     map[string]any `json:"_meta"`
+		} `json:"contents"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readResource reads uri from server through its JSON-RPC interface.
+func readResource(t *testing.T, server *mcpserver.MCPServer, uri string) resourceResponse {
+	t.Helper()
+	msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":%q}}`, uri)
+	raw, err := json.Marshal(server.HandleMessage(t.Context(), []byte(msg)))
+	require.NoError(t, err)
+	var resp resourceResponse
+	require.NoError(t, json.Unmarshal(raw, &resp))
+	return resp
+}
+
+func TestResources(t *testing.T) {
+	handler := registry.New()
+	reg := httptest.NewServer(handler)
+	t.Cleanup(reg.Close)
+	repo := strings.TrimPrefix(reg.URL, "http://") + "/app"
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(repo + ":v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	rawManifest, err := img.RawManifest()
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	rawConfig, err := img.RawConfigFile()
+	require.NoError(t, err)
+
+	provider := NewToolProvider(oci.NewClient(), WithResourceMaxBytes(1024))
+	server := mcpserver.NewMCPServer("test", "0.0.0")
+	require.NoError(t, provider.Register(server, RegisterOptions{}))
+
+	t.Run("manifest by tag", func(t *testing.T) {
+		resp := readResource(t, server, "oci://"+repo+":v1/manifest")
+		require.Nil(t, resp.Error)
+		require.Len(t, resp.Result.Contents, 1)
+		content := resp.Result.Contents[0]
+		assert.Equal(t, "oci://"+repo+":v1/manifest", content.URI)
+		assert.Equal(t, string(manifest.MediaType), content.MIMEType)
+		assert.Equal(t, string(rawManifest), content.Text)
+		assert.Equal(t, digest.String(), content.Meta["digest"])
+	})
+
+	t.Run("manifest by digest", func(t *testing.T) {
+		resp := readResource(t, server, "oci://"+repo+"@"+digest.String())
+		require.Nil(t, resp.Error)
+		require.Len(t, resp.Result.Contents, 1)
+		assert.Equal(t, string(manifest.MediaType), resp.Result.Contents[0].MIMEType)
+		assert.Equal(t, string(rawManifest), resp.Result.Contents[0].Text)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		for _, uri := range []string{"oci://" + repo + ":v1/config", "oci://" + repo + "@" + manifest.Config.Digest.String()} {
+			resp := readResource(t, server, uri)
+			require.Nil(t, resp.Error, uri)
+			require.Len(t, resp.Result.Contents, 1)
+			assert.Contains(t, resp.Result.Contents[0].MIMEType, "json")
+			assert.JSONEq(t, string(rawConfig), resp.Result.Contents[0].Text)
+		}
+	})
+
+	t.Run("binary blob", func(t *testing.T) {
+		layer := manifest.Layers[0]
+		resp := readResource(t, server, "oci://"+repo+"@"+layer.Digest.String())
+		require.Nil(t, resp.Error)
+		require.Len(t, resp.Result.Contents, 1)
+		assert.Equal(t, "application/octet-stream", resp.Result.Contents[0].MIMEType)
+		blob, err := base64.StdEncoding.DecodeString(resp.Result.Contents[0].Blob)
+		require.NoError(t, err)
+		assert.Len(t, blob, int(layer.Size))
+	})
+
+	t.Run("blob digest rejected as manifest", func(t *testing.T) {
+		// Some registries answer a blob digest at the manifests endpoint with
+		// 400 rather than 404.
+		strict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.Path, "/manifests/sha256:") && !strings.HasSuffix(r.URL.Path, digest.String()) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"not a manifest"}]}`))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		}))
+		t.Cleanup(strict.Close)
+		strictRepo := strings.TrimPrefix(strict.URL, "http://") + "/app"
+
+		resp := readResource(t, server, "oci://"+strictRepo+"@"+manifest.Config.Digest.String())
+		require.Nil(t, resp.Error)
+		require.Len(t, resp.Result.Contents, 1)
+		assert.JSONEq(t, string(rawConfig), resp.Result.Contents[0].Text)
+	})
+
+	t.Run("size cap", func(t *testing.T) {
+		small := NewToolProvider(oci.NewClient(), WithResourceMaxBytes(64))
+		server := mcpserver.NewMCPServer("test", "0.0.0")
+		require.NoError(t, small.Register(server, RegisterOptions{}))
+		resp := readResource(t, server, "oci://"+repo+"@"+manifest.Layers[0].Digest.String())
+		require.NotNil(t, resp.Error)
+		assert.Contains(t, resp.Error.Message, "size limit")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp := readResource(t, server, "oci://"+repo+":missing/manifest")
+		require.NotNil(t, resp.Error)
+		assert.Contains(t, resp.Error.Message, "not_found")
+	})
+}
@@ -6,10 +6,155 @@ type ListTagsResult struct {
 	TotalCount int      `json:"totalCount"`
 	NextCursor string   `json:"nextCursor,omitempty"`
 	Sort       string   `json:"sort"`
+	// Partial is set when the call timed out while listing, so only the tags
+	// read by then are included and counted.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// ListRepositoriesResult is the structured result for the list_repositories tool.
+type ListRepositoriesResult struct {
+	Registry     string   `json:"registry"`
+	Repositories []string `json:"repositories"`
+	TotalCount   int      `json:"totalCount"`
+	NextCursor   string   `json:"nextCursor,omitempty"`
+	// Partial is set when the call timed out while listing, so only the
+	// repositories read by then are included and counted.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// ResolvedReference is the resolution of a single reference by the
+// resolve_reference tool. Error is set instead of the digest fields when the
+// reference could not be resolved.
+type ResolvedReference struct {
+	Reference  string `json:"reference"`
+	Registry   string `json:"registry,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	MediaType  string `json:"mediaType,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	// ServedBy is the registry that answered, which is a mirror when one is
+	// configured for the reference's registry and has the content.
+	ServedBy string `json:"servedBy,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// ErrorCategory classifies Error like ErrorResult.Category.
+	ErrorCategory string `json:"errorCategory,omitempty"`
+}
+
+// ResolveReferenceResult is the structured result for the resolve_reference tool.
+type ResolveReferenceResult struct {
+	References []ResolvedReference `json:"references"`
+	Failed     int                 `json:"failed"`
+}
+
+// DigestTagMatch is a tag found by the find_tags_by_digest tool. Digest is the
+// digest the tag itself resolves to, which differs from the searched digest for
+// index-child matches.
+type DigestTagMatch struct {
+	Tag       string `json:"tag"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Match     string `json:"match"`
+	Platform  string `json:"platform,omitempty"`
+}
+
+// FindTagsByDigestResult is the structured result for the find_tags_by_digest tool.
+type FindTagsByDigestResult struct {
+	Repository string           `json:"repository"`
+	Digest     string           `json:"digest"`
+	Tags       []DigestTagMatch `json:"tags"`
+	// ScannedTags counts the tags that were resolved and compared.
+	ScannedTags int `json:"scannedTags"`
+	// FailedTags lists the tags that could not be resolved.
+	FailedTags []string `json:"failedTags,omitempty"`
+	// SkippedTags counts the tags past the scan limit that were not resolved.
+	SkippedTags int `json:"skippedTags,omitempty"`
+	// UnscannedTags lists the tags that were not resolved before the call
+	// timed out.
+	UnscannedTags []string `json:"unscannedTags,omitempty"`
+	// Partial is set when the call timed out while listing or scanning tags,
+	// so tags that were not listed, or are in UnscannedTags, may also match.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// ReferenceDefaults reports which defaults were applied while parsing a reference.
+type ReferenceDefaults struct {
+	Registry  bool `json:"registry"`
+	Namespace bool `json:"namespace"`
+	Tag       bool `json:"tag"`
+}
+
+// ReferenceSuggestion is a suggested correction for an invalid reference.
+type ReferenceSuggestion struct {
+	Reference string `json:"reference,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// ParseReferenceResult is the structured result for the parse_reference tool.
+type ParseReferenceResult struct {
+	Input           string                `json:"input"`
+	Valid           bool                  `json:"valid"`
+	Strict          bool                  `json:"strict"`
+	FullyQualified  string                `json:"fullyQualified,omitempty"`
+	Registry        string                `json:"registry,omitempty"`
+	Repository      string                `json:"repository,omitempty"`
+	Tag             string                `json:"tag,omitempty"`
+	Digest          string                `json:"digest,omitempty"`
+	Alias           string                `json:"alias,omitempty"`
+	DefaultsApplied ReferenceDefaults     `json:"defaultsApplied"`
+	Error           string                `json:"error,omitempty"`
+	Suggestions     []ReferenceSuggestion `json:"suggestions,omitempty"`
+}
+
+// RateLimitResult is the structured result for the get_docker_hub_rate_limit tool.
+type RateLimitResult struct {
+	Registry string `json:"registry"`
+	// Limited is false when the registry reports no pull limit for the credentials.
+	Limited       bool   `json:"limited"`
+	Limit         int    `json:"limit,omitempty"`
+	Remaining     int    `json:"remaining,omitempty"`
+	WindowSeconds int    `json:"windowSeconds,omitempty"`
+	Source        string `json:"source,omitempty"`
+}
+
+// ProbeRegistryResult is the structured result for the probe_registry tool.
+// Referrers and Catalog are one of supported, unsupported, unknown or
+// not_checked.
+type ProbeRegistryResult struct {
+	Registry        string   `json:"registry"`
+	APIVersion      string   `json:"apiVersion,omitempty"`
+	AuthScheme      string   `json:"authScheme"`
+	Realm           string   `json:"realm,omitempty"`
+	Service         string   `json:"service,omitempty"`
+	AnonymousAccess bool     `json:"anonymousAccess"`
+	Referrers       string   `json:"referrers"`
+	Catalog         string   `json:"catalog"`
+	Notes           []string `json:"notes,omitempty"`
+}
+
+// ErrorResult is the structured payload returned with tool errors so clients
+// can decide how to react without parsing the message.
+type ErrorResult struct {
+	Error string `json:"error"`
+	// Category is one of not_found, unauthorized, denied, rate_limited,
+	// timeout, invalid_argument, unsupported, unavailable or unknown.
+	Category string `json:"category"`
+	// Code is the error code reported by the registry, e.g. MANIFEST_UNKNOWN.
+	Code string `json:"code,omitempty"`
+	// StatusCode is the HTTP status of the failed registry response.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Retryable reports whether repeating the same call may succeed.
+	Retryable bool `json:"retryable"`
+	// RetryAfterSeconds is the delay the registry asked for before retrying.
+	RetryAfterSeconds int `json:"retryAfterSeconds,omitempty"`
+	// Suggestions are existing references or repositories close to one that
+	// was not found.
+	Suggestions []string `json:"suggestions,omitempty"`
 }
 
 // ImageInfoResult is the structured result for the get_image_info tool.
 type ImageInfoResult struct {
+	Reference    string `json:"reference"`
 	Digest       string `json:"digest"`
 	Size         int64  `json:"size"`
 	Architecture string `json:"architecture"`
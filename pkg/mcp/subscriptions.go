@@ -0,0 +1,639 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+const (
+	// defaultWatchInterval is how often subscribed tags are polled when
+	// WatchOptions.Interval is not set.
+	defaultWatchInterval = time.Minute
+
+	// defaultWatchMaxBackoff bounds the delay between polls of a failing tag
+	// when WatchOptions.MaxBackoff is not set.
+	defaultWatchMaxBackoff = 15 * time.Minute
+
+	// defaultMaxWatches bounds the subscriptions of all sessions when
+	// WatchOptions.MaxWatches is not set.
+	defaultMaxWatches = 1024
+
+	// maxWatchesPerSession bounds the subscriptions of a single client.
+	maxWatchesPerSession = 64
+
+	// maxSubscribeMessageBytes bounds the requests inspected for subscriptions;
+	// larger messages are passed through untouched.
+	maxSubscribeMessageBytes = 64 << 10
+
+	// stdioSessionID is the session ID of the stdio server's only client.
+	stdioSessionID = "stdio"
+)
+
+// Methods of the subscription requests, which mcp-go does not route to
+// handlers itself.
+const (
+	methodResourcesSubscribe   = "resources/subscribe"
+	methodResourcesUnsubscribe = "resources/unsubscribe"
+)
+
+// tagResourceTemplates match the resource URIs that name a tag.
+var tagResourceTemplates = []*mcp.URITemplate{
+	mcp.NewResourceTemplate(ManifestResourceTemplate, "").URITemplate,
+	mcp.NewResourceTemplate(ConfigResourceTemplate, "").URITemplate,
+}
+
+// contentResourceTemplate matches the resource URIs that name a digest.
+var contentResourceTemplate = mcp.NewResourceTemplate(ContentResourceTemplate, "").URITemplate
+
+// WatchOptions controls how the tags of subscribed resources are polled.
+type WatchOptions struct {
+	// Interval between polls of a tag. 0 uses 1 minute.
+	Interval time.Duration
+	// Registries overrides Interval per registry host, e.g. to poll a
+	// rate-limited registry less often.
+	Registries map[string]time.Duration
+	// MaxBackoff bounds the delay between polls of a tag whose polls keep
+	// failing; the delay doubles with each failure, or follows a longer
+	// Retry-After from the registry. 0 uses 15 minutes.
+	MaxBackoff time.Duration
+	// MaxWatches bounds the tag subscriptions of all sessions together.
+	// 0 uses 1024.
+	MaxWatches int
+	// OnError, if set, is called with the errors of polls, notifications and
+	// subscription responses, which have no caller to return them to.
+	OnError func(error)
+}
+
+// TagWatcher serves resource subscriptions: it polls the tags of subscribed
+// tag resources in the background and sends notifications/resources/updated
+// to subscribers when a tag's digest changes. Subscriptions to the same tag
+// with the same credentials share a single poll. Digest resources never
+// change, so subscribing to them is accepted without polling.
+//
+// mcp-go answers resources/subscribe with "method not found", so subscription
+// requests must reach the watcher before the server: see Middleware for HTTP
+// transports and FilterStdio for the stdio transport. The server should be
+// created with mcpserver.WithResourceCapabilities(true, ...) to advertise
+// subscriptions, and with hooks passed to AddHooks: only sessions the server
+// registered may subscribe, and their subscriptions end with them.
+type TagWatcher struct {
+	provider *ToolProvider
+	server   *mcpserver.MCPServer
+	opts     WatchOptions
+	now      func() time.Time
+	wake     chan struct{}
+
+	mu sync.Mutex
+	// sessions are the sessions registered with the server.
+	sessions map[string]bool
+	// watches maps each subscription to the poll of its tag.
+	watches map[watchKey]*watch
+	// polls are the watches of subscribed tags, shared by the subscriptions
+	// to the same tag with the same credentials.
+	polls map[pollKey]*watch
+}
+
+// watchKey identifies a subscription of a session to a resource URI.
+type watchKey struct {
+	session string
+	uri     string
+}
+
+// pollKey identifies the poll of a tag with a set of credentials.
+type pollKey struct {
+	credentials string
+	tag         string
+}
+
+// watch is the polling state of a subscribed tag.
+type watch struct {
+	key      pollKey
+	backend  oci.ContentReader
+	ref      string
+	registry string
+	// tag is ref as resolved by the backend, matched against webhook events.
+	tag name.Tag
+	// digest is the digest the tag pointed at when last polled, zero if the
+	// tag did not exist.
+	digest   v1.Hash
+	interval time.Duration
+	next     time.Time
+	failures int
+	polling  bool
+	// subscribers are notified when the tag moves.
+	subscribers map[watchKey]struct{}
+}
+
+// NewTagWatcher returns a watcher polling tags with provider's backends and
+// notifying the clients of server. Call Run to start polling.
+func NewTagWatcher(provider *ToolProvider, server *mcpserver.MCPServer, opts WatchOptions) *TagWatcher {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultWatchMaxBackoff
+	}
+	if opts.MaxWatches <= 0 {
+		opts.MaxWatches = defaultMaxWatches
+	}
+	registries := make(map[string]time.Duration, len(opts.Registries))
+	for host, interval := range opts.Registries {
+		registries[registryKey(host)] = interval
+	}
+	opts.Registries = registries
+
+	return &TagWatcher{
+		provider: provider,
+		server:   server,
+		opts:     opts,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		sessions: map[string]bool{},
+		watches:  map[watchKey]*watch{},
+		polls:    map[pollKey]*watch{},
+	}
+}
+
+// AddHooks registers hooks tracking the sessions of the server, which may
+// subscribe until they are closed.
+func (w *TagWatcher) AddHooks(hooks *mcpserver.Hooks) {
+	hooks.AddOnRegisterSession(func(_ context.Context, session mcpserver.ClientSession) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.sessions[session.SessionID()] = true
+	})
+	hooks.AddOnUnregisterSession(func(_ context.Context, session mcpserver.ClientSession) {
+		w.removeSession(session.SessionID())
+	})
+}
+
+// Subscribe subscribes session to the resource uri, authenticating polls with
+// the credentials in header like tool calls of the session. The tag's current
+// digest is resolved first, so later changes are detected.
+func (w *TagWatcher) Subscribe(ctx context.Context, session, uri string, header http.Header) error {
+	if !w.knownSession(session) {
+		return fmt.Errorf("cannot subscribe to %q: unknown session %q", uri, session)
+	}
+	if contentResourceTemplate.Regexp().MatchString(uri) {
+		return nil
+	}
+	ref, ok := tagResourceReference(uri)
+	if !ok {
+		return fmt.Errorf("cannot subscribe to %q: not an oci:// tag or digest resource", uri)
+	}
+
+	backend := w.provider.backendFor(header)
+	parsed, err := backend.ParseReference(ref)
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to %q: invalid reference %q", uri, ref)
+	}
+	registry := parsed.Context().RegistryStr()
+	reqCtx, cancel := w.provider.resourceContext(ctx, registry)
+	defer cancel()
+	digest, err := w.resolve(reqCtx, backend, ref)
+	if err != nil {
+		return resourceError(reqCtx, uri, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.sessions[session] {
+		return fmt.Errorf("cannot subscribe to %q: session %q closed", uri, session)
+	}
+	key := watchKey{session: session, uri: uri}
+	if _, ok := w.watches[key]; ok {
+		w.unsubscribe(key)
+	} else if w.sessionWatches(session) >= maxWatchesPerSession {
+		return fmt.Errorf("cannot subscribe to %q: at most %d subscriptions per session", uri, maxWatchesPerSession)
+	} else if len(w.watches) >= w.opts.MaxWatches {
+		return fmt.Errorf("cannot subscribe to %q: the server has reached its limit of %d subscriptions",
+			uri, w.opts.MaxWatches)
+	}
+
+	pk := pollKey{credentials: credentialsKey(w.provider, header), tag: parsed.Name()}
+	wt, ok := w.polls[pk]
+	if !ok {
+		interval := w.interval(registry)
+		wt = &watch{
+			key:         pk,
+			backend:     backend,
+			ref:         ref,
+			registry:    registry,
+			tag:         tagOf(parsed),
+			digest:      digest,
+			interval:    interval,
+			next:        w.now().Add(interval),
+			subscribers: map[watchKey]struct{}{},
+		}
+		w.polls[pk] = wt
+	}
+	wt.subscribers[key] = struct{}{}
+	w.watches[key] = wt
+	w.signal()
+	return nil
+}
+
+// credentialsKey identifies the credentials the backends of provider use for
+// a request with header: those of the Authorization header when backends are
+// created per request, and none otherwise.
+func credentialsKey(provider *ToolProvider, header http.Header) string {
+	if provider.clientFactory == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(header.Get("Authorization")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Unsubscribe cancels the subscription of session to uri, if any.
+func (w *TagWatcher) Unsubscribe(session, uri string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.unsubscribe(watchKey{session: session, uri: uri})
+}
+
+// unsubscribe cancels a subscription, dropping the poll of its tag when no
+// other subscription shares it. w.mu must be held.
+func (w *TagWatcher) unsubscribe(key watchKey) {
+	wt, ok := w.watches[key]
+	if !ok {
+		return
+	}
+	delete(w.watches, key)
+	delete(wt.subscribers, key)
+	if len(wt.subscribers) == 0 && w.polls[wt.key] == wt {
+		delete(w.polls, wt.key)
+	}
+}
+
+// knownSession reports whether the server registered session.
+func (w *TagWatcher) knownSession(session string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sessions[session]
+}
+
+// removeSession forgets session and cancels its subscriptions.
+func (w *TagWatcher) removeSession(session string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.sessions, session)
+	for key := range w.watches {
+		if key.session == session {
+			w.unsubscribe(key)
+		}
+	}
+}
+
+// sessionWatches counts the subscriptions of session. w.mu must be held.
+func (w *TagWatcher) sessionWatches(session string) int {
+	n := 0
+	for key := range w.watches {
+		if key.session == session {
+			n++
+		}
+	}
+	return n
+}
+
+// interval returns the poll interval of a registry host.
+func (w *TagWatcher) interval(registry string) time.Duration {
+	if interval, ok := w.opts.Registries[registryKey(registry)]; ok {
+		return interval
+	}
+	return w.opts.Interval
+}
+
+// signal wakes Run to reschedule polls.
+func (w *TagWatcher) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls subscribed tags when they are due until ctx is done.
+func (w *TagWatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		for _, wt := range w.due() {
+			go w.poll(ctx, wt)
+		}
+		timer.Reset(w.untilNext())
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// due marks the polls that are due as polling and returns them.
+func (w *TagWatcher) due() []*watch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	var due []*watch
+	for _, wt := range w.polls {
+		if !wt.polling && !now.Before(wt.next) {
+			wt.polling = true
+			due = append(due, wt)
+		}
+	}
+	return due
+}
+
+// untilNext returns the time until the next poll is due, at most the
+// shortest configured interval so new subscriptions are picked up.
+func (w *TagWatcher) untilNext() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wait := w.opts.Interval
+	now := w.now()
+	for _, wt := range w.polls {
+		if !wt.polling {
+			wait = min(wait, max(wt.next.Sub(now), 0))
+		}
+	}
+	return wait
+}
+
+// poll resolves the tag of a watch and notifies its subscribers if its digest
+// changed, backing off after failures.
+func (w *TagWatcher) poll(ctx context.Context, wt *watch) {
+	defer w.signal()
+	reqCtx, cancel := w.provider.resourceContext(ctx, wt.registry)
+	defer cancel()
+	reqCtx, trace := oci.WithTrace(reqCtx)
+	digest, err := w.resolve(reqCtx, wt.backend, wt.ref)
+
+	w.mu.Lock()
+	wt.polling = false
+	if w.polls[wt.key] != wt {
+		w.mu.Unlock()
+		return
+	}
+	if err != nil {
+		wt.failures++
+		// A registry's Retry-After can lengthen the backoff, but not past
+		// MaxBackoff.
+		delay := min(max(wt.interval<<min(wt.failures, 16), trace.RetryAfter()), w.opts.MaxBackoff)
+		wt.next = w.now().Add(delay)
+		w.mu.Unlock()
+		w.reportError(fmt.Errorf("watching %s, retrying in %s: %w", wt.ref, delay, err))
+		return
+	}
+	wt.failures = 0
+	wt.next = w.now().Add(wt.interval)
+	var changed []watchKey
+	if digest != wt.digest {
+		wt.digest = digest
+		changed = wt.subscriberKeys()
+	}
+	w.mu.Unlock()
+
+	for _, key := range changed {
+		w.notify(key)
+	}
+}
+
+// subscriberKeys returns the subscriptions sharing wt. The watcher's mutex
+// must be held.
+func (wt *watch) subscriberKeys() []watchKey {
+	keys := make([]watchKey, 0, len(wt.subscribers))
+	for key := range wt.subscribers {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// applyEvent updates the watches of the tag a registry webhook reported as
+// pushed or deleted, notifying the subscribers of a tag that moved without
+// waiting for its next poll. A manifest deleted by digest removes the tags
+// pointing at it.
+func (w *TagWatcher) applyEvent(ev oci.RegistryEvent) {
+	var changed []watchKey
+	w.mu.Lock()
+	for _, wt := range w.polls {
+		if wt.tag.Context().Name() != ev.Repository.Name() {
+			continue
+		}
+		var digest v1.Hash
+		switch {
+		case ev.Tag != "" && ev.Tag == wt.tag.TagStr():
+			if ev.Action == oci.EventPush {
+				digest = ev.Digest
+			}
+		case ev.Tag == "" && ev.Action == oci.EventDelete && ev.Digest == wt.digest:
+		default:
+			continue
+		}
+		if ev.Action == oci.EventPush && digest == (v1.Hash{}) {
+			// Without a digest the tag must be resolved, so poll it now.
+			wt.next = w.now()
+			continue
+		}
+		wt.failures = 0
+		wt.next = w.now().Add(wt.interval)
+		if digest != wt.digest {
+			wt.digest = digest
+			changed = append(changed, wt.subscriberKeys()...)
+		}
+	}
+	w.mu.Unlock()
+	w.signal()
+
+	for _, key := range changed {
+		w.notify(key)
+	}
+}
+
+// tagOf returns ref if it is a tag, and the zero tag otherwise.
+func tagOf(ref name.Reference) name.Tag {
+	tag, _ := ref.(name.Tag)
+	return tag
+}
+
+// notify sends notifications/resources/updated for a subscription, dropping
+// it if its session is gone.
+func (w *TagWatcher) notify(key watchKey) {
+	err := w.server.SendNotificationToSpecificClient(key.session, mcp.MethodNotificationResourceUpdated,
+		map[string]any{"uri": key.uri})
+	if errors.Is(err, mcpserver.ErrSessionNotFound) {
+		w.removeSession(key.session)
+	} else if err != nil {
+		w.reportError(fmt.Errorf("notifying %s of %s: %w", key.session, key.uri, err))
+	}
+}
+
+// reportError passes err to WatchOptions.OnError, if set.
+func (w *TagWatcher) reportError(err error) {
+	if w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+}
+
+// resolve returns the digest ref points at, or a zero digest if it does not
+// exist. Tags are always resolved from the registry, bypassing the tag cache.
+func (w *TagWatcher) resolve(ctx context.Context, backend oci.ContentReader, ref string) (v1.Hash, error) {
+	_, digest, err := oci.ResolveDigest(oci.WithNoCache(ctx), backend, ref)
+	if oci.IsNotFound(err) {
+		return v1.Hash{}, nil
+	}
+	return digest, err
+}
+
+// tagResourceReference returns the image reference of a tag resource URI.
+func tagResourceReference(uri string) (string, bool) {
+	for _, template := range tagResourceTemplates {
+		if template.Regexp().MatchString(uri) {
+			values := template.Match(uri)
+			return values.Get("repository").String() + ":" + values.Get("tag").String(), true
+		}
+	}
+	return "", false
+}
+
+// HandleMessage answers a JSON-RPC resources/subscribe or
+// resources/unsubscribe request of session. It reports false for any other
+// message, which should be passed on to the server.
+func (w *TagWatcher) HandleMessage(
+	ctx context.Context, session string, header http.Header, message []byte,
+) (mcp.JSONRPCMessage, bool) {
+	var req struct {
+		ID     any    `json:"id"`
+		Method string `json:"method"`
+		Params struct {
+			URI string `json:"uri"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(message, &req) != nil || req.ID == nil ||
+		(req.Method != methodResourcesSubscribe && req.Method != methodResourcesUnsubscribe) {
+		return nil, false
+	}
+
+	id := mcp.NewRequestId(req.ID)
+	if req.Params.URI == "" {
+		return mcp.NewJSONRPCError(id, mcp.INVALID_PARAMS, "uri is required", nil), true
+	}
+	if req.Method == methodResourcesUnsubscribe {
+		w.Unsubscribe(session, req.Params.URI)
+		return mcp.NewJSONRPCResultResponse(id, mcp.EmptyResult{}), true
+	}
+	if err := w.Subscribe(ctx, session, req.Params.URI, header); err != nil {
+		return mcp.NewJSONRPCError(id, mcp.INVALID_PARAMS, err.Error(), nil), true
+	}
+	return mcp.NewJSONRPCResultResponse(id, mcp.EmptyResult{}), true
+}
+
+// Middleware answers subscription requests posted to a streamable HTTP
+// server, identifying sessions by their Mcp-Session-Id header, and passes
+// every other request on to next.
+func (w *TagWatcher) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		peek, err := io.ReadAll(io.LimitReader(r.Body, maxSubscribeMessageBytes))
+		if err != nil {
+			http.Error(rw, "reading request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(peek), r.Body), r.Body}
+		if len(peek) == maxSubscribeMessageBytes {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		response, ok := w.HandleMessage(r.Context(), r.Header.Get(mcpserver.HeaderKeySessionID), r.Header, peek)
+		if !ok {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(response); err != nil {
+			w.reportError(fmt.Errorf("writing subscription response: %w", err))
+		}
+	})
+}
+
+// FilterStdio returns the input and output streams for a stdio server, with
+// subscription requests read from stdin answered by the watcher instead of
+// being passed on. Responses are written to stdout between the server's
+// messages, never within them.
+func (w *TagWatcher) FilterStdio(ctx context.Context, stdin io.Reader, stdout io.Writer) (io.Reader, io.Writer) {
+	out := &lockedWriter{w: stdout}
+	pr, pw := io.Pipe()
+	go func() {
+		reader := bufio.NewReader(stdin)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				if werr := w.filterLine(ctx, line, pw, out); werr != nil {
+					pw.CloseWithError(werr)
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr, out
+}
+
+// filterLine answers a subscription request read from stdin or passes the
+// line on to the server.
+func (w *TagWatcher) filterLine(ctx context.Context, line []byte, server, stdout io.Writer) error {
+	response, ok := w.HandleMessage(ctx, stdioSessionID, nil, line)
+	if !ok {
+		_, err := server.Write(line)
+		return err
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(append(encoded, '\n'))
+	return err
+}
+
+// lockedWriter serializes writes, so each message written with a single Write
+// stays intact.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,333 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// testSession is an initialized client session collecting notifications.
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) SessionID() string { return s.id }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// subscribe sends a resources/subscribe or resources/unsubscribe request to
+// watcher and returns the JSON response.
+func subscribe(t *testing.T, watcher *TagWatcher, session, method, uri string) string {
+	t.Helper()
+	msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":%q,"params":{"uri":%q}}`, method, uri)
+	resp, ok := watcher.HandleMessage(t.Context(), session, nil, []byte(msg))
+	require.True(t, ok)
+	raw, err := json.Marshal(resp)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+// pushRandomImage pushes a new random image to ref and returns its digest.
+func pushRandomImage(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	tag, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return digest
+}
+
+func TestTagWatcher(t *testing.T) {
+	reg := httptest.NewServer(registry.New())
+	t.Cleanup(reg.Close)
+	repo := strings.TrimPrefix(reg.URL, "http://") + "/app"
+	pushRandomImage(t, repo+":stable")
+
+	server, watcher := newTestWatcher(t, NewToolProvider(oci.NewClient()), WatchOptions{Interval: 10 * time.Millisecond})
+	session := registerSession(t, server, "session-1")
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+	go watcher.Run(ctx)
+
+	uri := "oci://" + repo + ":stable/manifest"
+	assert.Equal(t, `{"jsonrpc":"2.0","id":7,"result":{}}`, subscribe(t, watcher, session.id, methodResourcesSubscribe, uri))
+
+	// Nothing is sent while the tag stays put.
+	select {
+	case n := <-session.notifications:
+		t.Fatalf("unexpected notification %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	pushRandomImage(t, repo+":stable")
+	select {
+	case n := <-session.notifications:
+		assert.Equal(t, mcp.MethodNotificationResourceUpdated, n.Method)
+		assert.Equal(t, uri, n.Params.AdditionalFields["uri"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification after the tag moved")
+	}
+
+	t.Run("digest resources are accepted without polling", func(t *testing.T) {
+		digestURI := "oci://" + repo + "@sha256:" + strings.Repeat("a", 64)
+		assert.Contains(t, subscribe(t, watcher, session.id, methodResourcesSubscribe, digestURI), `"result":{}`)
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		assert.NotContains(t, watcher.watches, watchKey{session: session.id, uri: digestURI})
+	})
+
+	t.Run("missing tags are watched until created", func(t *testing.T) {
+		next := "oci://" + repo + ":next/config"
+		assert.Contains(t, subscribe(t, watcher, session.id, methodResourcesSubscribe, next), `"result":{}`)
+		pushRandomImage(t, repo+":next")
+		select {
+		case n := <-session.notifications:
+			assert.Equal(t, next, n.Params.AdditionalFields["uri"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification after the tag was created")
+		}
+	})
+
+	t.Run("invalid resources are rejected", func(t *testing.T) {
+		assert.Contains(t, subscribe(t, watcher, session.id, methodResourcesSubscribe, "https://example.com"),
+			`"code":-32602`)
+		assert.Contains(t, subscribe(t, watcher, session.id, methodResourcesSubscribe, ""), `"code":-32602`)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		assert.Contains(t, subscribe(t, watcher, session.id, methodResourcesUnsubscribe, uri), `"result":{}`)
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		assert.NotContains(t, watcher.watches, watchKey{session: session.id, uri: uri})
+	})
+
+	t.Run("closed sessions are forgotten", func(t *testing.T) {
+		server.UnregisterSession(t.Context(), session.id)
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+		assert.Empty(t, watcher.watches)
+		assert.Empty(t, watcher.polls)
+	})
+}
+
+// newTestWatcher returns a server and a watcher tracking its sessions.
+func newTestWatcher(t *testing.T, provider *ToolProvider, opts WatchOptions) (*mcpserver.MCPServer, *TagWatcher) {
+	t.Helper()
+	hooks := &mcpserver.Hooks{}
+	server := mcpserver.NewMCPServer("test", "0.0.0", mcpserver.WithHooks(hooks))
+	watcher := NewTagWatcher(provider, server, opts)
+	watcher.AddHooks(hooks)
+	return server, watcher
+}
+
+// registerSession registers a session collecting notifications with server.
+func registerSession(t *testing.T, server *mcpserver.MCPServer, id string) *testSession {
+	t.Helper()
+	session := &testSession{id: id, notifications: make(chan mcp.JSONRPCNotification, 4)}
+	require.NoError(t, server.RegisterSession(t.Context(), session))
+	return session
+}
+
+func TestTagWatcher_UnknownSessions(t *testing.T) {
+	server, watcher := newTestWatcher(t, NewToolProvider(oci.NewClient()), WatchOptions{})
+	digestURI := "oci://ghcr.io/org/app@sha256:" + strings.Repeat("a", 64)
+
+	for _, session := range []string{"", "session-1"} {
+		assert.Contains(t, subscribe(t, watcher, session, methodResourcesSubscribe, digestURI), `"code":-32602`)
+	}
+
+	registerSession(t, server, "session-1")
+	assert.Contains(t, subscribe(t, watcher, "session-1", methodResourcesSubscribe, digestURI), `"result":{}`)
+
+	server.UnregisterSession(t.Context(), "session-1")
+	assert.Contains(t, subscribe(t, watcher, "session-1", methodResourcesSubscribe, digestURI), `"code":-32602`)
+}
+
+func TestTagWatcher_SharesPolls(t *testing.T) {
+	reg := httptest.NewServer(registry.New())
+	t.Cleanup(reg.Close)
+	host := strings.TrimPrefix(reg.URL, "http://")
+	pushRandomImage(t, host+"/app:stable")
+
+	factory := func(http.Header) oci.Backend { return oci.NewClient() }
+	server, watcher := newTestWatcher(t, NewToolProviderWithFactory(factory), WatchOptions{
+		Interval:   10 * time.Millisecond,
+		MaxWatches: 3,
+	})
+	sessions := []*testSession{registerSession(t, server, "session-1"), registerSession(t, server, "session-2")}
+
+	subscribeAs := func(session, auth, uri string) string {
+		msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":"resources/subscribe","params":{"uri":%q}}`, uri)
+		resp, ok := watcher.HandleMessage(t.Context(), session, http.Header{"Authorization": {auth}}, []byte(msg))
+		require.True(t, ok)
+		raw, err := json.Marshal(resp)
+		require.NoError(t, err)
+		return string(raw)
+	}
+
+	// Subscriptions to the same tag with the same credentials share a poll,
+	// whichever resource they name.
+	manifest := "oci://" + host + "/app:stable/manifest"
+	config := "oci://" + host + "/app:stable/config"
+	assert.Contains(t, subscribeAs("session-1", "Bearer a", manifest), `"result":{}`)
+	assert.Contains(t, subscribeAs("session-2", "Bearer a", config), `"result":{}`)
+	assert.Contains(t, subscribeAs("session-2", "Bearer b", manifest), `"result":{}`)
+	watcher.mu.Lock()
+	assert.Len(t, watcher.polls, 2)
+	watcher.mu.Unlock()
+
+	// The server's limit applies across sessions.
+	assert.Contains(t, subscribeAs("session-1", "Bearer a", "oci://"+host+"/app:next/manifest"),
+		"limit of 3 subscriptions")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+	go watcher.Run(ctx)
+	pushRandomImage(t, host+"/app:stable")
+	var got []string
+	for len(got) < 3 {
+		select {
+		case n := <-sessions[0].notifications:
+			got = append(got, "session-1 "+n.Params.AdditionalFields["uri"].(string))
+		case n := <-sessions[1].notifications:
+			got = append(got, "session-2 "+n.Params.AdditionalFields["uri"].(string))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notified %v only", got)
+		}
+	}
+	assert.ElementsMatch(t, []string{"session-1 " + manifest, "session-2 " + config, "session-2 " + manifest}, got)
+
+	// A poll ends with the last subscription sharing it.
+	watcher.Unsubscribe("session-1", manifest)
+	watcher.mu.Lock()
+	assert.Len(t, watcher.polls, 2)
+	watcher.mu.Unlock()
+	watcher.Unsubscribe("session-2", config)
+	watcher.mu.Lock()
+	assert.Len(t, watcher.polls, 1)
+	watcher.mu.Unlock()
+}
+
+// failingBackend fails to read any content.
+type failingBackend struct {
+	oci.NameResolver
+}
+
+func (failingBackend) HeadReference(context.Context, string) (name.Reference, *v1.Descriptor, error) {
+	return nil, nil, &transport.Error{StatusCode: http.StatusServiceUnavailable}
+}
+
+func (failingBackend) GetManifest(context.Context, string) ([]byte, v1.Descriptor, error) {
+	return nil, v1.Descriptor{}, &transport.Error{StatusCode: http.StatusServiceUnavailable}
+}
+
+func (failingBackend) GetBlob(context.Context, string, string, int64) ([]byte, error) {
+	return nil, &transport.Error{StatusCode: http.StatusServiceUnavailable}
+}
+
+func TestTagWatcher_Backoff(t *testing.T) {
+	var errs []error
+	watcher := NewTagWatcher(NewToolProvider(oci.NewClient()), mcpserver.NewMCPServer("test", "0.0.0"), WatchOptions{
+		Interval:   time.Minute,
+		Registries: map[string]time.Duration{"docker.io": 5 * time.Minute},
+		MaxBackoff: 5 * time.Minute,
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	now := time.Now()
+	watcher.now = func() time.Time { return now }
+
+	assert.Equal(t, 5*time.Minute, watcher.interval("index.docker.io"))
+	assert.Equal(t, time.Minute, watcher.interval("ghcr.io"))
+
+	wt := &watch{key: pollKey{tag: "ghcr.io/org/app:stable"}, backend: failingBackend{(*oci.Config)(nil)},
+		ref: "ghcr.io/org/app:stable", registry: "ghcr.io", interval: time.Minute}
+	watcher.polls[wt.key] = wt
+
+	for _, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		watcher.poll(t.Context(), wt)
+		assert.Equal(t, want, wt.next.Sub(now))
+	}
+	require.Len(t, errs, 4, "failed polls are reported")
+	assert.Contains(t, errs[0].Error(), "watching ghcr.io/org/app:stable, retrying in 2m0s")
+
+	// A registry's Retry-After lengthens the backoff up to MaxBackoff.
+	retryAfter := "180"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+	limited := &watch{key: pollKey{tag: host + "/app:stable"}, backend: oci.NewBackend(oci.NewClient()),
+		ref: host + "/app:stable", registry: host, interval: time.Minute}
+	watcher.polls[limited.key] = limited
+
+	for _, tt := range []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"180", 3 * time.Minute},
+		{"3600", 5 * time.Minute},
+	} {
+		retryAfter = tt.retryAfter
+		watcher.poll(t.Context(), limited)
+		assert.Equal(t, tt.want, limited.next.Sub(now), "Retry-After %s", tt.retryAfter)
+	}
+}
+
+func TestTagWatcher_Middleware(t *testing.T) {
+	server, watcher := newTestWatcher(t, NewToolProvider(oci.NewClient()), WatchOptions{})
+	registerSession(t, server, "session-1")
+	var passed []string
+	handler := watcher.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		passed = append(passed, string(body))
+	}))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set(mcpserver.HeaderKeySessionID, "session-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	subscribeBody := `{"jsonrpc":"2.0","id":1,"method":"resources/subscribe",` +
+		`"params":{"uri":"oci://ghcr.io/org/app@sha256:` + strings.Repeat("a", 64) + `"}}`
+	rec := post(subscribeBody)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, rec.Body.String())
+	assert.Empty(t, passed)
+
+	// Other messages reach the server with their body intact.
+	toolsList := `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+	post(toolsList)
+	assert.Equal(t, []string{toolsList}, passed)
+}
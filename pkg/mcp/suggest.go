@@ -0,0 +1,41 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// lookupErrorResult builds the error result for a failed registry lookup. When
+// the registry reported the content as not found, suggest is called for
+// nearby alternatives, which are returned both in the structured payload and
+// as a "did you mean" hint in the text.
+func lookupErrorResult(
+	ctx context.Context, message string, err error, suggest func(context.Context) []string,
+) *mcp.CallToolResult {
+	payload := newErrorPayload(ctx, message, err)
+	if payload.Category != string(oci.ErrorCategoryNotFound) {
+		return newStructuredErrorResult(payload)
+	}
+
+	payload.Suggestions = suggest(ctx)
+	result := newStructuredErrorResult(payload)
+	if len(payload.Suggestions) > 0 {
+		result.Content = []mcp.Content{mcp.NewTextContent(fmt.Sprintf(
+			"%s\n\nDid you mean: %s?", payload.Error, strings.Join(payload.Suggestions, ", "),
+		))}
+	}
+	return result
+}
+
+// suggestAlternatives returns a suggest function for lookupErrorResult that
+// proposes tags or repositories close to imageRef.
+func suggestAlternatives(client oci.Backend, imageRef string) func(context.Context) []string {
+	return func(ctx context.Context) []string {
+		return client.SuggestAlternatives(ctx, imageRef)
+	}
+}
@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+func TestGetImageInfo_NotFoundSuggestsTags(t *testing.T) {
+	host := pushRandomImages(t, "app:1.4.0", "app:1.4.1", "app:latest")
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": host + "/app:1.4.2"}
+
+	result, err := provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryNotFound), payload.Category)
+	assert.Equal(t, []string{host + "/app:1.4.0", host + "/app:1.4.1"}, payload.Suggestions)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	require.True(t, ok)
+	assert.Contains(t, textContent.Text, "failed to get image")
+	assert.Contains(t, textContent.Text, "Did you mean: "+host+"/app:1.4.0")
+}
+
+func TestListTags_NotFoundSuggestsRepositories(t *testing.T) {
+	host := pushRandomImages(t, "team/api:v1", "team/web:v1")
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"repository": host + "/team/apj"}
+
+	result, err := provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryNotFound), payload.Category)
+	assert.Equal(t, []string{host + "/team/api"}, payload.Suggestions)
+}
+
+func TestGetImageManifest_NotFoundWithoutSuggestions(t *testing.T) {
+	host := pushRandomImages(t, "app:latest")
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": host + "/app:production"}
+
+	result, err := provider.GetImageManifest(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryNotFound), payload.Category)
+	assert.Empty(t, payload.Suggestions)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	require.True(t, ok)
+	assert.NotContains(t, textContent.Text, "Did you mean")
+}
@@ -0,0 +1,177 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// defaultMaxTimeout bounds the timeout_seconds argument when TimeoutConfig.Max
+// is not set.
+const defaultMaxTimeout = 5 * time.Minute
+
+// timeoutArgument is the optional tool argument overriding the configured
+// timeout for a single call.
+const timeoutArgument = "timeout_seconds"
+
+// noCacheArgument is the optional tool argument bypassing cached tag
+// resolutions and listings for a single call.
+const noCacheArgument = "no_cache"
+
+// TimeoutConfig controls how long a tool call may spend on registry operations.
+type TimeoutConfig struct {
+	// Default applies to tools and registries without a specific timeout.
+	// 0 uses 30 seconds.
+	Default time.Duration
+	// Max bounds the timeout_seconds argument. 0 uses 5 minutes.
+	Max time.Duration
+	// Tools overrides Default per tool name.
+	Tools map[string]time.Duration
+	// Registries extends the timeout of calls to slow registries, keyed by
+	// registry host. A call gets the larger of its tool and registry timeouts.
+	Registries map[string]time.Duration
+}
+
+// WithTimeouts configures the timeouts applied to tool calls.
+func WithTimeouts(cfg TimeoutConfig) ToolProviderOption {
+	return func(p *ToolProvider) {
+		registries := make(map[string]time.Duration, len(cfg.Registries))
+		for host, timeout := range cfg.Registries {
+			registries[registryKey(host)] = timeout
+		}
+		cfg.Registries = registries
+		p.timeouts = cfg
+	}
+}
+
+// ParseTimeouts parses a comma-separated list of key=duration pairs, such as
+// "list_tags=2m,find_tags_by_digest=90s", as used for per-tool and
+// per-registry timeouts.
+func ParseTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid timeout %q: expected key=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q: expected a positive duration such as 90s", entry)
+		}
+		timeouts[key] = timeout
+	}
+	return timeouts, nil
+}
+
+// timeout returns the timeout for a call to tool against registries. A
+// positive number of requested seconds takes precedence, bounded by Max.
+func (c TimeoutConfig) timeout(tool string, registries []string, requestedSeconds float64) time.Duration {
+	if requestedSeconds > 0 {
+		maxTimeout := c.Max
+		if maxTimeout <= 0 {
+			maxTimeout = defaultMaxTimeout
+		}
+		if requestedSeconds >= maxTimeout.Seconds() {
+			return maxTimeout
+		}
+		return time.Duration(requestedSeconds * float64(time.Second))
+	}
+
+	timeout := c.Default
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if t, ok := c.Tools[tool]; ok {
+		timeout = t
+	}
+	for _, registry := range registries {
+		if t, ok := c.Registries[registry]; ok && t > timeout {
+			timeout = t
+		}
+	}
+	return timeout
+}
+
+// registryKey normalises a registry host so "docker.io" and "index.docker.io"
+// share a timeout. Unparseable hosts are returned unchanged.
+func registryKey(host string) string {
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return host
+	}
+	return reg.RegistryStr()
+}
+
+// callContext prepares the context for a tool call's registry operations
+// against the given registry hosts: it applies the call's timeout, retry
+// budget and no_cache argument and attaches a trace so results can report how
+// requests were served.
+func (p *ToolProvider) callContext(
+	ctx context.Context, req mcp.CallToolRequest, registries ...string,
+) (context.Context, *oci.Trace, context.CancelFunc) {
+	timeout := p.timeouts.timeout(req.Params.Name, registries, mcp.ParseFloat64(req, timeoutArgument, 0))
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	reqCtx = oci.WithRetryBudget(reqCtx, toolRetryBudget)
+	if mcp.ParseBoolean(req, noCacheArgument, false) {
+		reqCtx = oci.WithNoCache(reqCtx)
+	}
+	reqCtx, trace := oci.WithTrace(reqCtx)
+	return reqCtx, trace, cancel
+}
+
+// withTimeoutArgument adds the timeout_seconds argument to a tool that calls registries.
+func withTimeoutArgument() mcp.ToolOption {
+	return mcp.WithNumber(timeoutArgument,
+		mcp.Description("Maximum seconds to spend on registry requests, "+
+			"overriding the server's timeout for this call up to its configured maximum"),
+	)
+}
+
+// withNoCacheArgument adds the no_cache argument to a tool that resolves or lists tags.
+func withNoCacheArgument() mcp.ToolOption {
+	return mcp.WithBoolean(noCacheArgument,
+		mcp.Description("Resolve and list tags from the registry instead of reusing results "+
+			"cached for a few seconds, e.g. right after pushing a tag"),
+	)
+}
+
+// referenceRegistry returns the registry host an image reference resolves to,
+// or "" if it does not parse.
+func referenceRegistry(client oci.Backend, imageRef string) string {
+	ref, err := client.ParseReference(imageRef)
+	if err != nil {
+		return ""
+	}
+	return ref.Context().RegistryStr()
+}
+
+// repositoryRegistry returns the registry host a repository resolves to, or ""
+// if it does not parse.
+func repositoryRegistry(client oci.Backend, repository string) string {
+	repo, err := client.NewRepository(repository)
+	if err != nil {
+		return ""
+	}
+	return repo.RegistryStr()
+}
+
+// registryHost returns the host of a registry name, or "" if it does not parse.
+func registryHost(client oci.Backend, registry string) string {
+	reg, err := client.NewRegistry(registry)
+	if err != nil {
+		return ""
+	}
+	return reg.RegistryStr()
+}
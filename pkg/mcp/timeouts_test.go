@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts(" list_tags=2m, find_tags_by_digest=90s ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"list_tags":           2 * time.Minute,
+		"find_tags_by_digest": 90 * time.Second,
+	}, timeouts)
+
+	for _, spec := range []string{"list_tags", "=1m", "list_tags=soon", "list_tags=-1s"} {
+		_, err := ParseTimeouts(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestTimeoutConfig_Timeout(t *testing.T) {
+	var provider ToolProvider
+	WithTimeouts(TimeoutConfig{
+		Default:    20 * time.Second,
+		Max:        time.Minute,
+		Tools:      map[string]time.Duration{ListTagsToolName: 45 * time.Second},
+		Registries: map[string]time.Duration{"docker.io": 2 * time.Minute, "fast.example": time.Second},
+	})(&provider)
+	cfg := provider.timeouts
+
+	tests := []struct {
+		name       string
+		tool       string
+		registries []string
+		requested  float64
+		want       time.Duration
+	}{
+		{"default", GetImageInfoToolName, []string{"ghcr.io"}, 0, 20 * time.Second},
+		{"tool override", ListTagsToolName, []string{"ghcr.io"}, 0, 45 * time.Second},
+		{"slow registry wins", ListTagsToolName, []string{"index.docker.io"}, 0, 2 * time.Minute},
+		{"fast registry does not shorten", ListTagsToolName, []string{"fast.example"}, 0, 45 * time.Second},
+		{"slowest of several registries", ResolveReferenceToolName, []string{"ghcr.io", "index.docker.io"}, 0, 2 * time.Minute},
+		{"requested", ListTagsToolName, []string{"index.docker.io"}, 2.5, 2500 * time.Millisecond},
+		{"requested bounded by max", ListTagsToolName, nil, 600, time.Minute},
+		{"non-positive request ignored", ListTagsToolName, nil, -5, 45 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cfg.timeout(tt.tool, tt.registries, tt.requested))
+		})
+	}
+
+	var zero TimeoutConfig
+	assert.Equal(t, defaultTimeout, zero.timeout(GetImageInfoToolName, nil, 0))
+	assert.Equal(t, defaultMaxTimeout, zero.timeout(GetImageInfoToolName, nil, 3600))
+}
+
+func TestListTags_PartialOnTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/app/tags/list?n=2&last=v2>; rel="next"`)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"app","tags":["v2","v1"]}`))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	provider := NewToolProvider(oci.NewClient())
+	req := mcp.CallToolRequest{}
+	req.Params.Name = ListTagsToolName
+	req.Params.Arguments = map[string]interface{}{
+		"repository":      strings.TrimPrefix(server.URL, "http://") + "/app",
+		"timeout_seconds": 0.2,
+	}
+
+	result, err := provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	tags, ok := result.StructuredContent.(ListTagsResult)
+	require.True(t, ok)
+	assert.True(t, tags.Partial)
+	assert.Equal(t, []string{"v1", "v2"}, tags.Tags)
+	assert.Equal(t, 2, tags.TotalCount)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "partial")
+}
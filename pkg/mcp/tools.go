@@ -5,13 +5,14 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
 
 	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
 )
@@ -45,190 +46,440 @@ const (
 	GetImageConfigToolName     = "get_image_config"
 	ListReferrersToolName      = "list_referrers"
 	GetReferrerContentToolName = "get_referrer_content"
+	ListRepositoriesToolName   = "list_repositories"
+	ResolveReferenceToolName   = "resolve_reference"
+	FindTagsByDigestToolName   = "find_tags_by_digest"
+	ParseReferenceToolName     = "parse_reference"
+	DockerHubRateLimitToolName = "get_docker_hub_rate_limit"
+	ProbeRegistryToolName      = "probe_registry"
 )
 
-// ClientFactory is a function that creates an OCI client from HTTP headers
-type ClientFactory func(http.Header) *oci.Client
+// ClientFactory is a function that creates the backend serving a request from
+// its HTTP headers, typically an *oci.Client authenticated as the caller.
+type ClientFactory func(http.Header) oci.Backend
 
 // ToolProvider provides MCP tools for OCI registry operations.
 type ToolProvider struct {
-	client        *oci.Client
+	client        oci.Backend
 	clientFactory ClientFactory
+	decorate      func(oci.Backend) oci.Backend
+	// names parses references without building a backend, see WithNameResolver.
+	names     oci.NameResolver
+	cursorKey []byte
+	cursors   *cursorCodec
+	timeouts  TimeoutConfig
+	// resourceMaxBytes limits the size of resource contents.
+	resourceMaxBytes int64
 }
 
-// NewToolProvider creates a new ToolProvider.
-func NewToolProvider(client *oci.Client) *ToolProvider {
-	return &ToolProvider{
-		client: client,
+// ToolProviderOption configures optional ToolProvider behaviour.
+type ToolProviderOption func(*ToolProvider)
+
+// WithCursorKey sets the secret used to sign pagination cursors. Servers running
+// several replicas behind a load balancer should share the same key; otherwise a
+// random per-process key is used.
+func WithCursorKey(key []byte) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.cursorKey = key
+	}
+}
+
+// WithBackendDecorator wraps the backend serving each request, for example to
+// add caching, auditing or access control around the registry operations.
+// Decorators added by several options are applied in order, the last one
+// outermost.
+func WithBackendDecorator(decorate func(oci.Backend) oci.Backend) ToolProviderOption {
+	return func(p *ToolProvider) {
+		if inner := p.decorate; inner != nil {
+			p.decorate = func(b oci.Backend) oci.Backend { return decorate(inner(b)) }
+			return
+		}
+		p.decorate = decorate
 	}
 }
 
+// WithNameResolver sets how parse_reference resolves names, typically the
+// *oci.Config the client factory creates clients with, so parsing does not
+// create a backend for the caller. When unset, the request's backend is used.
+func WithNameResolver(names oci.NameResolver) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.names = names
+	}
+}
+
+// NewToolProvider creates a new ToolProvider serving every request from
+// client, an *oci.Client or any other oci.Backend.
+func NewToolProvider(client oci.Backend, opts ...ToolProviderOption) *ToolProvider {
+	return newToolProvider(&ToolProvider{
+		client: client,
+	}, opts)
+}
+
 // NewToolProviderWithFactory creates a new ToolProvider with a custom client factory.
 // The factory will be used to create clients per-request based on HTTP headers.
-func NewToolProviderWithFactory(clientFactory ClientFactory) *ToolProvider {
-	return &ToolProvider{
+func NewToolProviderWithFactory(clientFactory ClientFactory, opts ...ToolProviderOption) *ToolProvider {
+	return newToolProvider(&ToolProvider{
 		clientFactory: clientFactory,
+	}, opts)
+}
+
+// newToolProvider applies options and initializes derived state.
+func newToolProvider(p *ToolProvider, opts []ToolProviderOption) *ToolProvider {
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.cursors = newCursorCodec(p.cursorKey)
+	return p
 }
 
-// getClient returns the appropriate OCI client for the request.
-// If a client factory is configured, it creates a new client from the request headers.
-// Otherwise, it uses the default client.
-func (p *ToolProvider) getClient(req mcp.CallToolRequest) *oci.Client {
-	if p.clientFactory != nil {
-		return p.clientFactory(req.Header)
+// nameResolver returns how names of the request are resolved.
+func (p *ToolProvider) nameResolver(req mcp.CallToolRequest) oci.NameResolver {
+	if p.names != nil {
+		return p.names
 	}
-	return p.client
+	return p.getClient(req)
 }
 
-// GetTools returns the list of tools provided by this MCP server.
-func (*ToolProvider) GetTools() []mcp.Tool {
-	return []mcp.Tool{
-		mcp.NewTool(
-			GetImageInfoToolName,
-			mcp.WithDescription("Get information about an OCI image"),
-			mcp.WithString("image_ref",
-				mcp.Description("The image reference (e.g., docker.io/library/alpine:latest)"),
-				mcp.Required(),
-			),
-			mcp.WithOutputSchema[ImageInfoResult](),
-			mcp.WithReadOnlyHintAnnotation(true),
-			mcp.WithDestructiveHintAnnotation(false),
-			mcp.WithOpenWorldHintAnnotation(true),
-		),
-		mcp.NewTool(
-			ListTagsToolName,
-			mcp.WithDescription("List tags for a repository with pagination support"),
-			mcp.WithString("repository",
-				mcp.Description("The repository name (e.g., docker.io/library/alpine)"),
-				mcp.Required(),
-			),
-			mcp.WithNumber("limit",
-				mcp.Description("Maximum number of tags to return per page (default: 100, max: 1000)"),
+// getClient returns the appropriate backend for the request.
+// If a client factory is configured, it creates a new backend from the request headers.
+// Otherwise, it uses the default backend. Either is wrapped by the configured decorators.
+func (p *ToolProvider) getClient(req mcp.CallToolRequest) oci.Backend {
+	return p.backendFor(req.Header)
+}
+
+// ServerTools returns the tools provided by this MCP server, each with the
+// handler serving it. Register adds them to a server; embedders can also add
+// them to a server alongside their own tools.
+func (p *ToolProvider) ServerTools() []mcpserver.ServerTool {
+	return []mcpserver.ServerTool{
+		{
+			Tool: mcp.NewTool(
+				GetImageInfoToolName,
+				mcp.WithDescription("Get information about an OCI image"),
+				mcp.WithString("image_ref",
+					mcp.Description("The image reference (e.g., docker.io/library/alpine:latest)"),
+					mcp.Required(),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithOutputSchema[ImageInfoResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithString("cursor",
-				mcp.Description("Opaque pagination cursor from a previous list_tags response"),
+			Handler: p.GetImageInfo,
+		},
+		{
+			Tool: mcp.NewTool(
+				ListTagsToolName,
+				mcp.WithDescription("List tags for a repository with pagination support"),
+				mcp.WithString("repository",
+					mcp.Description("The repository name (e.g., docker.io/library/alpine)"),
+					mcp.Required(),
+				),
+				mcp.WithNumber("limit",
+					mcp.Description("Maximum number of tags to return per page (default: 100, max: 1000)"),
+				),
+				mcp.WithString("cursor",
+					mcp.Description("Opaque pagination cursor from a previous list_tags response"),
+				),
+				mcp.WithString("sort",
+					mcp.Description("Sort order for tags"),
+					mcp.Enum("alphabetical", "alphabetical-desc", "semver", "semver-desc"),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithOutputSchema[ListTagsResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithString("sort",
-				mcp.Description("Sort order for tags"),
-				mcp.Enum("alphabetical", "alphabetical-desc", "semver", "semver-desc"),
+			Handler: p.ListTags,
+		},
+		{
+			Tool: mcp.NewTool(
+				GetImageManifestToolName,
+				mcp.WithDescription("Get the manifest for an OCI image"),
+				mcp.WithString("image_ref",
+					mcp.Description("The image reference (e.g., docker.io/library/alpine:latest)"),
+					mcp.Required(),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithOutputSchema[ListTagsResult](),
-			mcp.WithReadOnlyHintAnnotation(true),
-			mcp.WithDestructiveHintAnnotation(false),
-			mcp.WithOpenWorldHintAnnotation(true),
-		),
-		mcp.NewTool(
-			GetImageManifestToolName,
-			mcp.WithDescription("Get the manifest for an OCI image"),
-			mcp.WithString("image_ref",
-				mcp.Description("The image reference (e.g., docker.io/library/alpine:latest)"),
-				mcp.Required(),
+			Handler: p.GetImageManifest,
+		},
+		{
+			Tool: mcp.NewTool(
+				GetImageConfigToolName,
+				mcp.WithDescription("Get the config for an OCI image"),
+				mcp.WithString("image_ref",
+					mcp.Description("The image reference (e.g., docker.io/library/alpine:latest)"),
+					mcp.Required(),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithReadOnlyHintAnnotation(true),
-			mcp.WithDestructiveHintAnnotation(false),
-			mcp.WithOpenWorldHintAnnotation(true),
-		),
-		mcp.NewTool(
-			GetImageConfigToolName,
-			mcp.WithDescription("Get the config for an OCI image"),
-			mcp.WithString("image_ref",
-				mcp.Description("The image reference (e.g., docker.io/library/alpine:latest)"),
-				mcp.Required(),
+			Handler: p.GetImageConfig,
+		},
+		{
+			Tool: mcp.NewTool(
+				ListReferrersToolName,
+				mcp.WithDescription(
+					"List OCI artifacts (SBOMs, signatures, provenance, VEX) attached to an image via the OCI Referrers API. "+
+						"Returns descriptors with artifact type, digest, size, and annotations. "+
+						"Use this to discover what attestations exist before fetching their content with get_referrer_content."),
+				mcp.WithString("image_ref",
+					mcp.Description("The image reference (e.g., docker.io/library/alpine:latest). Tags are automatically resolved to digests."),
+					mcp.Required(),
+				),
+				mcp.WithString("artifact_type",
+					mcp.Description("Filter referrers by artifact type (e.g., application/vnd.cyclonedx+json)"),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithOutputSchema[ListReferrersResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithReadOnlyHintAnnotation(true),
-			mcp.WithDestructiveHintAnnotation(false),
-			mcp.WithOpenWorldHintAnnotation(true),
-		),
-		mcp.NewTool(
-			ListReferrersToolName,
-			mcp.WithDescription(
-				"List OCI artifacts (SBOMs, signatures, provenance, VEX) attached to an image via the OCI Referrers API. "+
-					"Returns descriptors with artifact type, digest, size, and annotations. "+
-					"Use this to discover what attestations exist before fetching their content with get_referrer_content."),
-			mcp.WithString("image_ref",
-				mcp.Description("The image reference (e.g., docker.io/library/alpine:latest). Tags are automatically resolved to digests."),
-				mcp.Required(),
+			Handler: p.ListReferrers,
+		},
+		{
+			Tool: mcp.NewTool(
+				GetReferrerContentToolName,
+				mcp.WithDescription(
+					"Fetch the content of a specific referrer artifact. "+
+						"Use list_referrers first to discover artifacts and their digests. "+
+						"Returns content as an embedded resource with proper MIME type. "+
+						"For cosign attestations (DSSE envelopes), automatically decodes "+
+						"the base64 payload unless decode_payload is false."),
+				mcp.WithString("image_ref",
+					mcp.Description(
+						"The parent image reference containing the repository "+
+							"(e.g., docker.io/library/alpine:latest)"),
+					mcp.Required(),
+				),
+				mcp.WithString("digest",
+					mcp.Description(
+						"The digest of the referrer artifact from list_referrers "+
+							"(e.g., sha256:abc123...)"),
+					mcp.Required(),
+				),
+				mcp.WithBoolean("decode_payload",
+					mcp.Description(
+						"When true (default), unwrap DSSE envelopes to return "+
+							"the decoded predicate. When false, return raw blob."),
+				),
+				mcp.WithString("content_type",
+					mcp.Description("Hint about the expected content type to help label output metadata"),
+					mcp.Enum("sbom", "provenance", "vex", "signature"),
+				),
+				mcp.WithNumber("max_bytes",
+					mcp.Description("Maximum payload size in bytes. Content exceeding this is truncated. Default 512KB (524288)."),
+				),
+				withTimeoutArgument(),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithString("artifact_type",
-				mcp.Description("Filter referrers by artifact type (e.g., application/vnd.cyclonedx+json)"),
+			Handler: p.GetReferrerContent,
+		},
+		{
+			Tool: mcp.NewTool(
+				ListRepositoriesToolName,
+				mcp.WithDescription(
+					"List repositories in a registry via the /v2/_catalog endpoint with pagination support. "+
+						"Many public registries (e.g., Docker Hub, GHCR) disable the catalog; "+
+						"in that case a catalog_unsupported error is returned."),
+				mcp.WithString("registry",
+					mcp.Description("The registry host (e.g., registry.example.com or localhost:5000)"),
+					mcp.Required(),
+				),
+				mcp.WithString("prefix",
+					mcp.Description("Only return repositories whose name starts with this prefix (e.g., team/)"),
+				),
+				mcp.WithNumber("limit",
+					mcp.Description("Maximum number of repositories to return per page (default: 100, max: 1000)"),
+				),
+				mcp.WithString("cursor",
+					mcp.Description("Opaque pagination cursor from a previous list_repositories response"),
+				),
+				withTimeoutArgument(),
+				mcp.WithOutputSchema[ListRepositoriesResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithOutputSchema[ListReferrersResult](),
-			mcp.WithReadOnlyHintAnnotation(true),
-			mcp.WithDestructiveHintAnnotation(false),
-			mcp.WithIdempotentHintAnnotation(true),
-			mcp.WithOpenWorldHintAnnotation(true),
-		),
-		mcp.NewTool(
-			GetReferrerContentToolName,
-			mcp.WithDescription(
-				"Fetch the content of a specific referrer artifact. "+
-					"Use list_referrers first to discover artifacts and their digests. "+
-					"Returns content as an embedded resource with proper MIME type. "+
-					"For cosign attestations (DSSE envelopes), automatically decodes "+
-					"the base64 payload unless decode_payload is false."),
-			mcp.WithString("image_ref",
-				mcp.Description(
-					"The parent image reference containing the repository "+
-						"(e.g., docker.io/library/alpine:latest)"),
-				mcp.Required(),
+			Handler: p.ListRepositories,
+		},
+		{
+			Tool: mcp.NewTool(
+				ResolveReferenceToolName,
+				mcp.WithDescription(
+					"Resolve one or more image references to their current manifest digest using HEAD requests. "+
+						"Returns registry, repository, tag, digest, media type and size for each reference, "+
+						"with a per-reference error instead of failing the whole batch. "+
+						"Use this for digest pinning and cheap existence checks."),
+				mcp.WithString("image_ref",
+					mcp.Description("A single image reference (e.g., docker.io/library/alpine:latest)"),
+				),
+				mcp.WithArray("image_refs",
+					mcp.Description("Several image references to resolve in one call (max 100)"),
+					mcp.WithStringItems(),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithOutputSchema[ResolveReferenceResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithString("digest",
-				mcp.Description(
-					"The digest of the referrer artifact from list_referrers "+
-						"(e.g., sha256:abc123...)"),
-				mcp.Required(),
+			Handler: p.ResolveReference,
+		},
+		{
+			Tool: mcp.NewTool(
+				FindTagsByDigestToolName,
+				mcp.WithDescription(
+					"Find every tag in a repository that currently resolves to a digest, "+
+						"including tags pointing at a multi-platform index that contains the digest as a platform child. "+
+						"Use this to map a digest from a crash report or deployment back to a release tag. "+
+						"At most 1000 tags are scanned; tags that fail to resolve are listed separately."),
+				mcp.WithString("repository",
+					mcp.Description("The repository to search (e.g., docker.io/library/alpine)"),
+				),
+				mcp.WithString("digest",
+					mcp.Description("The digest to look up (e.g., sha256:abc123...)"),
+				),
+				mcp.WithString("image_ref",
+					mcp.Description(
+						"Alternatively, an image reference such as docker.io/library/alpine@sha256:abc123...; "+
+							"tag references are resolved to their current digest first"),
+				),
+				withTimeoutArgument(),
+				withNoCacheArgument(),
+				mcp.WithOutputSchema[FindTagsByDigestResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithBoolean("decode_payload",
-				mcp.Description(
-					"When true (default), unwrap DSSE envelopes to return "+
-						"the decoded predicate. When false, return raw blob."),
+			Handler: p.FindTagsByDigest,
+		},
+		{
+			Tool: mcp.NewTool(
+				ParseReferenceToolName,
+				mcp.WithDescription(
+					"Parse and normalise an image reference without contacting a registry. "+
+						"Returns the fully-qualified registry, repository, tag and digest, and which defaults "+
+						"(Docker Hub registry, library/ namespace, latest tag) were applied. "+
+						"Invalid references return suggested corrections. Use this to validate references before calling other tools."),
+				mcp.WithString("image_ref",
+					mcp.Description("The image reference to parse (e.g., alpine:3.19)"),
+					mcp.Required(),
+				),
+				mcp.WithBoolean("strict",
+					mcp.Description("When true, require the registry, namespace and tag or digest to be explicit"),
+				),
+				mcp.WithOutputSchema[ParseReferenceResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithIdempotentHintAnnotation(true),
+				mcp.WithOpenWorldHintAnnotation(false),
 			),
-			mcp.WithString("content_type",
-				mcp.Description("Hint about the expected content type to help label output metadata"),
-				mcp.Enum("sbom", "provenance", "vex", "signature"),
+			Handler: p.ParseReference,
+		},
+		{
+			Tool: mcp.NewTool(
+				DockerHubRateLimitToolName,
+				mcp.WithDescription(
+					"Check the Docker Hub pull rate limit for the current credentials: the limit, remaining pulls, "+
+						"window and what the limit is tracked against (IP or account). "+
+						"The check does not count as a pull. Use this before pulling many images from Docker Hub."),
+				withTimeoutArgument(),
+				mcp.WithOutputSchema[RateLimitResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithNumber("max_bytes",
-				mcp.Description("Maximum payload size in bytes. Content exceeding this is truncated. Default 512KB (524288)."),
+			Handler: p.GetDockerHubRateLimit,
+		},
+		{
+			Tool: mcp.NewTool(
+				ProbeRegistryToolName,
+				mcp.WithDescription(
+					"Probe a registry's capabilities: its auth scheme and token realm, whether the repository can be read anonymously, "+
+						"whether the OCI 1.1 Referrers API is supported natively (versus the tag schema fallback) "+
+						"and whether repository catalog listing is enabled. "+
+						"Use this to explain empty list_referrers results or failing list_repositories calls."),
+				mcp.WithString("registry",
+					mcp.Description("The registry to probe (e.g., ghcr.io)"),
+					mcp.Required(),
+				),
+				mcp.WithString("repository",
+					mcp.Description(
+						"An existing repository in the registry (e.g., org/app), "+
+							"needed to check Referrers API support and anonymous pull access"),
+				),
+				withTimeoutArgument(),
+				mcp.WithOutputSchema[ProbeRegistryResult](),
+				mcp.WithReadOnlyHintAnnotation(true),
+				mcp.WithDestructiveHintAnnotation(false),
+				mcp.WithOpenWorldHintAnnotation(true),
 			),
-			mcp.WithReadOnlyHintAnnotation(true),
-			mcp.WithDestructiveHintAnnotation(false),
-			mcp.WithIdempotentHintAnnotation(true),
-			mcp.WithOpenWorldHintAnnotation(true),
-		),
+			Handler: p.ProbeRegistry,
+		},
+	}
+}
+
+// GetTools returns the list of tools provided by this MCP server.
+func (p *ToolProvider) GetTools() []mcp.Tool {
+	serverTools := p.ServerTools()
+	tools := make([]mcp.Tool, len(serverTools))
+	for i, tool := range serverTools {
+		tools[i] = tool.Tool
 	}
+	return tools
 }
 
 // GetImageInfo handles the get_image_info tool.
 func (p *ToolProvider) GetImageInfo(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	imageRef := mcp.ParseString(req, "image_ref", "")
 	if imageRef == "" {
-		return mcp.NewToolResultError("image_ref is required"), nil
+		return invalidArgumentResult("image_ref is required"), nil
 	}
 
 	// Get the appropriate client for this request
 	client := p.getClient(req)
 
-	// Create a context with timeout
-	reqCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	// Create a context with timeout and a trace for result metadata
+	reqCtx, trace, cancel := p.callContext(ctx, req, referenceRegistry(client, imageRef))
 	defer cancel()
 
-	img, err := client.GetImage(reqCtx, imageRef)
+	img, err := oci.GetImage(reqCtx, client, imageRef)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to get image", err), nil
+		return lookupErrorResult(reqCtx, "failed to get image", err, suggestAlternatives(client, imageRef)), nil
 	}
 
 	manifest, err := img.Manifest()
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to get manifest", err), nil
+		return toolErrorResult(reqCtx, "failed to get manifest", err), nil
 	}
 
 	config, err := img.ConfigFile()
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to get config", err), nil
+		return toolErrorResult(reqCtx, "failed to get config", err), nil
 	}
 
 	result := ImageInfoResult{
+		Reference:    qualifiedReference(client, imageRef),
 		Digest:       manifest.Config.Digest.String(),
 		Size:         manifest.Config.Size,
 		Architecture: config.Architecture,
@@ -239,160 +490,290 @@ func (p *ToolProvider) GetImageInfo(ctx context.Context, req mcp.CallToolRequest
 
 	resultJSON, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to marshal result", err), nil
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
 	}
 
-	fallback := fmt.Sprintf("Image information for %s:\n\n```json\n%s\n```", imageRef, string(resultJSON))
-	return mcp.NewToolResultStructured(result, fallback), nil
+	fallback := fmt.Sprintf("Image information for %s:\n\n```json\n%s\n```", result.Reference, string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
 }
 
 // ListTags handles the list_tags tool.
 func (p *ToolProvider) ListTags(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	repository := mcp.ParseString(req, "repository", "")
 	if repository == "" {
-		return mcp.NewToolResultError("repository is required"), nil
+		return invalidArgumentResult("repository is required"), nil
 	}
 
-	// Parse and clamp limit
-	limit := mcp.ParseInt(req, "limit", DefaultPageSize)
-	if limit < 1 {
-		limit = 1
-	}
-	if limit > MaxPageSize {
-		limit = MaxPageSize
-	}
+	limit := parsePageSize(req)
 
 	// Parse sort order
 	sortOrder := mcp.ParseString(req, "sort", SortAlphabetical)
 	if !isValidSortOrder(sortOrder) {
-		return mcp.NewToolResultError(fmt.Sprintf(
+		return invalidArgumentResult(fmt.Sprintf(
 			"invalid sort order %q: must be one of alphabetical, alphabetical-desc, semver, semver-desc",
 			sortOrder,
 		)), nil
 	}
 
-	// Parse cursor
-	var offset int
-	cursorStr := mcp.ParseString(req, "cursor", "")
-	if cursorStr != "" {
-		cursor, err := decodeCursor(cursorStr)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid cursor: %v", err)), nil
-		}
-		if cursor.Sort != sortOrder {
-			return mcp.NewToolResultError(fmt.Sprintf(
-				"sort order mismatch: cursor was created with %q but request specifies %q",
-				cursor.Sort, sortOrder,
-			)), nil
-		}
-		offset = cursor.Offset
-	}
-
 	// Get the appropriate client for this request
 	client := p.getClient(req)
 
-	// Create a context with timeout
-	reqCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	// Parse cursor
+	scope := repositoryScope(client, repository)
+	after, errResult := p.parseCursor(req, scope, "", sortOrder)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	// Create a context with timeout and a trace for result metadata
+	reqCtx, trace, cancel := p.callContext(ctx, req, repositoryRegistry(client, repository))
 	defer cancel()
 
 	tags, err := client.ListTags(reqCtx, repository)
-	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to list tags", err), nil
+	partial := errors.Is(err, oci.ErrIncomplete)
+	if err != nil && !partial {
+		return lookupErrorResult(reqCtx, "failed to list tags", err, func(ctx context.Context) []string {
+			return client.SuggestRepositories(ctx, repository)
+		}), nil
 	}
 
 	if len(tags) == 0 {
-		result := ListTagsResult{Tags: []string{}, TotalCount: 0, Sort: sortOrder}
-		return mcp.NewToolResultStructured(result,
-			fmt.Sprintf("No tags found for repository %s", repository)), nil
+		result := ListTagsResult{Tags: []string{}, TotalCount: 0, Sort: sortOrder, Partial: partial}
+		return withTraceMeta(mcp.NewToolResultStructured(result,
+			fmt.Sprintf("No tags found for repository %s", repository)), trace), nil
 	}
 
-	// Sort
+	// Sort and paginate after the last tag of the previous page
 	sorted := sortTags(tags, sortOrder)
-
-	// Validate offset
-	if offset >= len(sorted) {
-		return mcp.NewToolResultError(fmt.Sprintf(
-			"cursor offset %d is beyond the end of %d tags", offset, len(sorted),
-		)), nil
+	page, hasMore := paginateAfter(sorted, after, limit, tagLess(sortOrder))
+	if page == nil {
+		page = []string{}
 	}
 
-	// Paginate
-	page, nextOffset := paginateTags(sorted, offset, limit)
-
 	// Build result
 	result := ListTagsResult{
 		Tags:       page,
 		TotalCount: len(sorted),
 		Sort:       sortOrder,
+		Partial:    partial,
 	}
-	if nextOffset > 0 {
-		result.NextCursor = encodeCursor(nextOffset, sortOrder)
+	if hasMore {
+		result.NextCursor = p.cursors.encode(listCursor{
+			After: page[len(page)-1],
+			Sort:  sortOrder,
+			Scope: scope,
+		})
 	}
 
 	resultJSON, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to marshal result", err), nil
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
 	}
 
-	fallback := fmt.Sprintf("Tags for %s (showing %d of %d, sorted by %s):\n\n```json\n%s\n```",
-		repository, len(page), len(sorted), sortOrder, string(resultJSON))
-	return mcp.NewToolResultStructured(result, fallback), nil
+	fallback := fmt.Sprintf("Tags for %s (showing %d of %d, sorted by %s%s):\n\n```json\n%s\n```",
+		repository, len(page), len(sorted), sortOrder, partialNote(partial), string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
+}
+
+// partialNote describes a listing cut short by the call's timeout in a
+// result's text.
+func partialNote(partial bool) string {
+	if !partial {
+		return ""
+	}
+	return "; partial, timed out while listing"
+}
+
+// parsePageSize parses the limit argument and clamps it to [1, MaxPageSize].
+func parsePageSize(req mcp.CallToolRequest) int {
+	limit := mcp.ParseInt(req, "limit", DefaultPageSize)
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return limit
+}
+
+// parseCursor decodes the cursor argument, if any, and checks that it was issued
+// for the same scope, filter and sort order. It returns the item to resume after,
+// or an error result if the cursor is invalid.
+func (p *ToolProvider) parseCursor(
+	req mcp.CallToolRequest, scope, filter, sortOrder string,
+) (string, *mcp.CallToolResult) {
+	cursorStr := mcp.ParseString(req, "cursor", "")
+	if cursorStr == "" {
+		return "", nil
+	}
+
+	cursor, err := p.cursors.decode(cursorStr)
+	if err != nil {
+		return "", invalidArgumentResult(err.Error())
+	}
+	if cursor.Scope != scope || cursor.Filter != filter {
+		return "", invalidArgumentResult(fmt.Sprintf(
+			"cursor mismatch: cursor was created for %q (filter %q) but request specifies %q (filter %q)",
+			cursor.Scope, cursor.Filter, scope, filter,
+		))
+	}
+	if cursor.Sort != sortOrder {
+		return "", invalidArgumentResult(fmt.Sprintf(
+			"sort order mismatch: cursor was created with %q but request specifies %q",
+			cursor.Sort, sortOrder,
+		))
+	}
+
+	return cursor.After, nil
+}
+
+// qualifiedReference returns the fully-qualified form of an image reference
+// after aliases and the default registry are applied, so results show which
+// registry was actually queried. Unparseable references are returned unchanged.
+func qualifiedReference(client oci.Backend, imageRef string) string {
+	ref, err := client.ParseReference(imageRef)
+	if err != nil {
+		return imageRef
+	}
+	return ref.Name()
+}
+
+// repositoryScope returns the canonical name of a repository for binding
+// pagination cursors, so "alpine" and "index.docker.io/library/alpine" share
+// cursors. Unparseable names are returned unchanged.
+func repositoryScope(client oci.Backend, repository string) string {
+	repo, err := client.NewRepository(repository)
+	if err != nil {
+		return repository
+	}
+	return repo.Name()
+}
+
+// ListRepositories handles the list_repositories tool.
+func (p *ToolProvider) ListRepositories(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	registry := mcp.ParseString(req, "registry", "")
+	if registry == "" {
+		return invalidArgumentResult("registry is required"), nil
+	}
+
+	prefix := mcp.ParseString(req, "prefix", "")
+	limit := parsePageSize(req)
+
+	client := p.getClient(req)
+
+	scope := registry
+	if reg, err := client.NewRegistry(registry); err == nil {
+		scope = reg.Name()
+	}
+
+	after, errResult := p.parseCursor(req, scope, prefix, SortAlphabetical)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reqCtx, trace, cancel := p.callContext(ctx, req, registryHost(client, registry))
+	defer cancel()
+
+	repos, err := client.ListRepositories(reqCtx, registry)
+	partial := errors.Is(err, oci.ErrIncomplete)
+	if err != nil && !partial {
+		return toolErrorResult(reqCtx, "failed to list repositories", err), nil
+	}
+
+	var matched []string
+	for _, repo := range repos {
+		if strings.HasPrefix(repo, prefix) {
+			matched = append(matched, repo)
+		}
+	}
+
+	sorted := sortTags(matched, SortAlphabetical)
+	page, hasMore := paginateAfter(sorted, after, limit, tagLess(SortAlphabetical))
+	if page == nil {
+		page = []string{}
+	}
+
+	result := ListRepositoriesResult{
+		Registry:     scope,
+		Repositories: page,
+		TotalCount:   len(sorted),
+		Partial:      partial,
+	}
+	if hasMore {
+		result.NextCursor = p.cursors.encode(listCursor{
+			After:  page[len(page)-1],
+			Sort:   SortAlphabetical,
+			Scope:  scope,
+			Filter: prefix,
+		})
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
+	}
+
+	fallback := fmt.Sprintf("Repositories in %s (showing %d of %d%s):\n\n```json\n%s\n```",
+		scope, len(page), len(sorted), partialNote(partial), string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
 }
 
 // GetImageManifest handles the get_image_manifest tool.
 func (p *ToolProvider) GetImageManifest(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	imageRef := mcp.ParseString(req, "image_ref", "")
 	if imageRef == "" {
-		return mcp.NewToolResultError("image_ref is required"), nil
+		return invalidArgumentResult("image_ref is required"), nil
 	}
 
 	// Get the appropriate client for this request
 	client := p.getClient(req)
 
-	// Create a context with timeout
-	reqCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	// Create a context with timeout and a trace for result metadata
+	reqCtx, trace, cancel := p.callContext(ctx, req, referenceRegistry(client, imageRef))
 	defer cancel()
 
-	manifest, err := client.GetImageManifest(reqCtx, imageRef)
+	manifest, err := oci.GetImageManifest(reqCtx, client, imageRef)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to get manifest", err), nil
+		return lookupErrorResult(reqCtx, "failed to get manifest", err, suggestAlternatives(client, imageRef)), nil
 	}
 
 	resultJSON, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to marshal result", err), nil
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
 	}
 
-	fallback := fmt.Sprintf("Manifest for %s:\n\n```json\n%s\n```", imageRef, string(resultJSON))
-	return mcp.NewToolResultStructured(manifest, fallback), nil
+	fallback := fmt.Sprintf("Manifest for %s:\n\n```json\n%s\n```",
+		qualifiedReference(client, imageRef), string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(manifest, fallback), trace), nil
 }
 
 // GetImageConfig handles the get_image_config tool.
 func (p *ToolProvider) GetImageConfig(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	imageRef := mcp.ParseString(req, "image_ref", "")
 	if imageRef == "" {
-		return mcp.NewToolResultError("image_ref is required"), nil
+		return invalidArgumentResult("image_ref is required"), nil
 	}
 
 	// Get the appropriate client for this request
 	client := p.getClient(req)
 
-	// Create a context with timeout
-	reqCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	// Create a context with timeout and a trace for result metadata
+	reqCtx, trace, cancel := p.callContext(ctx, req, referenceRegistry(client, imageRef))
 	defer cancel()
 
-	config, err := client.GetImageConfig(reqCtx, imageRef)
+	config, err := oci.GetImageConfig(reqCtx, client, imageRef)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to get config", err), nil
+		return lookupErrorResult(reqCtx, "failed to get config", err, suggestAlternatives(client, imageRef)), nil
 	}
 
 	resultJSON, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to marshal result", err), nil
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
 	}
 
-	fallback := fmt.Sprintf("Config for %s:\n\n```json\n%s\n```", imageRef, string(resultJSON))
-	return mcp.NewToolResultStructured(config, fallback), nil
+	fallback := fmt.Sprintf("Config for %s:\n\n```json\n%s\n```",
+		qualifiedReference(client, imageRef), string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(config, fallback), trace), nil
 }
 
 // legacyCosignAnnotation is added to referrers discovered via legacy
@@ -405,21 +786,20 @@ func (p *ToolProvider) ListReferrers(
 ) (*mcp.CallToolResult, error) {
 	imageRef := mcp.ParseString(req, "image_ref", "")
 	if imageRef == "" {
-		return mcp.NewToolResultError("image_ref is required"), nil
+		return invalidArgumentResult("image_ref is required"), nil
 	}
 
 	artifactType := mcp.ParseString(req, "artifact_type", "")
 
 	client := p.getClient(req)
 
-	reqCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	reqCtx, trace, cancel := p.callContext(ctx, req, referenceRegistry(client, imageRef))
 	defer cancel()
 
 	indexManifest, err := client.ListReferrers(
 		reqCtx, imageRef, artifactType)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr(
-			"failed to list referrers", err), nil
+		return lookupErrorResult(reqCtx, "failed to list referrers", err, suggestAlternatives(client, imageRef)), nil
 	}
 
 	referrers := make([]ReferrerDescriptor, 0, len(indexManifest.Manifests))
@@ -434,10 +814,9 @@ func (p *ToolProvider) ListReferrers(
 	}
 
 	// Also discover legacy cosign tag artifacts (.sig, .att, .sbom)
-	repo, digest, err := client.ResolveDigest(reqCtx, imageRef)
+	repo, digest, err := oci.ResolveDigest(reqCtx, client, imageRef)
 	if err == nil {
-		legacyArtifacts := client.ListLegacyCosignArtifacts(
-			reqCtx, repo, digest)
+		legacyArtifacts := oci.ListLegacyCosignArtifacts(reqCtx, client, repo, digest)
 		for _, la := range legacyArtifacts {
 			// Skip if artifact_type filter doesn't match
 			if artifactType != "" && la.ArtifactType != artifactType {
@@ -464,11 +843,12 @@ func (p *ToolProvider) ListReferrers(
 
 	resultJSON, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to marshal result", err), nil
+		return toolErrorResult(ctx, "failed to marshal result", err), nil
 	}
 
-	fallback := fmt.Sprintf("Referrers for %s (%d found):\n\n```json\n%s\n```", imageRef, len(referrers), string(resultJSON))
-	return mcp.NewToolResultStructured(result, fallback), nil
+	fallback := fmt.Sprintf("Referrers for %s (%d found):\n\n```json\n%s\n```",
+		qualifiedReference(client, imageRef), len(referrers), string(resultJSON))
+	return withTraceMeta(mcp.NewToolResultStructured(result, fallback), trace), nil
 }
 
 // dsseEnvelope represents a DSSE (Dead Simple Signing Envelope) structure.
@@ -566,12 +946,12 @@ func (p *ToolProvider) GetReferrerContent(
 ) (*mcp.CallToolResult, error) {
 	imageRef := mcp.ParseString(req, "image_ref", "")
 	if imageRef == "" {
-		return mcp.NewToolResultError("image_ref is required"), nil
+		return invalidArgumentResult("image_ref is required"), nil
 	}
 
 	digest := mcp.ParseString(req, "digest", "")
 	if digest == "" {
-		return mcp.NewToolResultError("digest is required"), nil
+		return invalidArgumentResult("digest is required"), nil
 	}
 
 	decodePayload := mcp.ParseBoolean(req, "decode_payload", true)
@@ -582,28 +962,25 @@ func (p *ToolProvider) GetReferrerContent(
 	}
 
 	if contentTypeHint != "" && !validContentTypes[contentTypeHint] {
-		return mcp.NewToolResultError(fmt.Sprintf(
+		return invalidArgumentResult(fmt.Sprintf(
 			"invalid content_type %q: must be one of sbom, provenance, vex, signature",
 			contentTypeHint,
 		)), nil
 	}
 
-	ref, err := name.ParseReference(imageRef)
+	client := p.getClient(req)
+
+	repo, registry, err := oci.RepositoryOf(client, imageRef)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr(
-			"failed to parse image reference", err), nil
+		return toolErrorResult(ctx, "failed to parse image reference", err), nil
 	}
-	repo := ref.Context().String()
 
-	client := p.getClient(req)
-	reqCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	reqCtx, trace, cancel := p.callContext(ctx, req, registry)
 	defer cancel()
 
-	content, layerMediaType, err := client.GetArtifactContent(
-		reqCtx, repo, digest)
+	content, layerMediaType, err := oci.GetArtifactContent(reqCtx, client, repo, digest)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr(
-			"failed to get artifact content", err), nil
+		return toolErrorResult(reqCtx, "failed to get artifact content", err), nil
 	}
 
 	meta := ReferrerContentMetadata{
@@ -642,7 +1019,7 @@ func (p *ToolProvider) GetReferrerContent(
 	outputMIME := detectOutputMIMEType(mimeType, meta.Format)
 	summary := buildContentSummary(repo, digest, meta)
 
-	return &mcp.CallToolResult{
+	return withTraceMeta(&mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.NewTextContent(summary),
 			mcp.NewEmbeddedResource(mcp.TextResourceContents{
@@ -652,7 +1029,7 @@ func (p *ToolProvider) GetReferrerContent(
 			}),
 		},
 		StructuredContent: meta,
-	}, nil
+	}, trace), nil
 }
 
 // detectContentFormat determines the content type and format
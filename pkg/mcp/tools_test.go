@@ -1,10 +1,25 @@
 package mcp
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
-
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -35,6 +50,12 @@ func TestGetTools(t *testing.T) {
 		GetImageConfigToolName,
 		ListReferrersToolName,
 		GetReferrerContentToolName,
+		ListRepositoriesToolName,
+		ResolveReferenceToolName,
+		FindTagsByDigestToolName,
+		ParseReferenceToolName,
+		DockerHubRateLimitToolName,
+		ProbeRegistryToolName,
 	} {
 		assert.True(t, toolNames[expected], "expected tool %q to be present", expected)
 	}
@@ -52,7 +73,9 @@ func TestGetTools_Annotations(t *testing.T) {
 			require.NotNil(t, tool.Annotations.DestructiveHint, "tool %s should have DestructiveHint", tool.Name)
 			assert.False(t, *tool.Annotations.DestructiveHint, "tool %s should not be destructive", tool.Name)
 			require.NotNil(t, tool.Annotations.OpenWorldHint, "tool %s should have OpenWorldHint", tool.Name)
-			assert.True(t, *tool.Annotations.OpenWorldHint, "tool %s should be open-world", tool.Name)
+			// Only parse_reference works without contacting a registry.
+			assert.Equal(t, tool.Name != ParseReferenceToolName, *tool.Annotations.OpenWorldHint,
+				"tool %s has the wrong OpenWorldHint", tool.Name)
 		})
 	}
 }
@@ -131,7 +154,9 @@ func TestListTags_SortCursorMismatch(t *testing.T) {
 	provider := NewToolProvider(oci.NewClient())
 
 	// Create a cursor with alphabetical sort
-	cursor := encodeCursor(0, SortAlphabetical)
+	cursor := provider.cursors.encode(listCursor{
+		After: "v1.0.0", Sort: SortAlphabetical, Scope: repositoryScope(oci.NewClient(), "docker.io/library/alpine"),
+	})
 
 	req := mcp.CallToolRequest{}
 	req.Params.Arguments = map[string]interface{}{
@@ -149,6 +174,50 @@ func TestListTags_SortCursorMismatch(t *testing.T) {
 	assert.Contains(t, textContent.Text, "sort order mismatch")
 }
 
+func TestListTags_CursorRepositoryMismatch(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	cursor := provider.cursors.encode(listCursor{
+		After: "v1.0.0", Sort: SortAlphabetical, Scope: repositoryScope(oci.NewClient(), "docker.io/library/alpine"),
+	})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"repository": "docker.io/library/busybox",
+		"cursor":     cursor,
+	}
+
+	result, err := provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	assert.True(t, ok)
+	assert.Contains(t, textContent.Text, "cursor mismatch")
+}
+
+func TestListTags_ForgedCursor(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	cursor := NewToolProvider(oci.NewClient()).cursors.encode(listCursor{
+		After: "v1.0.0", Sort: SortAlphabetical, Scope: repositoryScope(oci.NewClient(), "docker.io/library/alpine"),
+	})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"repository": "docker.io/library/alpine",
+		"cursor":     cursor,
+	}
+
+	result, err := provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	assert.True(t, ok)
+	assert.Contains(t, textContent.Text, "invalid cursor")
+}
+
 func TestListTags_LimitClamping(t *testing.T) {
 	provider := NewToolProvider(oci.NewClient())
 
@@ -172,6 +241,109 @@ func TestListTags_LimitClamping(t *testing.T) {
 	}
 }
 
+// pushRandomImages starts an in-memory registry and pushes a random image to
+// each of the given repositories, returning the registry host.
+func pushRandomImages(t *testing.T, repos ...string) string {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	for _, repo := range repos {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		ref, err := name.ParseReference(host + "/" + repo)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+
+	return host
+}
+
+func TestListRepositories_MissingRegistry(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	result, err := provider.ListRepositories(t.Context(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	textContent, ok := mcp.AsTextContent(result.Content[0])
+	assert.True(t, ok)
+	assert.Contains(t, textContent.Text, "registry is required")
+}
+
+func TestListRepositories_PrefixAndPagination(t *testing.T) {
+	host := pushRandomImages(t, "team/api:v1", "team/web:v1", "team/worker:v1", "other/db:v1")
+	provider := NewToolProvider(oci.NewClient())
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"registry": host,
+		"prefix":   "team/",
+		"limit":    float64(2),
+	}
+
+	result, err := provider.ListRepositories(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	first, ok := result.StructuredContent.(ListRepositoriesResult)
+	require.True(t, ok)
+	assert.Equal(t, []string{"team/api", "team/web"}, first.Repositories)
+	assert.Equal(t, 3, first.TotalCount)
+	require.NotEmpty(t, first.NextCursor)
+
+	req.Params.Arguments = map[string]interface{}{
+		"registry": host,
+		"prefix":   "team/",
+		"limit":    float64(2),
+		"cursor":   first.NextCursor,
+	}
+	result, err = provider.ListRepositories(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	second, ok := result.StructuredContent.(ListRepositoriesResult)
+	require.True(t, ok)
+	assert.Equal(t, []string{"team/worker"}, second.Repositories)
+	assert.Empty(t, second.NextCursor)
+
+	// The cursor is bound to the prefix filter
+	req.Params.Arguments = map[string]interface{}{
+		"registry": host,
+		"prefix":   "other/",
+		"cursor":   first.NextCursor,
+	}
+	result, err = provider.ListRepositories(t.Context(), req)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+}
+
+func TestListRepositories_CatalogUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider := NewToolProvider(oci.NewClient())
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"registry": strings.TrimPrefix(server.URL, "http://"),
+	}
+
+	result, err := provider.ListRepositories(t.Context(), req)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryUnsupported), payload.Category)
+	assert.Equal(t, http.StatusNotFound, payload.StatusCode)
+	assert.False(t, payload.Retryable)
+}
+
 func TestGetImageManifest_MissingImageRef(t *testing.T) {
 	provider := NewToolProvider(oci.NewClient())
 
@@ -433,3 +605,276 @@ func TestTryDecodeDSSE_InvalidBase64(t *testing.T) {
 	assert.False(t, result.decoded)
 	assert.Equal(t, "application/octet-stream", result.mimeType)
 }
+
+func TestGetImageInfo_RateLimitedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"errors":[{"code":"TOOMANYREQUESTS","message":"pull rate limit exceeded"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewToolProvider(oci.NewClient())
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"image_ref": strings.TrimPrefix(server.URL, "http://") + "/app:v1",
+	}
+
+	result, err := provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryRateLimited), payload.Category)
+	assert.Equal(t, "TOOMANYREQUESTS", payload.Code)
+	assert.Equal(t, http.StatusTooManyRequests, payload.StatusCode)
+	assert.True(t, payload.Retryable)
+	assert.Equal(t, 30, payload.RetryAfterSeconds)
+}
+
+func TestMissingArgument_InvalidArgumentCategory(t *testing.T) {
+	provider := NewToolProvider(oci.NewClient())
+
+	result, err := provider.GetImageConfig(t.Context(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryInvalidArgument), payload.Category)
+	assert.False(t, payload.Retryable)
+	assert.Equal(t, "image_ref is required", payload.Error)
+}
+
+func TestListTags_RateLimitMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("RateLimit-Limit", "100;w=21600")
+		w.Header().Set("RateLimit-Remaining", "3;w=21600")
+		w.Header().Set("Docker-RateLimit-Source", "203.0.113.7")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"app","tags":["v1"]}`))
+	}))
+	defer server.Close()
+
+	provider := NewToolProvider(oci.NewClient())
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{
+		"repository": strings.TrimPrefix(server.URL, "http://") + "/app",
+	}
+
+	result, err := provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	require.NotNil(t, result.Meta)
+	meta, ok := result.Meta.AdditionalFields[resultMetaKey].(ResultMetadata)
+	require.True(t, ok)
+	require.NotNil(t, meta.RateLimit)
+	assert.Equal(t, oci.RateLimit{Limit: 100, Remaining: 3, WindowSeconds: 21600, Source: "203.0.113.7"}, *meta.RateLimit)
+}
+
+func TestProbeRegistry(t *testing.T) {
+	host := pushRandomImages(t, "app:v1")
+	provider := NewToolProvider(oci.NewClient())
+
+	result, err := provider.ProbeRegistry(t.Context(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"registry": host, "repository": "app"}
+	result, err = provider.ProbeRegistry(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	probe, ok := result.StructuredContent.(ProbeRegistryResult)
+	require.True(t, ok)
+	assert.Equal(t, host, probe.Registry)
+	assert.Equal(t, "none", probe.AuthScheme)
+	assert.True(t, probe.AnonymousAccess)
+	assert.Equal(t, oci.CapabilityUnsupported, probe.Referrers)
+	assert.Equal(t, oci.CapabilitySupported, probe.Catalog)
+}
+
+func TestGetImageInfo_ReportsContentCache(t *testing.T) {
+	host := pushRandomImages(t, "app:v1")
+	cache, err := oci.NewContentCache(oci.ContentCacheOptions{})
+	require.NoError(t, err)
+	provider := NewToolProvider(oci.NewBackend(oci.NewClientWithConfig(&oci.Config{ContentCache: cache})))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": host + "/app:v1"}
+
+	result, err := provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	meta, ok := result.Meta.AdditionalFields[resultMetaKey].(ResultMetadata)
+	require.True(t, ok)
+	assert.Zero(t, meta.CacheHits)
+	assert.Positive(t, meta.CacheMisses)
+
+	result, err = provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	meta, ok = result.Meta.AdditionalFields[resultMetaKey].(ResultMetadata)
+	require.True(t, ok)
+	assert.Positive(t, meta.CacheHits)
+	assert.Zero(t, meta.CacheMisses)
+}
+
+func TestListTags_NoCache(t *testing.T) {
+	host := pushRandomImages(t, "app:v1")
+	provider := NewToolProvider(oci.NewBackend(oci.NewClientWithConfig(&oci.Config{TagCache: oci.NewTagCache(time.Minute)})))
+
+	listTags := func(noCache bool) ListTagsResult {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]interface{}{"repository": host + "/app", "no_cache": noCache}
+		result, err := provider.ListTags(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		tags, ok := result.StructuredContent.(ListTagsResult)
+		require.True(t, ok)
+		return tags
+	}
+
+	assert.Equal(t, []string{"v1"}, listTags(false).Tags)
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(host + "/app:v2")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	assert.Equal(t, []string{"v1"}, listTags(false).Tags, "cached")
+	assert.Equal(t, []string{"v1", "v2"}, listTags(true).Tags)
+}
+
+func TestTools_LocalLayout(t *testing.T) {
+	root := t.TempDir()
+	path, err := layout.Write(filepath.Join(root, "build"), empty.Index)
+	require.NoError(t, err)
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, path.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": "v1",
+	})))
+	location := oci.LayoutScheme + filepath.Join(root, "build")
+	store := oci.NewLocalStore(root)
+	t.Cleanup(func() { assert.NoError(t, store.Close()) })
+	provider := NewToolProvider(oci.WithLocalStore(oci.NewClient(), store))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": location + ":v1"}
+	result, err := provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	info, ok := result.StructuredContent.(ImageInfoResult)
+	require.True(t, ok)
+	assert.Equal(t, 1, info.Layers)
+
+	req.Params.Arguments = map[string]interface{}{"repository": location}
+	result, err = provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	tags, ok := result.StructuredContent.(ListTagsResult)
+	require.True(t, ok)
+	assert.Equal(t, []string{"v1"}, tags.Tags)
+
+	req.Params.Arguments = map[string]interface{}{"image_ref": location + ":missing"}
+	result, err = provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryNotFound), payload.Category)
+}
+
+// fakeTagBackend serves tags from a map, resolving names with an embedded
+// client that never contacts a registry.
+type fakeTagBackend struct {
+	oci.Backend
+	tags map[string][]string
+}
+
+func (f *fakeTagBackend) ListTags(_ context.Context, repoName string) ([]string, error) {
+	repo, err := f.NewRepository(repoName)
+	if err != nil {
+		return nil, err
+	}
+	tags, ok := f.tags[repo.Name()]
+	if !ok {
+		return nil, &transport.Error{StatusCode: http.StatusNotFound}
+	}
+	return tags, nil
+}
+
+// countingBackend counts the manifests it fetches through the backend it
+// wraps.
+type countingBackend struct {
+	oci.Backend
+	manifests *atomic.Int32
+}
+
+func (c countingBackend) GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	c.manifests.Add(1)
+	return c.Backend.GetManifest(ctx, imageRef)
+}
+
+func TestToolProvider_Backend(t *testing.T) {
+	provider := NewToolProvider(&fakeTagBackend{
+		Backend: oci.NewClient(),
+		tags:    map[string][]string{"index.docker.io/library/alpine": {"3.19", "3.20"}},
+	})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"repository": "alpine"}
+	result, err := provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	tags, ok := result.StructuredContent.(ListTagsResult)
+	require.True(t, ok)
+	assert.Equal(t, []string{"3.19", "3.20"}, tags.Tags)
+
+	req.Params.Arguments = map[string]interface{}{"repository": "busybox"}
+	result, err = provider.ListTags(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	payload, ok := result.StructuredContent.(ErrorResult)
+	require.True(t, ok)
+	assert.Equal(t, string(oci.ErrorCategoryNotFound), payload.Category)
+}
+
+func TestToolProvider_BackendDecorator(t *testing.T) {
+	host := pushRandomImages(t, "app:v1")
+	var manifests atomic.Int32
+	var order []string
+	provider := NewToolProviderWithFactory(
+		func(http.Header) oci.Backend { return oci.NewClient() },
+		WithBackendDecorator(func(b oci.Backend) oci.Backend {
+			order = append(order, "inner")
+			return countingBackend{Backend: b, manifests: &manifests}
+		}),
+		WithBackendDecorator(func(b oci.Backend) oci.Backend {
+			order = append(order, "outer")
+			return b
+		}),
+	)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]interface{}{"image_ref": host + "/app:v1"}
+	result, err := provider.GetImageInfo(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	assert.Equal(t, int32(1), manifests.Load())
+	assert.Equal(t, []string{"inner", "outer"}, order)
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// maxWebhookBytes bounds the size of webhook payloads (1MiB).
+const maxWebhookBytes = 1 << 20
+
+// WebhookOptions configures the receiver of registry webhooks.
+type WebhookOptions struct {
+	// Secret shared with the registries sending webhooks, see
+	// oci.VerifyWebhook. Webhooks are rejected when it is empty.
+	Secret string
+	// TagCache, if set, forgets what pushed and deleted tags resolved to.
+	TagCache *oci.TagCache
+	// Watcher, if set, notifies the subscribers of pushed and deleted tags.
+	Watcher *TagWatcher
+	// OnEvents, if set, is called with the events of each accepted webhook
+	// once they have been applied.
+	OnEvents func([]oci.RegistryEvent)
+	// Hosts maps the registry hosts webhooks name to the registries clients
+	// reference, e.g. the internal address a registry reports to its public
+	// name.
+	Hosts map[string]string
+	// Names, if set, resolves the repositories of events like the references
+	// of tool calls, so aliases apply to them as to the tags clients watch.
+	Names oci.NameResolver
+}
+
+// normalize returns ev with its repository named as clients reference it.
+func (opts WebhookOptions) normalize(ev oci.RegistryEvent) oci.RegistryEvent {
+	host := ev.Repository.RegistryStr()
+	mapped, ok := opts.Hosts[host]
+	if ok {
+		host = mapped
+	}
+	if !ok && opts.Names == nil {
+		return ev
+	}
+	var names oci.NameResolver = (*oci.Config)(nil)
+	if opts.Names != nil {
+		names = opts.Names
+	}
+	if repo, err := names.NewRepository(host + "/" + ev.Repository.RepositoryStr()); err == nil {
+		ev.Repository = repo
+	}
+	return ev
+}
+
+// NewWebhookHandler returns a handler receiving the push and delete events of
+// registries as Distribution notifications, Harbor webhooks or GitHub package
+// events for GHCR. Events invalidate the cached resolutions of their tags and
+// notify the subscribers of tag resources immediately, so registries sending
+// webhooks need not be polled often. The registries events name are
+// translated with opts.Hosts and opts.Names into the names clients use.
+func NewWebhookHandler(opts WebhookOptions) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "webhooks must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookBytes))
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(rw, "webhook payload too large", http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(rw, "reading webhook payload", http.StatusBadRequest)
+			return
+		}
+		if !oci.VerifyWebhook(r.Header, body, opts.Secret) {
+			http.Error(rw, "invalid webhook credentials", http.StatusUnauthorized)
+			return
+		}
+
+		events, err := oci.ParseWebhook(r.Header, body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i, ev := range events {
+			ev = opts.normalize(ev)
+			events[i] = ev
+			if opts.TagCache != nil {
+				if ev.Tag != "" {
+					opts.TagCache.Invalidate(ev.Repository, ev.Tag)
+				} else {
+					opts.TagCache.Invalidate(ev.Repository)
+				}
+			}
+			if opts.Watcher != nil {
+				opts.Watcher.applyEvent(ev)
+			}
+		}
+		if opts.OnEvents != nil && len(events) > 0 {
+			opts.OnEvents(events)
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
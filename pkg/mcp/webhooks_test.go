@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/StacklokLabs/ocireg-mcp/pkg/oci"
+)
+
+// distributionPush returns a Distribution notification of a tag pushed to a
+// repository of host.
+func distributionPush(host, repository, tag, digest string) string {
+	return fmt.Sprintf(`{"events":[{"action":"push","target":{"repository":%q,"tag":%q,"digest":%q},`+
+		`"request":{"host":%q}}]}`, repository, tag, digest, host)
+}
+
+func TestWebhookHandler(t *testing.T) {
+	reg := httptest.NewServer(registry.New())
+	t.Cleanup(reg.Close)
+	host := strings.TrimPrefix(reg.URL, "http://")
+	pushRandomImage(t, host+"/app:stable")
+
+	cache := oci.NewTagCache(time.Hour)
+	client := oci.NewBackend(oci.NewClientWithConfig(&oci.Config{TagCache: cache}))
+	// Tags are never polled during the test, only updated by webhooks.
+	server, watcher := newTestWatcher(t, NewToolProvider(client), WatchOptions{Interval: time.Hour})
+	session := registerSession(t, server, "session-1")
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+	go watcher.Run(ctx)
+	uri := "oci://" + host + "/app:stable/manifest"
+	assert.Contains(t, subscribe(t, watcher, session.id, methodResourcesSubscribe, uri), `"result":{}`)
+
+	_, cached, err := oci.ResolveDigest(t.Context(), client, host+"/app:stable")
+	require.NoError(t, err)
+
+	var received []oci.RegistryEvent
+	handler := NewWebhookHandler(WebhookOptions{
+		Secret:   "s3cret",
+		TagCache: cache,
+		Watcher:  watcher,
+		OnEvents: func(events []oci.RegistryEvent) { received = append(received, events...) },
+	})
+	post := func(auth, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		req.Header.Set("Authorization", auth)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	moved := pushRandomImage(t, host+"/app:stable")
+	push := distributionPush(host, "app", "stable", moved.String())
+
+	t.Run("rejected webhooks change nothing", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, post("Bearer guess", push))
+		assert.Equal(t, http.StatusBadRequest, post("Bearer s3cret", `{"hello":"world"}`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Empty(t, session.notifications)
+		assert.Empty(t, received)
+
+		_, digest, err := oci.ResolveDigest(t.Context(), client, host+"/app:stable")
+		require.NoError(t, err)
+		assert.Equal(t, cached, digest)
+	})
+
+	t.Run("pushes notify subscribers", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, post("Bearer s3cret", push))
+		require.Len(t, received, 1)
+		assert.Equal(t, "stable", received[0].Tag)
+		select {
+		case n := <-session.notifications:
+			assert.Equal(t, mcp.MethodNotificationResourceUpdated, n.Method)
+			assert.Equal(t, uri, n.Params.AdditionalFields["uri"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification after the push webhook")
+		}
+
+		// The tag's cached resolution was dropped.
+		_, digest, err := oci.ResolveDigest(t.Context(), client, host+"/app:stable")
+		require.NoError(t, err)
+		assert.Equal(t, moved, digest)
+
+		// Repeated deliveries of the same push are not notified again.
+		assert.Equal(t, http.StatusNoContent, post("Bearer s3cret", push))
+		assert.Empty(t, session.notifications)
+	})
+
+	t.Run("deletes notify subscribers", func(t *testing.T) {
+		deleted := fmt.Sprintf(`{"events":[{"action":"delete","target":{"repository":"app","digest":%q},`+
+			`"request":{"host":%q}}]}`, moved.String(), host)
+		assert.Equal(t, http.StatusNoContent, post("Bearer s3cret", deleted))
+		select {
+		case n := <-session.notifications:
+			assert.Equal(t, uri, n.Params.AdditionalFields["uri"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification after the delete webhook")
+		}
+	})
+
+	t.Run("registries are named as clients reference them", func(t *testing.T) {
+		// The registry reports an internal address, mapped to an alias of the
+		// host clients subscribed with.
+		aliases := &oci.Config{Aliases: []oci.Alias{{Prefix: "internal/", Replacement: host + "/"}}}
+		handler := NewWebhookHandler(WebhookOptions{
+			Secret:  "s3cret",
+			Watcher: watcher,
+			Hosts:   map[string]string{"registry.internal:5000": "internal"},
+			Names:   aliases,
+		})
+		pushed := pushRandomImage(t, host+"/app:stable")
+		req := httptest.NewRequest(http.MethodPost, "/webhook",
+			strings.NewReader(distributionPush("registry.internal:5000", "app", "stable", pushed.String())))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		select {
+		case n := <-session.notifications:
+			assert.Equal(t, uri, n.Params.AdditionalFields["uri"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification after a push reported by another host name")
+		}
+	})
+}
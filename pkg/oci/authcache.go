@@ -0,0 +1,263 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pingCacheTTL is how long a registry's /v2/ auth challenge is reused.
+	pingCacheTTL = 5 * time.Minute
+
+	// defaultTokenTTL applies to tokens issued without expires_in, as the
+	// distribution token specification prescribes.
+	defaultTokenTTL = 60 * time.Second
+
+	// tokenExpiryMargin is subtracted from token lifetimes so a cached token
+	// is not used right as it expires.
+	tokenExpiryMargin = 10 * time.Second
+
+	// maxAuthCacheEntries bounds the number of cached pings and tokens.
+	maxAuthCacheEntries = 1024
+
+	// maxCachedResponseSize bounds how much of a cached response is buffered.
+	maxCachedResponseSize = 1 << 20
+)
+
+// tokenKey identifies a bearer token by the registry's token service, the
+// scopes it grants and the identity of the credentials that obtained it, so
+// callers with different credentials never share tokens.
+type tokenKey struct {
+	realm    string
+	service  string
+	scope    string
+	identity string
+}
+
+// cachedResponse is a response replayed from the cache until it expires.
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	token   string
+	expires time.Time
+}
+
+// authCacheTransport caches the two round trips go-containerregistry makes
+// before every operation: the unauthenticated /v2/ ping that discovers the
+// auth challenge, and the token request for the operation's scope. Tool calls
+// that build a new Client per request then reuse tokens across calls.
+type authCacheTransport struct {
+	inner http.RoundTripper
+	now   func() time.Time
+
+	mu     sync.Mutex
+	pings  map[string]*cachedResponse
+	tokens map[tokenKey]*cachedResponse
+}
+
+// newAuthCacheTransport wraps inner with a ping and token cache.
+func newAuthCacheTransport(inner http.RoundTripper) *authCacheTransport {
+	return &authCacheTransport{
+		inner:  inner,
+		now:    time.Now,
+		pings:  map[string]*cachedResponse{},
+		tokens: map[tokenKey]*cachedResponse{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case isPing(req):
+		return t.ping(req)
+	case isTokenRequest(req):
+		return t.token(req)
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// The registry rejected a token, perhaps revoked: forget it so the
+		// bearer transport's refresh fetches a new one.
+		if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			t.evictToken(token)
+		}
+	}
+	return resp, err
+}
+
+// ping serves a /v2/ ping from the cache or caches its auth challenge.
+func (t *authCacheTransport) ping(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	if cached := t.lookupPing(key); cached != nil {
+		return cached.response(req), nil
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized) {
+		return resp, err
+	}
+
+	cached, err := bufferResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	cached.expires = t.now().Add(pingCacheTTL)
+
+	t.mu.Lock()
+	pruneExpired(t.pings, t.now())
+	if len(t.pings) < maxAuthCacheEntries {
+		t.pings[key] = cached
+	}
+	t.mu.Unlock()
+	return cached.response(req), nil
+}
+
+// token serves a token request from the cache or caches the issued token.
+func (t *authCacheTransport) token(req *http.Request) (*http.Response, error) {
+	key := newTokenKey(req)
+	if cached := t.lookupToken(key); cached != nil {
+		return cached.response(req), nil
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	cached, err := bufferResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if json.Unmarshal(cached.body, &token) != nil || (token.Token == "" && token.AccessToken == "") {
+		return cached.response(req), nil
+	}
+
+	cached.token = token.AccessToken
+	if cached.token == "" {
+		cached.token = token.Token
+	}
+	ttl := defaultTokenTTL
+	if token.ExpiresIn > 0 {
+		ttl = time.Duration(token.ExpiresIn) * time.Second
+	}
+	if ttl > tokenExpiryMargin {
+		cached.expires = t.now().Add(ttl - tokenExpiryMargin)
+
+		t.mu.Lock()
+		pruneExpired(t.tokens, t.now())
+		if len(t.tokens) < maxAuthCacheEntries {
+			t.tokens[key] = cached
+		}
+		t.mu.Unlock()
+	}
+	return cached.response(req), nil
+}
+
+// lookupPing returns the unexpired cached ping for key, or nil.
+func (t *authCacheTransport) lookupPing(key string) *cachedResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cached, ok := t.pings[key]; ok && t.now().Before(cached.expires) {
+		return cached
+	}
+	return nil
+}
+
+// lookupToken returns the unexpired cached token response for key, or nil.
+func (t *authCacheTransport) lookupToken(key tokenKey) *cachedResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cached, ok := t.tokens[key]; ok && t.now().Before(cached.expires) {
+		return cached
+	}
+	return nil
+}
+
+// evictToken forgets every cached response that issued token.
+func (t *authCacheTransport) evictToken(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, cached := range t.tokens {
+		if cached.token == token {
+			delete(t.tokens, key)
+		}
+	}
+}
+
+// isPing reports whether req is go-containerregistry's unauthenticated ping.
+func isPing(req *http.Request) bool {
+	return req.Method == http.MethodGet && req.URL.Path == "/v2/" && req.Header.Get("Authorization") == ""
+}
+
+// isTokenRequest reports whether req asks a token service for a bearer token.
+// POST requests exchanging refresh tokens are not cached.
+func isTokenRequest(req *http.Request) bool {
+	return req.Method == http.MethodGet && req.URL.Query().Has("service") && req.URL.Path != "/v2/"
+}
+
+// newTokenKey returns the cache key of a token request.
+func newTokenKey(req *http.Request) tokenKey {
+	query := req.URL.Query()
+	scopes := query["scope"]
+	sort.Strings(scopes)
+
+	realm := *req.URL
+	realm.RawQuery = ""
+
+	identity := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return tokenKey{
+		realm:    realm.String(),
+		service:  query.Get("service"),
+		scope:    strings.Join(scopes, " "),
+		identity: hex.EncodeToString(identity[:]),
+	}
+}
+
+// bufferResponse reads and closes resp's body so it can be replayed.
+func bufferResponse(resp *http.Response) (*cachedResponse, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return &cachedResponse{status: resp.StatusCode, header: resp.Header.Clone(), body: body}, nil
+}
+
+// response returns a fresh copy of the cached response answering req.
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.status, http.StatusText(c.status)),
+		StatusCode:    c.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// pruneExpired removes expired entries from a cache.
+func pruneExpired[K comparable](cache map[K]*cachedResponse, now time.Time) {
+	for key, cached := range cache {
+		if !now.Before(cached.expires) {
+			delete(cache, key)
+		}
+	}
+}
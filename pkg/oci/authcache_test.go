@@ -0,0 +1,129 @@
+package oci
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuthServer answers pings with a bearer challenge, issues a new token
+// for every token request and rejects tokens listed in revoked.
+type fakeAuthServer struct {
+	pings   atomic.Int32
+	tokens  atomic.Int32
+	revoked map[string]bool
+}
+
+func (s *fakeAuthServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	respond := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}
+	}
+
+	switch {
+	case req.URL.Path == "/v2/":
+		s.pings.Add(1)
+		resp := respond(http.StatusUnauthorized, "")
+		resp.Header.Set("WWW-Authenticate", `Bearer realm="https://auth.example/token",service="registry.example"`)
+		return resp, nil
+	case req.URL.Host == "auth.example":
+		n := s.tokens.Add(1)
+		return respond(http.StatusOK, fmt.Sprintf(`{"token":"token-%d","expires_in":300}`, n)), nil
+	default:
+		if s.revoked[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")] {
+			return respond(http.StatusUnauthorized, ""), nil
+		}
+		return respond(http.StatusOK, "{}"), nil
+	}
+}
+
+// authGet issues a GET through rt and returns the response body.
+func authGet(t *testing.T, rt http.RoundTripper, rawURL, authorization string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, rawURL, nil)
+	require.NoError(t, err)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestAuthCacheTransport_Ping(t *testing.T) {
+	server := &fakeAuthServer{}
+	cache := newAuthCacheTransport(server)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for range 3 {
+		status, _ := authGet(t, cache, "https://registry.example/v2/", "")
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+	assert.Equal(t, int32(1), server.pings.Load())
+
+	now = now.Add(pingCacheTTL)
+	authGet(t, cache, "https://registry.example/v2/", "")
+	assert.Equal(t, int32(2), server.pings.Load())
+}
+
+func TestAuthCacheTransport_Token(t *testing.T) {
+	server := &fakeAuthServer{}
+	cache := newAuthCacheTransport(server)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	const tokenURL = "https://auth.example/token?scope=repository%3Aapp%3Apull&service=registry.example"
+	_, first := authGet(t, cache, tokenURL, "Basic YWxpY2U6c2VjcmV0")
+	_, second := authGet(t, cache, tokenURL, "Basic YWxpY2U6c2VjcmV0")
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), server.tokens.Load())
+
+	// Different credentials and different scopes get their own tokens.
+	_, other := authGet(t, cache, tokenURL, "Basic Ym9iOnNlY3JldA==")
+	assert.NotEqual(t, first, other)
+	authGet(t, cache, "https://auth.example/token?scope=repository%3Aother%3Apull&service=registry.example",
+		"Basic YWxpY2U6c2VjcmV0")
+	assert.Equal(t, int32(3), server.tokens.Load())
+
+	// Tokens expire ahead of their lifetime.
+	now = now.Add(300*time.Second - tokenExpiryMargin)
+	authGet(t, cache, tokenURL, "Basic YWxpY2U6c2VjcmV0")
+	assert.Equal(t, int32(4), server.tokens.Load())
+}
+
+func TestAuthCacheTransport_EvictsRejectedToken(t *testing.T) {
+	server := &fakeAuthServer{revoked: map[string]bool{"token-1": true}}
+	cache := newAuthCacheTransport(server)
+
+	const tokenURL = "https://auth.example/token?scope=repository%3Aapp%3Apull&service=registry.example"
+	_, body := authGet(t, cache, tokenURL, "")
+	assert.Contains(t, body, "token-1")
+
+	status, _ := authGet(t, cache, "https://registry.example/v2/app/tags/list", "Bearer token-1")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	_, body = authGet(t, cache, tokenURL, "")
+	assert.Contains(t, body, "token-2")
+}
+
+func TestNewClientWithConfig_SharesTransport(t *testing.T) {
+	cfg := &Config{}
+	assert.Same(t, NewClientWithConfig(cfg).transport, NewClientWithConfig(cfg).transport)
+	assert.Same(t, NewClient().transport, NewClient().transport)
+	assert.NotSame(t, NewClientWithConfig(cfg).transport, NewClientWithConfig(&Config{}).transport)
+}
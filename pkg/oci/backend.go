@@ -0,0 +1,102 @@
+package oci
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ContentReader reads the manifests and blobs images are made of. GetImage,
+// ResolveDigest and the other content helpers are built on it, so a
+// ContentReader is all a store needs to serve images.
+//
+// Errors should wrap the go-containerregistry transport errors, or sentinel
+// errors such as ErrLocalNotFound, so ClassifyError can categorize them.
+type ContentReader interface {
+	// NameResolver resolves names, applying the reader's default registry
+	// and aliases.
+	NameResolver
+
+	// HeadReference returns the descriptor a reference points at. The
+	// returned reference is nil for references outside any registry.
+	HeadReference(ctx context.Context, imageRef string) (name.Reference, *v1.Descriptor, error)
+	// GetManifest returns the raw manifest or index a reference points at.
+	GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error)
+	// GetBlob returns a blob of a repository, failing with ErrBlobTooLarge
+	// if it is larger than maxBytes.
+	GetBlob(ctx context.Context, repo, digest string, maxBytes int64) ([]byte, error)
+}
+
+// TagLister lists the tags of repositories.
+type TagLister interface {
+	// ListTags returns the tags of a repository.
+	ListTags(ctx context.Context, repoName string) ([]string, error)
+}
+
+// ReferrerLister lists the artifacts referring to images.
+type ReferrerLister interface {
+	// ListReferrers returns the artifacts referring to an image, optionally
+	// filtered by artifact type.
+	ListReferrers(ctx context.Context, imageRef, artifactTypeFilter string) (*v1.IndexManifest, error)
+}
+
+// RegistryInspector reports on registries as a whole.
+type RegistryInspector interface {
+	// ListRepositories returns the repositories of a registry.
+	ListRepositories(ctx context.Context, registryName string) ([]string, error)
+	// ProbeRegistry reports the capabilities of a registry.
+	ProbeRegistry(ctx context.Context, registryName, repository string) (*RegistryProbe, error)
+	// DockerHubRateLimit reports the caller's Docker Hub pull rate limit.
+	DockerHubRateLimit(ctx context.Context) (*RateLimit, error)
+}
+
+// Suggester suggests existing references in place of missing ones.
+type Suggester interface {
+	// SuggestAlternatives and SuggestRepositories return the closest existing
+	// references to one that does not exist.
+	SuggestAlternatives(ctx context.Context, imageRef string) []string
+	SuggestRepositories(ctx context.Context, repoName string) []string
+}
+
+// Backend is the set of operations the MCP tools perform against images and
+// registries. Client is the implementation talking to remote registries;
+// WithLocalStore, WithTagCache and WithContentCache wrap a Backend to serve
+// local images and cached content. Other implementations can serve images
+// from elsewhere, or decorate a Backend by embedding it and overriding some
+// of its methods.
+type Backend interface {
+	ContentReader
+	TagLister
+	ReferrerLister
+	RegistryInspector
+	Suggester
+}
+
+var _ Backend = (*Client)(nil)
+
+// NewBackend returns the client wrapped in the mirrors and caches its config
+// enables. Cached content and tags are partitioned by the credentials the
+// client authenticates with, see WithAuth and WithKeychain; clients created
+// with remote options are not cached.
+func NewBackend(c *Client) Backend {
+	var b Backend = c
+	if c.config == nil {
+		return b
+	}
+	if len(c.config.Mirrors) > 0 {
+		mirrors := NewClientWithConfig(c.config).WithAuth(authn.Anonymous)
+		if c.config.MirrorKeychain != nil {
+			mirrors = mirrors.WithKeychain(c.config.MirrorKeychain)
+		}
+		b = WithMirrors(b, mirrors, c.config)
+	}
+	if c.config.ContentCache != nil {
+		b = WithContentCache(b, c.config.ContentCache, c.cacheScope)
+	}
+	if c.config.TagCache != nil {
+		b = WithTagCache(b, c.config.TagCache, c.cacheScope)
+	}
+	return b
+}
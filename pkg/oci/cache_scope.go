@@ -0,0 +1,86 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// CacheScope returns the scope the cached content and tags of a registry are
+// partitioned by, and false when they must not be cached at all. Callers
+// sharing a scope may be served what any of them fetched, so a scope must
+// identify the credentials the registry is accessed with.
+type CacheScope func(ctx context.Context, registry name.Registry) (string, bool)
+
+// WithAuth returns a copy of the client authenticating to every registry
+// with auth. Unlike a remote.WithAuth option, the client knows the
+// credentials, so its requests can be cached per credentials.
+func (c *Client) WithAuth(auth authn.Authenticator) *Client {
+	authed := *c
+	authed.auth, authed.keychain = auth, nil
+	return &authed
+}
+
+// WithKeychain returns a copy of the client resolving the credentials of
+// each registry from keychain. Unlike a remote.WithAuthFromKeychain option,
+// the client knows the credentials, so its requests can be cached per
+// credentials.
+func (c *Client) WithKeychain(keychain authn.Keychain) *Client {
+	authed := *c
+	authed.auth, authed.keychain = nil, keychain
+	return &authed
+}
+
+// authOptions returns the remote options authenticating the client's
+// requests.
+func (c *Client) authOptions() []remote.Option {
+	switch {
+	case c.auth != nil:
+		return []remote.Option{remote.WithAuth(c.auth)}
+	case c.keychain != nil:
+		return []remote.Option{remote.WithAuthFromKeychain(c.keychain)}
+	default:
+		return nil
+	}
+}
+
+// cacheScope is the client's CacheScope, derived from the credentials it
+// authenticates to registry with. Anonymous requests share the empty scope,
+// and credentials get a scope hashed from them. Options passed to NewClient
+// may authenticate in ways the client cannot see, so a client created with
+// options is not cached.
+func (c *Client) cacheScope(ctx context.Context, registry name.Registry) (string, bool) {
+	if len(c.options) > 0 {
+		return "", false
+	}
+
+	auth := c.auth
+	if c.keychain != nil {
+		var err error
+		if auth, err = authn.Resolve(ctx, c.keychain, registry); err != nil {
+			return "", false
+		}
+	}
+	if auth == nil || auth == authn.Anonymous {
+		return "", true
+	}
+
+	cfg, err := authn.Authorization(ctx, auth)
+	if err != nil {
+		return "", false
+	}
+	if *cfg == (authn.AuthConfig{}) {
+		return "", true
+	}
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), true
+}
@@ -3,28 +3,84 @@ package oci
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"slices"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
+// ErrCatalogUnsupported is returned when a registry does not serve the
+// /v2/_catalog endpoint, either because it is disabled or not implemented.
+var ErrCatalogUnsupported = errors.New("registry does not support repository catalog listing")
+
+// ErrIncomplete is returned together with the items read so far when a
+// paginated listing is cut short by its context, typically a deadline, after
+// at least one page was read.
+var ErrIncomplete = errors.New("listing incomplete")
+
+// ErrBlobTooLarge is returned when a blob exceeds the size a caller is
+// willing to read.
+var ErrBlobTooLarge = errors.New("blob exceeds the size limit")
+
 // Client provides methods for interacting with OCI registries.
 type Client struct {
-	options []remote.Option
+	options   []remote.Option
+	config    *Config
+	transport http.RoundTripper
+	// auth and keychain authenticate requests, see WithAuth and WithKeychain.
+	auth     authn.Authenticator
+	keychain authn.Keychain
 }
 
 // NewClient creates a new OCI registry client.
 func NewClient(options ...remote.Option) *Client {
+	return NewClientWithConfig(nil, options...)
+}
+
+// NewClientWithConfig creates a new OCI registry client using the given
+// server-wide configuration. A nil config uses go-containerregistry defaults.
+// Clients created with the same config share pooled connections and cached
+// bearer tokens, which are keyed by credentials so they are never shared
+// between callers with different credentials.
+func NewClientWithConfig(cfg *Config, options ...remote.Option) *Client {
 	return &Client{
-		options: options,
+		options:   options,
+		config:    cfg,
+		transport: cfg.sharedTransport(),
 	}
 }
 
+// defaultSharedTransport is the transport shared by clients without a config.
+var defaultSharedTransport = sync.OnceValue(func() http.RoundTripper {
+	return newClientTransport(nil)
+})
+
+// sharedTransport returns the transport shared by every Client created with cfg.
+func (cfg *Config) sharedTransport() http.RoundTripper {
+	if cfg == nil {
+		return defaultSharedTransport()
+	}
+	cfg.sharedOnce.Do(func() {
+		cfg.shared = newClientTransport(cfg)
+	})
+	return cfg.shared
+}
+
+// newClientTransport builds the transport chain for registry requests: auth
+// handshake caching, retries and tracing around the configured base
+// transport.
+func newClientTransport(cfg *Config) http.RoundTripper {
+	return newAuthCacheTransport(newRetryTransport(newTraceTransport(cfg.transport()), cfg.retryPolicy()))
+}
+
 // WithBasicAuth returns a remote.Option for basic authentication with username and password.
 func WithBasicAuth(username, password string) remote.Option {
 	return remote.WithAuth(&authn.Basic{
@@ -39,10 +95,22 @@ func WithDefaultKeychain() remote.Option {
 	return remote.WithAuthFromKeychain(authn.DefaultKeychain)
 }
 
-// optionsWith returns a new slice containing c.options plus the given extras,
-// avoiding mutation of the original backing array under concurrent use.
+// optionsWith returns a new slice containing the client's transport, c.options
+// plus the given extras, avoiding mutation of the original backing array under
+// concurrent use. A remote.WithTransport in c.options replaces the client's
+// transport.
 func (c *Client) optionsWith(extras ...remote.Option) []remote.Option {
-	opts := make([]remote.Option, 0, len(c.options)+len(extras))
+	opts := make([]remote.Option, 0, len(c.options)+len(extras)+4)
+	if c.transport != nil {
+		// The client's transport retries on its own; disable go-containerregistry's
+		// retries so attempts do not multiply.
+		opts = append(opts,
+			remote.WithTransport(c.transport),
+			remote.WithRetryStatusCodes(),
+			remote.WithRetryPredicate(func(error) bool { return false }),
+		)
+	}
+	opts = append(opts, c.authOptions()...)
 	opts = append(opts, c.options...)
 	opts = append(opts, extras...)
 	return opts
@@ -55,50 +123,91 @@ func WithBearerToken(token string) remote.Option {
 	})
 }
 
-// GetImage retrieves an image from a registry.
-func (c *Client) GetImage(ctx context.Context, imageRef string) (v1.Image, error) {
-	ref, err := name.ParseReference(imageRef)
-	if err != nil {
-		return nil, fmt.Errorf("parsing image reference: %w", err)
-	}
+// manifest is a manifest read in full, with its descriptor.
+type manifest struct {
+	raw  []byte
+	desc v1.Descriptor
+}
 
-	options := c.optionsWith(remote.WithContext(ctx))
-	img, err := remote.Image(ref, options...)
+// getManifest fetches the manifest ref points at. Concurrent requests for
+// the same reference share one request; each caller gets its own copy of
+// the manifest.
+func (c *Client) getManifest(ctx context.Context, ref name.Reference) ([]byte, v1.Descriptor, error) {
+	m, err := coalesce(ctx, c, ref.Context().Registry, "get "+ref.String(), func(ctx context.Context) (manifest, error) {
+		desc, err := remote.Get(ref, c.optionsWith(remote.WithContext(ctx))...)
+		if err != nil {
+			return manifest{}, err
+		}
+		served(ctx, ref.Context())
+		return manifest{raw: desc.Manifest, desc: desc.Descriptor}, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("fetching image: %w", err)
+		return nil, v1.Descriptor{}, err
 	}
-
-	return img, nil
+	return slices.Clone(m.raw), m.desc, nil
 }
 
-// GetImageManifest retrieves the manifest for an image.
-func (c *Client) GetImageManifest(ctx context.Context, imageRef string) (*v1.Manifest, error) {
-	img, err := c.GetImage(ctx, imageRef)
+// GetManifest retrieves the raw manifest or index a reference points at, with
+// its descriptor.
+func (c *Client) GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	ref, err := c.ParseReference(imageRef)
 	if err != nil {
-		return nil, err
+		return nil, v1.Descriptor{}, err
 	}
-
-	manifest, err := img.Manifest()
+	raw, desc, err := c.getManifest(ctx, ref)
 	if err != nil {
-		return nil, fmt.Errorf("getting manifest: %w", err)
+		return nil, v1.Descriptor{}, fmt.Errorf("fetching manifest: %w", err)
 	}
-
-	return manifest, nil
+	return raw, desc, nil
 }
 
-// GetImageConfig retrieves the config for an image.
-func (c *Client) GetImageConfig(ctx context.Context, imageRef string) (*v1.ConfigFile, error) {
-	img, err := c.GetImage(ctx, imageRef)
+// GetBlob reads the blob with the given digest from a repository. It fails with an error wrapping ErrBlobTooLarge
+// without reading further when the blob is larger than maxBytes. Concurrent reads of the same blob with the same
+// limit share one request; each caller gets its own copy of the blob.
+func (c *Client) GetBlob(ctx context.Context, repo, digest string, maxBytes int64) ([]byte, error) {
+	if _, err := v1.NewHash(digest); err != nil {
+		return nil, fmt.Errorf("parsing blob digest: %w", err)
+	}
+
+	r, err := c.NewRepository(repo)
 	if err != nil {
 		return nil, err
 	}
-
-	config, err := img.ConfigFile()
+	key := fmt.Sprintf("blob %s@%s %d", r, digest, maxBytes)
+	blob, err := coalesce(ctx, c, r.Registry, key, func(ctx context.Context) ([]byte, error) {
+		layer, err := remote.Layer(r.Digest(digest), c.optionsWith(remote.WithContext(ctx))...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching blob: %w", err)
+		}
+		rc, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("fetching blob: %w", err)
+		}
+		defer rc.Close()
+		blob, err := readLimited(rc, digest, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		served(ctx, r)
+		return blob, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("getting config: %w", err)
+		return nil, err
 	}
+	return slices.Clone(blob), nil
+}
 
-	return config, nil
+// readLimited reads a blob, failing with an error wrapping ErrBlobTooLarge
+// without reading further when it is larger than maxBytes.
+func readLimited(r io.Reader, digest string, maxBytes int64) ([]byte, error) {
+	blob, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob: %w", err)
+	}
+	if int64(len(blob)) > maxBytes {
+		return nil, fmt.Errorf("blob %s: %w of %d bytes", digest, ErrBlobTooLarge, maxBytes)
+	}
+	return blob, nil
 }
 
 // ListReferrers lists OCI artifacts that refer to the given image via the Referrers API.
@@ -106,12 +215,16 @@ func (c *Client) GetImageConfig(ctx context.Context, imageRef string) (*v1.Confi
 func (c *Client) ListReferrers(
 	ctx context.Context, imageRef, artifactTypeFilter string,
 ) (*v1.IndexManifest, error) {
-	repo, digest, err := c.ResolveDigest(ctx, imageRef)
+	ref, err := c.ParseReference(imageRef)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := c.resolveDigest(ctx, ref)
 	if err != nil {
 		return nil, err
 	}
+	repo := ref.Context()
 
-	digestRef := repo.Digest(digest.String())
 	options := c.optionsWith(remote.WithContext(ctx))
 
 	if artifactTypeFilter != "" {
@@ -119,10 +232,11 @@ func (c *Client) ListReferrers(
 			remote.WithFilter("artifactType", artifactTypeFilter))
 	}
 
-	idx, err := remote.Referrers(digestRef, options...)
+	idx, err := remote.Referrers(repo.Digest(digest.String()), options...)
 	if err != nil {
 		return nil, fmt.Errorf("listing referrers: %w", err)
 	}
+	served(ctx, repo)
 
 	indexManifest, err := idx.IndexManifest()
 	if err != nil {
@@ -132,138 +246,194 @@ func (c *Client) ListReferrers(
 	return indexManifest, nil
 }
 
-// LegacyCosignArtifact represents an artifact found via legacy cosign tag scheme.
-type LegacyCosignArtifact struct {
-	Digest       v1.Hash
-	Size         int64
-	MediaType    types.MediaType
-	ArtifactType string
-	TagSuffix    string // "sig", "att", or "sbom"
-}
+// resolveDigest resolves a parsed reference to a digest.
+func (c *Client) resolveDigest(ctx context.Context, ref name.Reference) (v1.Hash, error) {
+	if digestRef, ok := ref.(name.Digest); ok {
+		digest, err := v1.NewHash(digestRef.Identifier())
+		if err != nil {
+			return v1.Hash{}, fmt.Errorf("parsing digest: %w", err)
+		}
+		return digest, nil
+	}
 
-// legacyCosignSuffixes maps tag suffixes to their artifact types.
-var legacyCosignSuffixes = map[string]string{
-	"sig":  "application/vnd.dev.cosign.artifact.sig.v1+json",
-	"att":  "application/vnd.dsse.envelope.v1+json",
-	"sbom": "application/vnd.dev.cosign.artifact.sbom.v1+json",
+	desc, err := c.headReference(ctx, ref)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return desc.Digest, nil
 }
 
-// ListLegacyCosignArtifacts discovers artifacts stored via the legacy
-// cosign tag scheme (sha256-<hex>.sig, .att, .sbom).
-func (c *Client) ListLegacyCosignArtifacts(
-	ctx context.Context, repo name.Repository, imageDigest v1.Hash,
-) []LegacyCosignArtifact {
-	options := c.optionsWith(remote.WithContext(ctx))
-	hex := imageDigest.Hex
-
-	var artifacts []LegacyCosignArtifact
-	for suffix, artifactType := range legacyCosignSuffixes {
-		tagName := fmt.Sprintf("sha256-%s.%s", hex, suffix)
-		tag := repo.Tag(tagName)
+// headReference resolves a parsed reference to the descriptor it points at.
+func (c *Client) headReference(ctx context.Context, ref name.Reference) (*v1.Descriptor, error) {
+	desc, err := c.head(ctx, ref, c.optionsWith(remote.WithContext(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("resolving image reference: %w", err)
+	}
+	return desc, nil
+}
 
-		desc, err := remote.Head(tag, options...)
+// head fetches the descriptor ref points at with a HEAD request, trying
+// configured mirrors first. Concurrent requests for the same reference share
+// one request.
+func (c *Client) head(ctx context.Context, ref name.Reference, options []remote.Option) (*v1.Descriptor, error) {
+	desc, err := coalesce(ctx, c, ref.Context().Registry, "head "+ref.String(), func(ctx context.Context) (*v1.Descriptor, error) {
+		desc, err := remote.Head(ref, append(slices.Clip(options), remote.WithContext(ctx))...)
 		if err != nil {
-			continue
+			return nil, err
 		}
+		served(ctx, ref.Context())
+		return desc, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	shared := *desc
+	return &shared, nil
+}
 
-		artifacts = append(artifacts, LegacyCosignArtifact{
-			Digest:       desc.Digest,
-			Size:         desc.Size,
-			MediaType:    desc.MediaType,
-			ArtifactType: artifactType,
-			TagSuffix:    suffix,
-		})
+// HeadReference resolves an image reference to the descriptor of the manifest it
+// points at using a HEAD request, without downloading the manifest.
+func (c *Client) HeadReference(
+	ctx context.Context, imageRef string,
+) (name.Reference, *v1.Descriptor, error) {
+	ref, err := c.ParseReference(imageRef)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return artifacts
+	desc, err := c.headReference(ctx, ref)
+	if err != nil {
+		return ref, nil, err
+	}
+
+	return ref, desc, nil
 }
 
-// ResolveDigest resolves an image reference to a digest and repository.
-func (c *Client) ResolveDigest(
-	ctx context.Context, imageRef string,
-) (name.Repository, v1.Hash, error) {
-	ref, err := name.ParseReference(imageRef)
+// ListTags lists all tags for a repository.
+// If the context ends after the first page was read, it returns the tags read
+// so far with an error wrapping ErrIncomplete.
+func (c *Client) ListTags(ctx context.Context, repoName string) ([]string, error) {
+	repo, err := c.NewRepository(repoName)
 	if err != nil {
-		return name.Repository{}, v1.Hash{},
-			fmt.Errorf("parsing image reference: %w", err)
+		return nil, err
 	}
 
-	options := c.optionsWith(remote.WithContext(ctx))
+	return c.listTags(ctx, repo)
+}
 
-	digestRef, ok := ref.(name.Digest)
-	if ok {
-		digest, err := v1.NewHash(digestRef.Identifier())
+// listTags lists the tags of a parsed repository.
+func (c *Client) listTags(ctx context.Context, repo name.Repository) ([]string, error) {
+	tags, err := coalesceProgress(ctx, c, repo.Registry, "tags "+repo.String(), func(
+		ctx context.Context, publish func([]string),
+	) ([]string, error) {
+		puller, err := remote.NewPuller(c.optionsWith(remote.WithContext(ctx))...)
 		if err != nil {
-			return name.Repository{}, v1.Hash{},
-				fmt.Errorf("parsing digest: %w", err)
+			return nil, err
+		}
+		lister, err := puller.Lister(ctx, repo)
+		if err != nil {
+			return nil, err
 		}
-		return ref.Context(), digest, nil
-	}
 
-	desc, err := remote.Head(ref, options...)
+		tags := []string{}
+		for lister.HasNext() {
+			page, err := lister.Next(ctx)
+			if err != nil {
+				if err = incomplete(ctx, err); errors.Is(err, ErrIncomplete) {
+					served(ctx, repo)
+				}
+				return tags, err
+			}
+			tags = append(tags, page.Tags...)
+			publish(slices.Clip(tags))
+		}
+		served(ctx, repo)
+		return tags, nil
+	})
+	// Callers sharing the listing each get their own copy to sort.
+	tags = slices.Clone(tags)
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) && len(tags) > 0 {
+		// The caller gave up on a listing it shared after a page was read.
+		err = incomplete(ctx, err)
+	}
 	if err != nil {
-		return name.Repository{}, v1.Hash{},
-			fmt.Errorf("resolving image digest: %w", err)
+		return partialResult(tags, err), fmt.Errorf("listing tags: %w", err)
 	}
 
-	return ref.Context(), desc.Digest, nil
+	return tags, nil
 }
 
-// GetArtifactContent fetches the content of an artifact by repository and digest.
-// It returns the first layer's content, its media type, and any error.
-func (c *Client) GetArtifactContent(ctx context.Context, repo, digest string) ([]byte, types.MediaType, error) {
-	ref, err := name.NewDigest(repo + "@" + digest)
+// ListRepositories lists all repositories in a registry via the /v2/_catalog endpoint.
+// It returns an error wrapping ErrCatalogUnsupported if the registry disables the catalog,
+// and the repositories read so far with an error wrapping ErrIncomplete if the
+// context ends after the first page was read.
+func (c *Client) ListRepositories(ctx context.Context, registryName string) ([]string, error) {
+	reg, err := c.NewRegistry(registryName)
 	if err != nil {
-		return nil, "", fmt.Errorf("parsing artifact reference: %w", err)
+		return nil, err
 	}
 
-	options := c.optionsWith(remote.WithContext(ctx))
-	img, err := remote.Image(ref, options...)
+	puller, err := remote.NewPuller(c.optionsWith(remote.WithContext(ctx))...)
 	if err != nil {
-		return nil, "", fmt.Errorf("fetching artifact: %w", err)
+		return nil, fmt.Errorf("listing repositories: %w", err)
 	}
-
-	layers, err := img.Layers()
+	catalogger, err := puller.Catalogger(ctx, reg)
 	if err != nil {
-		return nil, "", fmt.Errorf("getting artifact layers: %w", err)
+		if isCatalogUnsupported(err) {
+			return nil, fmt.Errorf("listing repositories: %w: %w", ErrCatalogUnsupported, err)
+		}
+		return nil, fmt.Errorf("listing repositories: %w", err)
 	}
 
-	if len(layers) == 0 {
-		return nil, "", fmt.Errorf("artifact has no layers")
+	repos := []string{}
+	for catalogger.HasNext() {
+		page, err := catalogger.Next(ctx)
+		if err != nil {
+			err = incomplete(ctx, err)
+			return partialResult(repos, err), fmt.Errorf("listing repositories: %w", err)
+		}
+		repos = append(repos, page.Repos...)
 	}
 
-	layer := layers[0]
-	mediaType, err := layer.MediaType()
-	if err != nil {
-		return nil, "", fmt.Errorf("getting layer media type: %w", err)
-	}
+	return repos, nil
+}
 
-	rc, err := layer.Compressed()
-	if err != nil {
-		return nil, "", fmt.Errorf("reading layer content: %w", err)
+// incomplete marks an error reading a later page of a listing as leaving the
+// listing incomplete when it was caused by the context ending.
+func incomplete(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		return err
 	}
-	defer rc.Close()
+	return fmt.Errorf("%w: %w", ErrIncomplete, err)
+}
 
-	content, err := io.ReadAll(rc)
-	if err != nil {
-		return nil, "", fmt.Errorf("reading layer content: %w", err)
+// partialResult returns the items read by a listing that failed with err,
+// which are only meaningful when err wraps ErrIncomplete.
+func partialResult(items []string, err error) []string {
+	if errors.Is(err, ErrIncomplete) {
+		return items
 	}
-
-	return content, mediaType, nil
+	return nil
 }
 
-// ListTags lists all tags for a repository.
-func (c *Client) ListTags(ctx context.Context, repoName string) ([]string, error) {
-	repo, err := name.NewRepository(repoName)
-	if err != nil {
-		return nil, fmt.Errorf("parsing repository name: %w", err)
+// isCatalogUnsupported reports whether a catalog error indicates the endpoint
+// is unavailable on the registry rather than a transient or auth failure.
+func isCatalogUnsupported(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
 	}
 
-	options := c.optionsWith(remote.WithContext(ctx))
-	tags, err := remote.List(repo, options...)
-	if err != nil {
-		return nil, fmt.Errorf("listing tags: %w", err)
+	switch terr.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
 	}
 
-	return tags, nil
+	for _, diag := range terr.Errors {
+		if diag.Code == transport.UnsupportedErrorCode {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1,7 +1,12 @@
 package oci
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,21 +23,21 @@ func TestNewClient(t *testing.T) {
 
 func TestGetImage_InvalidReference(t *testing.T) {
 	client := NewClient()
-	_, err := client.GetImage(t.Context(), "invalid:reference:format")
+	_, err := GetImage(t.Context(), client, "invalid:reference:format")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "parsing image reference")
 }
 
 func TestGetImageManifest_InvalidReference(t *testing.T) {
 	client := NewClient()
-	_, err := client.GetImageManifest(t.Context(), "invalid:reference:format")
+	_, err := GetImageManifest(t.Context(), client, "invalid:reference:format")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "parsing image reference")
 }
 
 func TestGetImageConfig_InvalidReference(t *testing.T) {
 	client := NewClient()
-	_, err := client.GetImageConfig(t.Context(), "invalid:reference:format")
+	_, err := GetImageConfig(t.Context(), client, "invalid:reference:format")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "parsing image reference")
 }
@@ -44,6 +49,14 @@ func TestListTags_InvalidRepository(t *testing.T) {
 	assert.Contains(t, err.Error(), "listing tags")
 }
 
+func TestHeadReference_InvalidReference(t *testing.T) {
+	client := NewClient()
+	ref, _, err := client.HeadReference(t.Context(), "invalid:reference:format")
+	require.Error(t, err)
+	assert.Nil(t, ref)
+	assert.Contains(t, err.Error(), "parsing image reference")
+}
+
 func TestListReferrers_InvalidReference(t *testing.T) {
 	client := NewClient()
 	_, err := client.ListReferrers(t.Context(), "invalid:reference:format", "")
@@ -53,18 +66,67 @@ func TestListReferrers_InvalidReference(t *testing.T) {
 
 func TestGetArtifactContent_InvalidDigest(t *testing.T) {
 	client := NewClient()
-	_, _, err := client.GetArtifactContent(t.Context(), "docker.io/library/alpine", "notadigest")
+	_, _, err := GetArtifactContent(t.Context(), client, "docker.io/library/alpine", "notadigest")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "parsing artifact reference")
 }
 
 func TestGetArtifactContent_InvalidRepo(t *testing.T) {
 	client := NewClient()
-	_, _, err := client.GetArtifactContent(t.Context(), "INVALID", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	_, _, err := GetArtifactContent(t.Context(), client, "INVALID", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "parsing artifact reference")
 }
 
+func TestListRepositories_CatalogUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	_, err := client.ListRepositories(t.Context(), strings.TrimPrefix(server.URL, "http://"))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrCatalogUnsupported)
+}
+
+// stalledTagsServer serves the first page of a tag listing and stalls on the
+// next one until the client gives up.
+func stalledTagsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/app/tags/list?n=2&last=v2>; rel="next"`)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"app","tags":["v1","v2"]}`))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestListTags_IncompleteOnDeadline(t *testing.T) {
+	server := stalledTagsServer(t)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+
+	client := NewClient()
+	tags, err := client.ListTags(ctx, strings.TrimPrefix(server.URL, "http://")+"/app")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrIncomplete)
+	assert.Equal(t, []string{"v1", "v2"}, tags)
+}
+
 func TestWithBearerToken(t *testing.T) {
 	// Test that WithBearerToken returns a valid remote.Option
 	option := WithBearerToken("test-token")
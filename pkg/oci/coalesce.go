@@ -0,0 +1,187 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// coalescedTimeout bounds a shared run of an operation. The run is detached
+// from the cancellation of the callers sharing it, so one of them giving up
+// does not fail the others.
+const coalescedTimeout = 5 * time.Minute
+
+// flightGroup coalesces concurrent identical operations.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a shared run of an operation.
+type flight struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	// value, err and endpoints are set before done is closed.
+	value     any
+	err       error
+	endpoints []EndpointUse
+
+	// waiters counts the callers waiting for the run, guarded by the
+	// group's mutex.
+	waiters int
+
+	// mu guards progress, the latest partial result the run published.
+	mu       sync.Mutex
+	progress any
+}
+
+// defaultInflight coalesces the registry operations of clients without a config.
+var defaultInflight flightGroup
+
+// inflight returns the group coalescing the operations of clients created
+// with cfg.
+func (cfg *Config) inflight() *flightGroup {
+	if cfg == nil {
+		return &defaultInflight
+	}
+	return &cfg.inflightOps
+}
+
+// coalesce runs op, sharing a single in-flight run between concurrent callers
+// passing the same key whose clients access registry with the same
+// credentials, see Client.cacheScope. Clients without a scope run op on
+// their own.
+func coalesce[T any](
+	ctx context.Context, c *Client, registry name.Registry, key string, op func(context.Context) (T, error),
+) (T, error) {
+	return coalesceProgress(ctx, c, registry, key, func(ctx context.Context, _ func(T)) (T, error) {
+		return op(ctx)
+	})
+}
+
+// coalesceProgress is coalesce for operations reading a result in parts, such
+// as paginated listings. The run publishes each partial result, and a caller
+// giving up before the run ends gets the latest one with its context's
+// error.
+//
+// The run uses a context detached from the cancellation of the caller that
+// started it, keeping its values, with its own timeout: coalescedTimeout, or
+// the caller's deadline when it is sooner, so retries still give up in time.
+// The run is canceled early once every caller sharing it has given up, and
+// callers with time left when the deadline cuts it short run op themselves.
+// Callers get their own copy of what the run records in its trace.
+func coalesceProgress[T any](
+	ctx context.Context, c *Client, registry name.Registry, key string,
+	op func(ctx context.Context, publish func(T)) (T, error),
+) (T, error) {
+	scope, ok := c.cacheScope(ctx, registry)
+	if !ok {
+		return op(ctx, func(T) {})
+	}
+
+	group := c.config.inflight()
+	key = scope + "\x00" + key
+	f, leader := group.join(key)
+	if leader {
+		timeout := coalescedTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = min(timeout, time.Until(deadline))
+		}
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		f.cancel = cancel
+		go runFlight(runCtx, f, op, func() { group.forget(key, f) })
+	}
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		group.leave(key, f)
+		partial, _ := f.latest().(T)
+		return partial, ctx.Err()
+	}
+
+	if leader {
+		value, _ := f.value.(T)
+		return value, f.err
+	}
+	if errors.Is(f.err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return op(ctx, func(T) {})
+	}
+	trace := traceFromContext(ctx)
+	for _, use := range f.endpoints {
+		trace.recordEndpoint(use)
+	}
+	value, _ := f.value.(T)
+	return value, f.err
+}
+
+// join returns the flight running under key, starting one when there is
+// none, and whether the caller started it.
+func (g *flightGroup) join(key string) (*flight, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		f.waiters++
+		return f, false
+	}
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f := &flight{done: make(chan struct{}), waiters: 1}
+	g.flights[key] = f
+	return f, true
+}
+
+// forget removes f from the group, so later callers start a new run.
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// runFlight runs op for f under a context detached from ctx, with its own
+// timeout, and records its outcome. The trace of the caller that started the
+// run, reached through the detached context, receives what the run records
+// as it happens.
+func runFlight[T any](
+	ctx context.Context, f *flight, op func(context.Context, func(T)) (T, error), done func(),
+) {
+	defer f.cancel()
+	runCtx, trace := WithTrace(ctx)
+	value, err := op(runCtx, func(partial T) {
+		f.mu.Lock()
+		f.progress = partial
+		f.mu.Unlock()
+	})
+	f.value, f.err, f.endpoints = value, err, trace.Endpoints()
+	done()
+	close(f.done)
+}
+
+// leave records that a caller stopped waiting for f, canceling the run and
+// removing it from the group when no caller is left.
+func (g *flightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	f.cancel()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// latest returns the latest partial result the run published.
+func (f *flight) latest() any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.progress
+}
@@ -0,0 +1,205 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedRegistry serves an in-memory registry whose manifest requests by tag
+// wait for gate to be called, counting them.
+func gatedRegistry(t *testing.T, gate func(r *http.Request)) (string, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "/manifests/v1") {
+			requests.Add(1)
+			gate(r)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), &requests
+}
+
+func TestCoalesce_SharesConcurrentRequests(t *testing.T) {
+	release := make(chan struct{})
+	var pushed atomic.Bool
+	host, requests := gatedRegistry(t, func(*http.Request) {
+		if pushed.Load() {
+			<-release
+		}
+	})
+	want := pushRandom(t, host+"/app:v1")
+	pushed.Store(true)
+	requests.Store(0)
+
+	// Separate clients share in-flight requests when they have the same
+	// credentials; clients with remote options never share them.
+	clients := []*Client{
+		NewClient().WithAuth(&authn.Basic{Username: "caller", Password: "secret"}),
+		NewClient().WithAuth(&authn.Basic{Username: "caller", Password: "secret"}),
+		NewClient().WithAuth(&authn.Basic{Username: "caller", Password: "secret"}),
+		NewClient().WithAuth(&authn.Basic{Username: "other", Password: "secret"}),
+		NewClient().WithAuth(&authn.Basic{Username: "other", Password: "secret"}),
+		NewClient(WithBasicAuth("caller", "secret")),
+		NewClient(WithBasicAuth("caller", "secret")),
+	}
+	digests := make([]string, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, digest, err := ResolveDigest(t.Context(), client, host+"/app:v1")
+			assert.NoError(t, err)
+			digests[i] = digest.String()
+		}()
+	}
+	require.Eventually(t, func() bool { return requests.Load() >= 4 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(4), requests.Load(), "one request per credentials, one per unscoped client")
+	for _, digest := range digests {
+		assert.Equal(t, want, digest)
+	}
+}
+
+func TestCoalesce_WaiterOutlivesCanceledCaller(t *testing.T) {
+	var pushed atomic.Bool
+	first := make(chan struct{})
+	release := make(chan struct{})
+	host, requests := gatedRegistry(t, func(*http.Request) {
+		if pushed.Load() {
+			close(first)
+			<-release
+		}
+	})
+	want := pushRandom(t, host+"/app:v1")
+	pushed.Store(true)
+	requests.Store(0)
+
+	client := NewClient()
+	ctx, cancel := context.WithCancel(t.Context())
+	leaderDone := make(chan error)
+	go func() {
+		_, _, err := ResolveDigest(ctx, client, host+"/app:v1")
+		leaderDone <- err
+	}()
+	<-first
+
+	waiterDone := make(chan string)
+	go func() {
+		_, digest, err := ResolveDigest(t.Context(), client, host+"/app:v1")
+		assert.NoError(t, err)
+		waiterDone <- digest.String()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The caller that started the request gives up without waiting for it,
+	// and the request goes on for the caller still waiting.
+	cancel()
+	require.ErrorIs(t, <-leaderDone, context.Canceled)
+	close(release)
+	assert.Equal(t, want, <-waiterDone)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestCoalesce_CancelsAbandonedRequests(t *testing.T) {
+	var pushed atomic.Bool
+	started := make(chan struct{})
+	abandoned := make(chan struct{})
+	host, _ := gatedRegistry(t, func(r *http.Request) {
+		if pushed.Load() {
+			close(started)
+			<-r.Context().Done()
+			close(abandoned)
+		}
+	})
+	pushRandom(t, host+"/app:v1")
+	pushed.Store(true)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() {
+		_, _, err := ResolveDigest(ctx, NewClientWithConfig(&Config{Retry: RetryPolicy{MaxAttempts: 1}}), host+"/app:v1")
+		done <- err
+	}()
+	<-started
+	cancel()
+
+	require.ErrorIs(t, <-done, context.Canceled)
+	select {
+	case <-abandoned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not canceled once no caller waited for it")
+	}
+}
+
+func TestCoalesce_SharesBlobReads(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/sha256:") {
+			requests.Add(1)
+			<-release
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(host + "/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	digest, err := layers[0].Digest()
+	require.NoError(t, err)
+	size, err := layers[0].Size()
+	require.NoError(t, err)
+
+	const callers = 3
+	blobs := make([][]byte, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			blob, err := NewClient().GetBlob(t.Context(), host+"/app", digest.String(), 1024)
+			assert.NoError(t, err)
+			blobs[i] = blob
+		}()
+	}
+	require.Eventually(t, func() bool { return requests.Load() > 0 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), requests.Load())
+	for _, blob := range blobs {
+		assert.Len(t, blob, int(size))
+	}
+	// Each caller gets its own copy.
+	blobs[0][0] ^= 0xff
+	assert.NotEqual(t, blobs[0], blobs[1])
+}
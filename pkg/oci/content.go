@@ -0,0 +1,301 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// defaultPlatform is the platform GetImage picks from an index, as
+// go-containerregistry does.
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// GetImage returns the image a reference points at, read through r; for an
+// index, the linux/amd64 image. Each call builds its own image from the
+// manifest, reading the config and layers with GetBlob when they are first
+// used, so images never share state between callers.
+func GetImage(ctx context.Context, r ContentReader, imageRef string) (v1.Image, error) {
+	repo, _, err := RepositoryOf(r, imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, desc, err := r.GetManifest(ctx, imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("fetching image: %w", err)
+	}
+	if desc.MediaType.IsIndex() {
+		child, err := platformChild(raw, defaultPlatform)
+		if err != nil {
+			return nil, fmt.Errorf("fetching image: %w", err)
+		}
+		raw, desc, err = r.GetManifest(ctx, repo+"@"+child.String())
+		if err != nil {
+			return nil, fmt.Errorf("fetching image: %w", err)
+		}
+	}
+
+	img, err := newContentImage(ctx, r, repo, raw, desc.MediaType)
+	if err != nil {
+		return nil, fmt.Errorf("fetching image: %w", err)
+	}
+	return img, nil
+}
+
+// GetImageManifest returns the manifest of the image a reference points at.
+func GetImageManifest(ctx context.Context, r ContentReader, imageRef string) (*v1.Manifest, error) {
+	img, err := GetImage(ctx, r, imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("getting manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// GetImageConfig returns the config of the image a reference points at.
+func GetImageConfig(ctx context.Context, r ContentReader, imageRef string) (*v1.ConfigFile, error) {
+	img, err := GetImage(ctx, r, imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("getting config: %w", err)
+	}
+
+	return config, nil
+}
+
+// platformChild returns the digest of the child of an index matching platform.
+func platformChild(raw []byte, platform v1.Platform) (v1.Hash, error) {
+	index, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	for _, child := range index.Manifests {
+		if child.Platform != nil && child.Platform.Satisfies(platform) {
+			return child.Digest, nil
+		}
+	}
+	return v1.Hash{}, fmt.Errorf("no child with platform %s in index", platform.String())
+}
+
+// ResolveDigest resolves an image reference to a digest and the repository
+// holding it, as HeadReference does, except that digest references are
+// resolved without a request. For local references, the repository is the
+// location of the image layout or archive.
+func ResolveDigest(ctx context.Context, r ContentReader, imageRef string) (string, v1.Hash, error) {
+	repo, _, err := RepositoryOf(r, imageRef)
+	if err != nil {
+		return "", v1.Hash{}, err
+	}
+
+	if !IsLocalReference(imageRef) {
+		ref, err := r.ParseReference(imageRef)
+		if err != nil {
+			return "", v1.Hash{}, err
+		}
+		if digest, ok := ref.(name.Digest); ok {
+			hash, err := v1.NewHash(digest.DigestStr())
+			if err != nil {
+				return "", v1.Hash{}, fmt.Errorf("parsing digest: %w", err)
+			}
+			return repo, hash, nil
+		}
+	}
+
+	_, desc, err := r.HeadReference(ctx, imageRef)
+	if err != nil {
+		return "", v1.Hash{}, err
+	}
+	return repo, desc.Digest, nil
+}
+
+// LegacyCosignArtifact represents an artifact found via legacy cosign tag scheme.
+type LegacyCosignArtifact struct {
+	Digest       v1.Hash
+	Size         int64
+	MediaType    types.MediaType
+	ArtifactType string
+	TagSuffix    string // "sig", "att", or "sbom"
+}
+
+// legacyCosignSuffixes maps tag suffixes to their artifact types.
+var legacyCosignSuffixes = map[string]string{
+	"sig":  "application/vnd.dev.cosign.artifact.sig.v1+json",
+	"att":  "application/vnd.dsse.envelope.v1+json",
+	"sbom": "application/vnd.dev.cosign.artifact.sbom.v1+json",
+}
+
+// ListLegacyCosignArtifacts discovers artifacts stored via the legacy
+// cosign tag scheme (sha256-<hex>.sig, .att, .sbom) in a repository.
+func ListLegacyCosignArtifacts(
+	ctx context.Context, r ContentReader, repoName string, imageDigest v1.Hash,
+) []LegacyCosignArtifact {
+	var artifacts []LegacyCosignArtifact
+	for suffix, artifactType := range legacyCosignSuffixes {
+		tagName := fmt.Sprintf("sha256-%s.%s", imageDigest.Hex, suffix)
+
+		_, desc, err := r.HeadReference(ctx, repoName+":"+tagName)
+		if err != nil {
+			continue
+		}
+
+		artifacts = append(artifacts, LegacyCosignArtifact{
+			Digest:       desc.Digest,
+			Size:         desc.Size,
+			MediaType:    desc.MediaType,
+			ArtifactType: artifactType,
+			TagSuffix:    suffix,
+		})
+	}
+
+	return artifacts
+}
+
+// GetArtifactContent fetches the content of an artifact by repository and
+// digest. It returns the first layer's content, its media type, and any error.
+func GetArtifactContent(
+	ctx context.Context, r ContentReader, repo, digest string,
+) ([]byte, types.MediaType, error) {
+	imageRef := repo + "@" + digest
+	if _, _, err := RepositoryOf(r, imageRef); err != nil {
+		return nil, "", fmt.Errorf("parsing artifact reference: %w", err)
+	}
+
+	img, err := GetImage(ctx, r, imageRef)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching artifact: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, "", fmt.Errorf("getting artifact layers: %w", err)
+	}
+
+	if len(layers) == 0 {
+		return nil, "", errors.New("artifact has no layers")
+	}
+
+	layer := layers[0]
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return nil, "", fmt.Errorf("getting layer media type: %w", err)
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, "", fmt.Errorf("reading layer content: %w", err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading layer content: %w", err)
+	}
+
+	return content, mediaType, nil
+}
+
+// contentImage is an image whose config and layers are read from a
+// ContentReader.
+type contentImage struct {
+	ctx       context.Context
+	r         ContentReader
+	repo      string
+	raw       []byte
+	mediaType types.MediaType
+	manifest  *v1.Manifest
+	config    func() ([]byte, error)
+}
+
+var _ partial.CompressedImageCore = (*contentImage)(nil)
+
+// newContentImage returns the image with the given raw manifest in repo.
+func newContentImage(
+	ctx context.Context, r ContentReader, repo string, raw []byte, mediaType types.MediaType,
+) (v1.Image, error) {
+	manifest, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+
+	img := &contentImage{ctx: ctx, r: r, repo: repo, raw: raw, mediaType: mediaType, manifest: manifest}
+	img.config = sync.OnceValues(func() ([]byte, error) {
+		return r.GetBlob(ctx, repo, manifest.Config.Digest.String(), manifest.Config.Size)
+	})
+	return partial.CompressedToImage(img)
+}
+
+// RawManifest implements partial.CompressedImageCore.
+func (i *contentImage) RawManifest() ([]byte, error) {
+	return i.raw, nil
+}
+
+// MediaType implements partial.CompressedImageCore.
+func (i *contentImage) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+// RawConfigFile implements partial.CompressedImageCore.
+func (i *contentImage) RawConfigFile() ([]byte, error) {
+	return i.config()
+}
+
+// LayerByDigest implements partial.CompressedImageCore.
+func (i *contentImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	for _, desc := range i.manifest.Layers {
+		if desc.Digest == h {
+			return &contentLayer{image: i, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s not found in manifest", h)
+}
+
+// contentLayer is a layer of a contentImage. Its content is read in full
+// with GetBlob, bounded by the size the manifest declares.
+type contentLayer struct {
+	image *contentImage
+	desc  v1.Descriptor
+}
+
+// Digest implements partial.CompressedLayer.
+func (l *contentLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+// Size implements partial.CompressedLayer.
+func (l *contentLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+// MediaType implements partial.CompressedLayer.
+func (l *contentLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
+
+// Compressed implements partial.CompressedLayer.
+func (l *contentLayer) Compressed() (io.ReadCloser, error) {
+	blob, err := l.image.r.GetBlob(l.image.ctx, l.image.repo, l.desc.Digest.String(), l.desc.Size)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(blob)), nil
+}
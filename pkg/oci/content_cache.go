@@ -0,0 +1,404 @@
+package oci
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Content cache defaults used when ContentCacheOptions does not override them.
+const (
+	defaultContentCacheMaxBytes      = 64 << 20
+	defaultContentCacheMaxEntryBytes = 8 << 20
+	defaultContentCacheMaxDiskBytes  = 1 << 30
+
+	// sharedCacheScope names the on-disk directory of the empty scope.
+	sharedCacheScope = "shared"
+)
+
+// ContentCacheOptions configures a ContentCache.
+type ContentCacheOptions struct {
+	// MaxBytes is the in-memory cache size. 0 uses 64 MiB.
+	MaxBytes int64
+	// MaxEntryBytes is the largest manifest or blob that is cached; larger
+	// content, such as most image layers, is always fetched. 0 uses 8 MiB.
+	MaxEntryBytes int64
+	// Dir optionally persists cached content on disk, so it survives restarts.
+	Dir string
+	// MaxDiskBytes is the on-disk cache size, evicting the least recently used
+	// content first. 0 uses 1 GiB.
+	MaxDiskBytes int64
+}
+
+// ContentCache caches manifests and blobs fetched by digest. Since
+// digest-addressed content is immutable, entries never go stale; content is
+// verified against its digest before it is cached. Entries are partitioned by
+// scope, the credentials the content was fetched with, see WithContentCache
+// and NewBackend, and by repository, since registries grant access to content
+// per repository.
+type ContentCache struct {
+	opts ContentCacheOptions
+
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[contentKey]*list.Element
+	size     int64
+	diskSize int64
+}
+
+// contentKey identifies cached content by the scope it was fetched in, the
+// repository it was fetched from and the content's digest.
+type contentKey struct {
+	scope      string
+	repository string
+	digest     v1.Hash
+}
+
+// contentEntry is a cached manifest or blob.
+type contentEntry struct {
+	key       contentKey
+	mediaType string
+	body      []byte
+}
+
+// NewContentCache returns a content cache with the given options, creating
+// the on-disk cache directory if one is configured.
+func NewContentCache(opts ContentCacheOptions) (*ContentCache, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultContentCacheMaxBytes
+	}
+	if opts.MaxEntryBytes <= 0 {
+		opts.MaxEntryBytes = defaultContentCacheMaxEntryBytes
+	}
+	if opts.MaxDiskBytes <= 0 {
+		opts.MaxDiskBytes = defaultContentCacheMaxDiskBytes
+	}
+
+	c := &ContentCache{opts: opts, lru: list.New(), entries: map[contentKey]*list.Element{}}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating content cache directory: %w", err)
+		}
+		files, err := c.diskFiles()
+		if err != nil {
+			return nil, fmt.Errorf("reading content cache directory: %w", err)
+		}
+		for _, f := range files {
+			c.diskSize += f.size
+		}
+	}
+	return c, nil
+}
+
+// get returns the cached content for key, loading it from disk into memory
+// if necessary.
+func (c *ContentCache) get(key contentKey) (*contentEntry, bool) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*contentEntry), true
+	}
+	c.mu.Unlock()
+
+	entry, ok := c.readDisk(key)
+	if ok {
+		c.putMemory(entry)
+	}
+	return entry, ok
+}
+
+// put caches a copy of content after checking it matches the key's digest.
+func (c *ContentCache) put(key contentKey, mediaType string, body []byte) {
+	if int64(len(body)) > c.opts.MaxEntryBytes || !matchesDigest(key.digest, body) {
+		return
+	}
+
+	entry := &contentEntry{key: key, mediaType: mediaType, body: slices.Clone(body)}
+	c.putMemory(entry)
+	c.writeDisk(entry)
+}
+
+// putMemory adds an entry to the in-memory LRU, evicting the least recently
+// used entries to stay within MaxBytes.
+func (c *ContentCache) putMemory(entry *contentEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += int64(len(entry.body))
+	for c.size > c.opts.MaxBytes {
+		oldest := c.lru.Back()
+		evicted := c.lru.Remove(oldest).(*contentEntry)
+		delete(c.entries, evicted.key)
+		c.size -= int64(len(evicted.body))
+	}
+}
+
+// path returns the on-disk location of a cached digest. Scopes and
+// repositories are hashed so they are safe to use as directory names.
+func (c *ContentCache) path(key contentKey) string {
+	scope := sharedCacheScope
+	if key.scope != "" {
+		scope = pathHash(key.scope)
+	}
+	return filepath.Join(c.opts.Dir, scope, pathHash(key.repository), key.digest.Algorithm, key.digest.Hex)
+}
+
+// pathHash returns a short hash of s for use as a directory name.
+func pathHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// readDisk loads an entry from the on-disk cache, if configured. Files hold
+// the media type on the first line followed by the content.
+func (c *ContentCache) readDisk(key contentKey) (*contentEntry, bool) {
+	if c.opts.Dir == "" {
+		return nil, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	mediaType, body, ok := bytes.Cut(data, []byte("\n"))
+	if !ok || !matchesDigest(key.digest, body) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return &contentEntry{key: key, mediaType: string(mediaType), body: body}, true
+}
+
+// writeDisk persists an entry to the on-disk cache, if configured, evicting
+// the least recently used files to stay within MaxDiskBytes.
+func (c *ContentCache) writeDisk(entry *contentEntry) {
+	if c.opts.Dir == "" {
+		return
+	}
+
+	path := c.path(entry.key)
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	size := int64(len(entry.mediaType) + 1 + len(entry.body))
+	if size > c.opts.MaxDiskBytes || os.MkdirAll(filepath.Dir(path), 0o700) != nil {
+		return
+	}
+
+	// Write to a temporary file first so concurrent readers never see
+	// partial content.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(tmp)
+	_, _ = w.WriteString(entry.mediaType + "\n")
+	_, _ = w.Write(entry.body)
+	if w.Flush() != nil || tmp.Close() != nil || os.Rename(tmp.Name(), path) != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	c.mu.Lock()
+	c.diskSize += size
+	overLimit := c.diskSize > c.opts.MaxDiskBytes
+	c.mu.Unlock()
+	if overLimit {
+		c.evictDisk()
+	}
+}
+
+// diskFile is a file in the on-disk cache.
+type diskFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// diskFiles lists the files in the on-disk cache.
+func (c *ContentCache) diskFiles() ([]diskFile, error) {
+	var files []diskFile
+	err := filepath.WalkDir(c.opts.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, diskFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// evictDisk removes the least recently used files until the on-disk cache is
+// within MaxDiskBytes.
+func (c *ContentCache) evictDisk() {
+	files, err := c.diskFiles()
+	if err != nil {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.diskSize = 0
+	for _, f := range files {
+		c.diskSize += f.size
+	}
+	for _, f := range files {
+		if c.diskSize <= c.opts.MaxDiskBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			c.diskSize -= f.size
+		}
+	}
+}
+
+// matchesDigest reports whether body hashes to digest. Only sha256 content
+// is cached.
+func matchesDigest(digest v1.Hash, body []byte) bool {
+	if digest.Algorithm != "sha256" {
+		return false
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]) == digest.Hex
+}
+
+// descriptor describes the cached content.
+func (e *contentEntry) descriptor() v1.Descriptor {
+	return v1.Descriptor{
+		MediaType: types.MediaType(e.mediaType),
+		Size:      int64(len(e.body)),
+		Digest:    e.key.digest,
+	}
+}
+
+// contentCacheBackend serves manifests and blobs requested by digest from a
+// ContentCache, fetching them through the Backend it wraps on a miss.
+type contentCacheBackend struct {
+	Backend
+	cache *ContentCache
+	scope CacheScope
+}
+
+// WithContentCache returns a Backend serving manifests and blobs requested by
+// digest from cache, with entries partitioned by scope, and fetching
+// everything else from b. Requests to registries without a scope bypass the
+// cache.
+func WithContentCache(b Backend, cache *ContentCache, scope CacheScope) Backend {
+	return &contentCacheBackend{Backend: b, cache: cache, scope: scope}
+}
+
+// cached returns the content cached for a digest of repo, recording the
+// lookup in the context's trace, and the key to cache it under. The key is
+// nil when the repository's registry has no scope.
+func (c *contentCacheBackend) cached(
+	ctx context.Context, repo name.Repository, digest v1.Hash,
+) (*contentKey, *contentEntry, bool) {
+	scope, ok := c.scope(ctx, repo.Registry)
+	if !ok {
+		return nil, nil, false
+	}
+	key := &contentKey{scope: scope, repository: repo.Name(), digest: digest}
+	entry, ok := c.cache.get(*key)
+	traceFromContext(ctx).recordCache(ok)
+	return key, entry, ok
+}
+
+// digestOf returns the digest a reference names, if it is a digest reference.
+func (c *contentCacheBackend) digestOf(imageRef string) (name.Reference, v1.Hash, bool) {
+	ref, err := c.ParseReference(imageRef)
+	if err != nil {
+		return nil, v1.Hash{}, false
+	}
+	digest, ok := ref.(name.Digest)
+	if !ok {
+		return nil, v1.Hash{}, false
+	}
+	hash, err := v1.NewHash(digest.DigestStr())
+	if err != nil || hash.Algorithm != "sha256" {
+		return nil, v1.Hash{}, false
+	}
+	return ref, hash, true
+}
+
+// HeadReference implements Backend.
+func (c *contentCacheBackend) HeadReference(
+	ctx context.Context, imageRef string,
+) (name.Reference, *v1.Descriptor, error) {
+	if ref, digest, ok := c.digestOf(imageRef); ok {
+		if _, entry, ok := c.cached(ctx, ref.Context(), digest); ok {
+			desc := entry.descriptor()
+			return ref, &desc, nil
+		}
+	}
+	return c.Backend.HeadReference(ctx, imageRef)
+}
+
+// GetManifest implements Backend.
+func (c *contentCacheBackend) GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	ref, digest, ok := c.digestOf(imageRef)
+	if !ok {
+		return c.Backend.GetManifest(ctx, imageRef)
+	}
+	key, entry, ok := c.cached(ctx, ref.Context(), digest)
+	if ok {
+		return slices.Clone(entry.body), entry.descriptor(), nil
+	}
+
+	raw, desc, err := c.Backend.GetManifest(ctx, imageRef)
+	if err == nil && key != nil {
+		c.cache.put(*key, string(desc.MediaType), raw)
+	}
+	return raw, desc, err
+}
+
+// GetBlob implements Backend.
+func (c *contentCacheBackend) GetBlob(ctx context.Context, repo, digest string, maxBytes int64) ([]byte, error) {
+	hash, err := v1.NewHash(digest)
+	if err != nil || hash.Algorithm != "sha256" {
+		return c.Backend.GetBlob(ctx, repo, digest, maxBytes)
+	}
+	repository, err := c.NewRepository(repo)
+	if err != nil {
+		return c.Backend.GetBlob(ctx, repo, digest, maxBytes)
+	}
+	key, entry, ok := c.cached(ctx, repository, hash)
+	if ok && int64(len(entry.body)) <= maxBytes {
+		return slices.Clone(entry.body), nil
+	}
+
+	blob, err := c.Backend.GetBlob(ctx, repo, digest, maxBytes)
+	if err == nil && key != nil {
+		c.cache.put(*key, "", blob)
+	}
+	return blob, err
+}
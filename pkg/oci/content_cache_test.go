@@ -0,0 +1,248 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestContentCache returns a content cache with the given options.
+func newTestContentCache(t *testing.T, opts ContentCacheOptions) *ContentCache {
+	t.Helper()
+	cache, err := NewContentCache(opts)
+	require.NoError(t, err)
+	return cache
+}
+
+// testContent returns a content cache key for body in scope, fetched from a
+// test repository.
+func testContent(scope, body string) contentKey {
+	sum := sha256.Sum256([]byte(body))
+	return contentKey{
+		scope:      scope,
+		repository: "registry.example/app",
+		digest:     v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])},
+	}
+}
+
+// redirectingRegistry serves an in-memory registry that redirects blob
+// requests to /storage, counting the manifest and blob requests it serves.
+func redirectingRegistry(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/storage/"):
+			fetches.Add(1)
+			r.URL.Path = strings.TrimPrefix(r.URL.Path, "/storage")
+		case strings.Contains(r.URL.Path, "/blobs/sha256:") && r.Method == http.MethodGet:
+			http.Redirect(w, r, "/storage"+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		case strings.Contains(r.URL.Path, "/manifests/") && r.Method == http.MethodGet:
+			fetches.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestContentCache_ServesDigestRequests(t *testing.T) {
+	server, fetches := redirectingRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	tag, err := name.ParseReference(host + "/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	ref := host + "/app@" + digest.String()
+
+	cache := newTestContentCache(t, ContentCacheOptions{})
+	cfg := &Config{ContentCache: cache}
+	client := NewBackend(NewClientWithConfig(cfg))
+
+	ctx, trace := WithTrace(t.Context())
+	want, err := GetImageConfig(ctx, client, ref)
+	require.NoError(t, err)
+	hits, misses := trace.Cache()
+	assert.Equal(t, 0, hits)
+	assert.Equal(t, 2, misses, "manifest and config blob")
+	assert.Equal(t, int32(2), fetches.Load())
+
+	ctx, trace = WithTrace(t.Context())
+	got, err := GetImageConfig(ctx, client, ref)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	hits, misses = trace.Cache()
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 0, misses)
+	assert.Equal(t, int32(2), fetches.Load(), "served from cache")
+
+	// Other credentials do not see the content cached for anonymous calls.
+	ctx, trace = WithTrace(t.Context())
+	_, err = GetImageConfig(ctx, NewBackend(NewClientWithConfig(cfg).WithAuth(&authn.Basic{Username: "caller"})), ref)
+	require.NoError(t, err)
+	hits, _ = trace.Cache()
+	assert.Equal(t, 0, hits)
+	assert.Equal(t, int32(4), fetches.Load())
+}
+
+func TestContentCache_Repositories(t *testing.T) {
+	server, fetches := redirectingRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	digest := pushRandom(t, host+"/first:v1")
+	pushRandom(t, host+"/second:v1")
+	fetches.Store(0)
+
+	cfg := &Config{ContentCache: newTestContentCache(t, ContentCacheOptions{})}
+	client := NewBackend(NewClientWithConfig(cfg))
+	manifest, _, err := client.GetManifest(t.Context(), host+"/first@"+digest)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), fetches.Load())
+
+	// The digest is cached for the first repository only: the second is asked
+	// whether it has the digest, and does not.
+	ctx, trace := WithTrace(t.Context())
+	_, _, err = client.HeadReference(ctx, host+"/second@"+digest)
+	assert.True(t, IsNotFound(err), "got %v", err)
+	_, _, err = client.GetManifest(ctx, host+"/second@"+digest)
+	assert.True(t, IsNotFound(err), "got %v", err)
+	hits, misses := trace.Cache()
+	assert.Equal(t, 0, hits)
+	assert.Equal(t, 2, misses)
+
+	// Callers get their own copy of cached content.
+	manifest[0] ^= 0xff
+	cached, _, err := client.GetManifest(t.Context(), host+"/first@"+digest)
+	require.NoError(t, err)
+	assert.NotEqual(t, manifest, cached)
+	cached[0] ^= 0xff
+	again, _, err := client.GetManifest(t.Context(), host+"/first@"+digest)
+	require.NoError(t, err)
+	assert.NotEqual(t, cached, again)
+	assert.Equal(t, int32(2), fetches.Load(), "only the second repository's manifest is fetched")
+}
+
+func TestContentCache_UnscopedClients(t *testing.T) {
+	server, fetches := redirectingRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	ref := host + "/app@" + pushRandom(t, host+"/app:v1")
+	fetches.Store(0)
+
+	cfg := &Config{ContentCache: newTestContentCache(t, ContentCacheOptions{})}
+	_, err := GetImageConfig(t.Context(), NewBackend(NewClientWithConfig(cfg)), ref)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), fetches.Load())
+
+	// A client with remote options may authenticate in ways the client cannot
+	// see: it neither reads the shared entries nor caches what it fetches.
+	opaque := NewBackend(NewClientWithConfig(cfg, WithBearerToken("token")))
+	for range 2 {
+		ctx, trace := WithTrace(t.Context())
+		_, err = GetImageConfig(ctx, opaque, ref)
+		require.NoError(t, err)
+		hits, misses := trace.Cache()
+		assert.Equal(t, 0, hits)
+		assert.Equal(t, 0, misses)
+	}
+	assert.Equal(t, int32(6), fetches.Load())
+}
+
+func TestContentCache_VerifiesDigest(t *testing.T) {
+	cache := newTestContentCache(t, ContentCacheOptions{})
+
+	key := testContent("", "expected")
+	cache.put(key, "application/json", []byte("tampered"))
+	_, ok := cache.get(key)
+	assert.False(t, ok)
+
+	cache.put(key, "application/json", []byte("expected"))
+	entry, ok := cache.get(key)
+	require.True(t, ok)
+	assert.Equal(t, "expected", string(entry.body))
+	assert.Equal(t, "application/json", entry.mediaType)
+}
+
+func TestContentCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTestContentCache(t, ContentCacheOptions{MaxBytes: 10, MaxEntryBytes: 6})
+
+	first, second, third := testContent("", "aaaa"), testContent("", "bbbb"), testContent("", "cccc")
+	cache.put(first, "", []byte("aaaa"))
+	cache.put(second, "", []byte("bbbb"))
+	_, ok := cache.get(first)
+	require.True(t, ok)
+	cache.put(third, "", []byte("cccc"))
+
+	_, ok = cache.get(first)
+	assert.True(t, ok)
+	_, ok = cache.get(second)
+	assert.False(t, ok, "least recently used entry is evicted")
+	_, ok = cache.get(third)
+	assert.True(t, ok)
+
+	tooLarge := testContent("", "too large")
+	cache.put(tooLarge, "", []byte("too large"))
+	_, ok = cache.get(tooLarge)
+	assert.False(t, ok, "entries over MaxEntryBytes are not cached")
+}
+
+func TestContentCache_Disk(t *testing.T) {
+	dir := t.TempDir()
+	opts := ContentCacheOptions{Dir: dir, MaxDiskBytes: 40}
+
+	cache := newTestContentCache(t, opts)
+	shared, scoped := testContent("", "shared"), testContent("caller", "scoped")
+	cache.put(shared, "text/plain", []byte("shared"))
+	cache.put(scoped, "text/plain", []byte("scoped"))
+
+	restarted := newTestContentCache(t, opts)
+	entry, ok := restarted.get(shared)
+	require.True(t, ok)
+	assert.Equal(t, "shared", string(entry.body))
+	assert.Equal(t, "text/plain", entry.mediaType)
+	_, ok = restarted.get(testContent("other", "scoped"))
+	assert.False(t, ok, "content is not shared between scopes")
+
+	// Corrupted files are discarded.
+	require.NoError(t, os.WriteFile(restarted.path(scoped), []byte("text/plain\ncorrupt"), 0o600))
+	_, ok = newTestContentCache(t, opts).get(scoped)
+	assert.False(t, ok)
+
+	// Writing past MaxDiskBytes evicts older files.
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(restarted.path(shared), past, past))
+	restarted.put(scoped, "text/plain", []byte("scoped"))
+	restarted.put(testContent("", "newer content"), "", []byte("newer content"))
+	_, err := os.Stat(restarted.path(shared))
+	assert.True(t, os.IsNotExist(err), "least recently used file is evicted")
+	files, err := restarted.diskFiles()
+	require.NoError(t, err)
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	assert.LessOrEqual(t, size, opts.MaxDiskBytes)
+	newer := testContent("", "newer content")
+	_, err = os.Stat(filepath.Join(dir, sharedCacheScope, pathHash(newer.repository), "sha256", newer.digest.Hex))
+	assert.NoError(t, err)
+}
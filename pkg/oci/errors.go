@@ -0,0 +1,178 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// ErrorCategory is a coarse classification of a failed registry operation,
+// telling callers whether to fix the request, supply credentials or retry.
+type ErrorCategory string
+
+// Error categories reported by ClassifyError.
+const (
+	ErrorCategoryNotFound        ErrorCategory = "not_found"
+	ErrorCategoryUnauthorized    ErrorCategory = "unauthorized"
+	ErrorCategoryDenied          ErrorCategory = "denied"
+	ErrorCategoryRateLimited     ErrorCategory = "rate_limited"
+	ErrorCategoryTimeout         ErrorCategory = "timeout"
+	ErrorCategoryInvalidArgument ErrorCategory = "invalid_argument"
+	ErrorCategoryUnsupported     ErrorCategory = "unsupported"
+	ErrorCategoryUnavailable     ErrorCategory = "unavailable"
+	ErrorCategoryUnknown         ErrorCategory = "unknown"
+)
+
+// ErrorClass describes a classified error.
+type ErrorClass struct {
+	Category ErrorCategory
+	// Code is the first error code from the registry's response, if any.
+	Code string
+	// StatusCode is the HTTP status of the failed registry response, if any.
+	StatusCode int
+	// Retryable reports whether repeating the same request may succeed.
+	Retryable bool
+	// RetryAfter is the delay the registry asked for before retrying, if any.
+	RetryAfter time.Duration
+}
+
+// ClassifyError classifies an error returned by a Client method. The Retry-After
+// hint is taken from the Trace attached to ctx, which records it from the
+// registry's responses.
+func ClassifyError(ctx context.Context, err error) ErrorClass {
+	class := classifyError(err)
+	if class.Category == ErrorCategoryRateLimited || class.Category == ErrorCategoryUnavailable {
+		class.RetryAfter = traceFromContext(ctx).RetryAfter()
+	}
+	return class
+}
+
+// classifyError implements ClassifyError without the Retry-After hint.
+func classifyError(err error) ErrorClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClass{Category: ErrorCategoryTimeout, Retryable: true}
+	}
+	if errors.Is(err, ErrLocalNotFound) {
+		return ErrorClass{Category: ErrorCategoryNotFound}
+	}
+	if errors.Is(err, ErrLocalNotAllowed) {
+		return ErrorClass{Category: ErrorCategoryDenied}
+	}
+	if errors.Is(err, ErrCatalogUnsupported) {
+		return withTransportDetails(ErrorClass{Category: ErrorCategoryUnsupported}, err)
+	}
+	var badName *name.ErrBadName
+	if errors.As(err, &badName) {
+		return ErrorClass{Category: ErrorCategoryInvalidArgument}
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return withTransportDetails(ErrorClass{
+			Category:  registryErrorCategory(terr),
+			Retryable: terr.Temporary() || terr.StatusCode == http.StatusTooManyRequests,
+		}, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClass{Category: ErrorCategoryTimeout, Retryable: true}
+		}
+		return ErrorClass{Category: ErrorCategoryUnavailable, Retryable: true}
+	}
+
+	return ErrorClass{Category: ErrorCategoryUnknown}
+}
+
+// withTransportDetails fills in the registry error code and HTTP status when
+// err wraps a transport.Error.
+func withTransportDetails(class ErrorClass, err error) ErrorClass {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		class.StatusCode = terr.StatusCode
+		if len(terr.Errors) > 0 {
+			class.Code = string(terr.Errors[0].Code)
+		}
+	}
+	return class
+}
+
+// registryErrorCodeCategories maps registry error codes to categories; codes
+// take precedence over the HTTP status, which registries use inconsistently.
+var registryErrorCodeCategories = map[transport.ErrorCode]ErrorCategory{
+	transport.UnauthorizedErrorCode:        ErrorCategoryUnauthorized,
+	transport.DeniedErrorCode:              ErrorCategoryDenied,
+	transport.TooManyRequestsErrorCode:     ErrorCategoryRateLimited,
+	transport.ManifestUnknownErrorCode:     ErrorCategoryNotFound,
+	transport.NameUnknownErrorCode:         ErrorCategoryNotFound,
+	transport.BlobUnknownErrorCode:         ErrorCategoryNotFound,
+	transport.UnsupportedErrorCode:         ErrorCategoryUnsupported,
+	transport.NameInvalidErrorCode:         ErrorCategoryInvalidArgument,
+	transport.TagInvalidErrorCode:          ErrorCategoryInvalidArgument,
+	transport.DigestInvalidErrorCode:       ErrorCategoryInvalidArgument,
+	transport.ManifestInvalidErrorCode:     ErrorCategoryInvalidArgument,
+	transport.UnavailableErrorCode:         ErrorCategoryUnavailable,
+	transport.ManifestBlobUnknownErrorCode: ErrorCategoryNotFound,
+}
+
+// registryErrorCategory classifies a registry error response.
+func registryErrorCategory(terr *transport.Error) ErrorCategory {
+	for _, diag := range terr.Errors {
+		if category, ok := registryErrorCodeCategories[diag.Code]; ok {
+			return category
+		}
+	}
+
+	switch {
+	case terr.StatusCode == http.StatusUnauthorized:
+		return ErrorCategoryUnauthorized
+	case terr.StatusCode == http.StatusForbidden:
+		return ErrorCategoryDenied
+	case terr.StatusCode == http.StatusTooManyRequests:
+		return ErrorCategoryRateLimited
+	case terr.StatusCode == http.StatusNotFound:
+		return ErrorCategoryNotFound
+	case terr.StatusCode == http.StatusMethodNotAllowed || terr.StatusCode == http.StatusNotImplemented:
+		return ErrorCategoryUnsupported
+	case terr.StatusCode == http.StatusBadRequest:
+		return ErrorCategoryInvalidArgument
+	case terr.StatusCode == http.StatusRequestTimeout || terr.StatusCode == http.StatusGatewayTimeout:
+		return ErrorCategoryTimeout
+	case terr.StatusCode >= http.StatusInternalServerError:
+		return ErrorCategoryUnavailable
+	default:
+		return ErrorCategoryUnknown
+	}
+}
+
+// IsNotFound reports whether err indicates that the requested repository,
+// manifest or blob does not exist in the registry.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrLocalNotFound) {
+		return true
+	}
+
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+
+	if terr.StatusCode == http.StatusNotFound {
+		return true
+	}
+
+	for _, diag := range terr.Errors {
+		switch diag.Code {
+		case transport.ManifestUnknownErrorCode, transport.NameUnknownErrorCode, transport.BlobUnknownErrorCode:
+			return true
+		}
+	}
+
+	return false
+}
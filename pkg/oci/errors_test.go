@@ -0,0 +1,141 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	_, badName := name.ParseReference("INVALID::ref")
+	require.Error(t, badName)
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{
+			name: "manifest unknown",
+			err: fmt.Errorf("fetching image: %w", &transport.Error{
+				StatusCode: http.StatusNotFound,
+				Errors:     []transport.Diagnostic{{Code: transport.ManifestUnknownErrorCode}},
+			}),
+			want: ErrorClass{Category: ErrorCategoryNotFound, Code: "MANIFEST_UNKNOWN", StatusCode: http.StatusNotFound},
+		},
+		{
+			name: "code takes precedence over status",
+			err: &transport.Error{
+				StatusCode: http.StatusNotFound,
+				Errors:     []transport.Diagnostic{{Code: transport.DeniedErrorCode}},
+			},
+			want: ErrorClass{Category: ErrorCategoryDenied, Code: "DENIED", StatusCode: http.StatusNotFound},
+		},
+		{
+			name: "unauthorized without body",
+			err:  &transport.Error{StatusCode: http.StatusUnauthorized},
+			want: ErrorClass{Category: ErrorCategoryUnauthorized, StatusCode: http.StatusUnauthorized},
+		},
+		{
+			name: "forbidden",
+			err:  &transport.Error{StatusCode: http.StatusForbidden},
+			want: ErrorClass{Category: ErrorCategoryDenied, StatusCode: http.StatusForbidden},
+		},
+		{
+			name: "rate limited",
+			err: &transport.Error{
+				StatusCode: http.StatusTooManyRequests,
+				Errors:     []transport.Diagnostic{{Code: transport.TooManyRequestsErrorCode}},
+			},
+			want: ErrorClass{
+				Category: ErrorCategoryRateLimited, Code: "TOOMANYREQUESTS",
+				StatusCode: http.StatusTooManyRequests, Retryable: true,
+			},
+		},
+		{
+			name: "server error",
+			err:  &transport.Error{StatusCode: http.StatusBadGateway},
+			want: ErrorClass{Category: ErrorCategoryUnavailable, StatusCode: http.StatusBadGateway, Retryable: true},
+		},
+		{
+			name: "catalog unsupported",
+			err:  fmt.Errorf("%w: %w", ErrCatalogUnsupported, &transport.Error{StatusCode: http.StatusNotFound}),
+			want: ErrorClass{Category: ErrorCategoryUnsupported, StatusCode: http.StatusNotFound},
+		},
+		{
+			name: "deadline",
+			err:  fmt.Errorf("fetching image: %w", context.DeadlineExceeded),
+			want: ErrorClass{Category: ErrorCategoryTimeout, Retryable: true},
+		},
+		{
+			name: "bad reference",
+			err:  badName,
+			want: ErrorClass{Category: ErrorCategoryInvalidArgument},
+		},
+		{
+			name: "connection refused",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")},
+			want: ErrorClass{Category: ErrorCategoryUnavailable, Retryable: true},
+		},
+		{
+			name: "other",
+			err:  fmt.Errorf("something else"),
+			want: ErrorClass{Category: ErrorCategoryUnknown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ClassifyError(t.Context(), tt.err))
+		})
+	}
+}
+
+func TestClassifyError_RetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "42")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClientWithConfig(&Config{Retry: RetryPolicy{MaxAttempts: 1}})
+	ctx, _ := WithTrace(t.Context())
+	_, err := client.ListTags(ctx, strings.TrimPrefix(server.URL, "http://")+"/app")
+	require.Error(t, err)
+
+	class := ClassifyError(ctx, err)
+	assert.Equal(t, ErrorCategoryRateLimited, class.Category)
+	assert.True(t, class.Retryable)
+	assert.Equal(t, 42*time.Second, class.RetryAfter)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("120", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+
+	delay, ok = parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, delay)
+
+	delay, ok = parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Zero(t, delay)
+
+	for _, value := range []string{"", "-1", "soon"} {
+		_, ok := parseRetryAfter(value, now)
+		assert.False(t, ok, value)
+	}
+}
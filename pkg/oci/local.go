@@ -0,0 +1,733 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Reference schemes selecting images stored on the local filesystem instead of
+// a registry.
+const (
+	// LayoutScheme selects an OCI image layout directory, e.g.
+	// oci-layout://./build/layout:v1.
+	LayoutScheme = "oci-layout://"
+	// ArchiveScheme selects a tarball of an OCI image layout or one written by
+	// docker save, e.g. oci-archive:///tmp/app.tar:v1.
+	ArchiveScheme = "oci-archive://"
+)
+
+// refNameAnnotation names the tag of a manifest in an OCI image layout.
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// maxOpenArchives bounds the number of archives a LocalStore keeps open.
+const maxOpenArchives = 16
+
+var (
+	// localTagPattern matches valid tags.
+	localTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	// layoutFilePattern matches the files of an OCI image layout extracted
+	// from an archive.
+	layoutFilePattern = regexp.MustCompile(`^(index\.json|oci-layout|blobs/[a-z0-9]+/[a-f0-9]+)$`)
+)
+
+// ErrLocalNotAllowed is returned for local references outside the directories
+// a LocalStore allows reading.
+var ErrLocalNotAllowed = errors.New("local image stores are not allowed at this path")
+
+// ErrLocalNotFound is returned when a local image layout or archive has no
+// manifest or blob matching a reference.
+var ErrLocalNotFound = errors.New("not found in local image store")
+
+// LocalReference is a parsed reference to an image in a local image layout or
+// archive: the scheme and path of the store, optionally followed by a tag or
+// a digest, e.g. oci-layout://./build:v1 or oci-archive://app.tar@sha256:....
+type LocalReference struct {
+	Scheme string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// IsLocalReference reports whether ref names an image in a local image layout
+// or archive rather than a registry.
+func IsLocalReference(ref string) bool {
+	return strings.HasPrefix(ref, LayoutScheme) || strings.HasPrefix(ref, ArchiveScheme)
+}
+
+// ParseLocalReference parses an oci-layout:// or oci-archive:// reference.
+// Without a tag or digest, it names the store's only image.
+func ParseLocalReference(ref string) (LocalReference, error) {
+	var r LocalReference
+	for _, scheme := range []string{LayoutScheme, ArchiveScheme} {
+		if rest, ok := strings.CutPrefix(ref, scheme); ok {
+			r.Scheme, r.Path = scheme, rest
+		}
+	}
+	if r.Scheme == "" {
+		return r, fmt.Errorf("%q is not an %s or %s reference", ref, LayoutScheme, ArchiveScheme)
+	}
+
+	if path, digest, ok := strings.Cut(r.Path, "@"); ok {
+		if _, err := v1.NewHash(digest); err != nil {
+			return r, fmt.Errorf("invalid digest in %q: %w", ref, err)
+		}
+		r.Path, r.Digest = path, digest
+	} else if i := strings.LastIndex(r.Path, ":"); i > 0 && !strings.ContainsAny(r.Path[i+1:], `/\`) {
+		if !localTagPattern.MatchString(r.Path[i+1:]) {
+			return r, fmt.Errorf("invalid tag in %q", ref)
+		}
+		r.Path, r.Tag = r.Path[:i], r.Path[i+1:]
+	}
+
+	if r.Path == "" {
+		return r, fmt.Errorf("%q does not name a path", ref)
+	}
+	return r, nil
+}
+
+// Location returns the store part of the reference, which local operations
+// accept in place of a repository.
+func (r LocalReference) Location() string {
+	return r.Scheme + r.Path
+}
+
+// String returns the reference in its canonical form.
+func (r LocalReference) String() string {
+	switch {
+	case r.Digest != "":
+		return r.Location() + "@" + r.Digest
+	case r.Tag != "":
+		return r.Location() + ":" + r.Tag
+	default:
+		return r.Location()
+	}
+}
+
+// LocalStore serves images from OCI image layouts and archives on the local
+// filesystem, for references with the LayoutScheme and ArchiveScheme schemes.
+// Layouts are read with go-containerregistry's layout package and docker save
+// tarballs with its tarball package; archives of an OCI image layout are
+// extracted to a temporary directory first. Only paths within the store's
+// roots can be read.
+type LocalStore struct {
+	roots []string
+
+	mu       sync.Mutex
+	archives map[string]*localArchive
+}
+
+// localArchive is an opened archive, reused while the file is unchanged.
+type localArchive struct {
+	size     int64
+	modTime  time.Time
+	lastUsed time.Time
+	images   *localImages
+	// dir is the directory an OCI image layout was extracted to, if any.
+	dir string
+}
+
+// localImages are the manifests of an image layout or archive.
+type localImages struct {
+	// index lists the top-level manifests.
+	index v1.ImageIndex
+	// blob returns the manifest or blob with the given digest.
+	blob func(v1.Hash) (io.ReadCloser, error)
+}
+
+// NewLocalStore returns a store reading the image layouts and archives within
+// roots. Without roots, every local reference is rejected with
+// ErrLocalNotAllowed.
+func NewLocalStore(roots ...string) *LocalStore {
+	return &LocalStore{roots: roots, archives: map[string]*localArchive{}}
+}
+
+// Close removes the layouts extracted from archives.
+func (s *LocalStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for path, archive := range s.archives {
+		errs = append(errs, archive.remove())
+		delete(s.archives, path)
+	}
+	return errors.Join(errs...)
+}
+
+// allowed reports whether path is within one of the store's roots.
+func (s *LocalStore) allowed(path string) bool {
+	path, err := canonicalPath(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range s.roots {
+		root, err := canonicalPath(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath returns the absolute form of path with symbolic links
+// resolved, so links cannot escape a root.
+func canonicalPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// open opens the image layout or archive a reference points at, if the store
+// allows reading it.
+func (s *LocalStore) open(r LocalReference) (*localImages, error) {
+	if !s.allowed(r.Path) {
+		return nil, fmt.Errorf("%s: %w", r.Location(), ErrLocalNotAllowed)
+	}
+	if r.Scheme == LayoutScheme {
+		return openLayout(r.Path)
+	}
+	return s.openArchive(r.Path)
+}
+
+// openLayout opens an OCI image layout directory.
+func openLayout(dir string) (*localImages, error) {
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading image layout: %w", err)
+	}
+	index, err := p.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading image layout index: %w", err)
+	}
+
+	return &localImages{
+		index: index,
+		blob: func(h v1.Hash) (io.ReadCloser, error) {
+			rc, err := p.Blob(h)
+			return rc, localNotFound(h, err)
+		},
+	}, nil
+}
+
+// localNotFound wraps a missing file error reading the blob h in
+// ErrLocalNotFound.
+func localNotFound(h v1.Hash, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("blob %s: %w", h, ErrLocalNotFound)
+	}
+	return err
+}
+
+// openArchive opens an archive holding an OCI image layout, as written by
+// docker save since Docker 25 or skopeo, or the images of an older docker
+// save tarball. Opened archives are reused until the file changes; the least
+// recently used are closed beyond maxOpenArchives.
+func (s *LocalStore) openArchive(path string) (*localImages, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if archive, ok := s.archives[path]; ok {
+		if archive.size == info.Size() && archive.modTime.Equal(info.ModTime()) {
+			archive.lastUsed = time.Now()
+			return archive.images, nil
+		}
+		_ = archive.remove()
+		delete(s.archives, path)
+	}
+
+	archive, err := loadArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	archive.size, archive.modTime, archive.lastUsed = info.Size(), info.ModTime(), time.Now()
+
+	for len(s.archives) >= maxOpenArchives {
+		var oldest string
+		for p, a := range s.archives {
+			if oldest == "" || a.lastUsed.Before(s.archives[oldest].lastUsed) {
+				oldest = p
+			}
+		}
+		_ = s.archives[oldest].remove()
+		delete(s.archives, oldest)
+	}
+	s.archives[path] = archive
+	return archive.images, nil
+}
+
+// remove deletes the layout extracted from the archive, if any.
+func (a *localArchive) remove() error {
+	if a.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(a.dir)
+}
+
+// loadArchive reads an archive, extracting an OCI image layout it holds to a
+// temporary directory.
+func loadArchive(path string) (*localArchive, error) {
+	dir, err := os.MkdirTemp("", "ocireg-mcp-archive-")
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	isLayout, isDocker, err := extractLayout(path, dir)
+	if err == nil && isLayout {
+		var images *localImages
+		images, err = openLayout(dir)
+		if err == nil {
+			return &localArchive{images: images, dir: dir}, nil
+		}
+	}
+	_ = os.RemoveAll(dir)
+
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("reading archive: %w", err)
+	case isDocker:
+		images, err := openDockerArchive(path)
+		if err != nil {
+			return nil, err
+		}
+		return &localArchive{images: images}, nil
+	default:
+		return nil, errors.New("reading archive: neither an OCI image layout nor a docker save tarball")
+	}
+}
+
+// extractLayout extracts the files of an OCI image layout in the archive at
+// path to dir, reporting whether it holds a layout's index.json and a docker
+// save manifest.json. Other files are skipped.
+func extractLayout(archive, dir string) (isLayout, isDocker bool, err error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return isLayout, isDocker, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		file := strings.TrimPrefix(path.Clean(filepath.ToSlash(hdr.Name)), "./")
+		isDocker = isDocker || file == "manifest.json"
+		if hdr.Typeflag != tar.TypeReg || !layoutFilePattern.MatchString(file) {
+			continue
+		}
+		isLayout = isLayout || file == "index.json"
+		if err := extractFile(tr, filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			return false, false, err
+		}
+	}
+}
+
+// extractFile writes the content of the current archive entry to target.
+func extractFile(r io.Reader, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// openDockerArchive presents the tagged images of a docker save tarball as an
+// image layout. Their manifests are generated, so digests differ from the
+// ones the images have in a registry.
+func openDockerArchive(path string) (*localImages, error) {
+	opener := func() (io.ReadCloser, error) { return os.Open(path) }
+	manifest, err := tarball.LoadManifest(opener)
+	if err != nil {
+		return nil, fmt.Errorf("reading docker archive: %w", err)
+	}
+
+	var index v1.ImageIndex = empty.Index
+	var images []v1.Image
+	for _, entry := range manifest {
+		for _, repoTag := range entry.RepoTags {
+			tag, err := name.NewTag(repoTag)
+			if err != nil {
+				continue
+			}
+			img, err := tarball.Image(opener, &tag)
+			if err != nil {
+				return nil, fmt.Errorf("reading docker archive image %s: %w", repoTag, err)
+			}
+			index = mutate.AppendManifests(index, mutate.IndexAddendum{
+				Add:        img,
+				Descriptor: v1.Descriptor{Annotations: map[string]string{refNameAnnotation: repoTag}},
+			})
+			images = append(images, img)
+		}
+	}
+
+	return &localImages{
+		index: index,
+		blob: func(h v1.Hash) (io.ReadCloser, error) {
+			return imageBlob(images, h)
+		},
+	}, nil
+}
+
+// imageBlob returns the manifest, config or layer with digest h of one of
+// images.
+func imageBlob(images []v1.Image, h v1.Hash) (io.ReadCloser, error) {
+	for _, img := range images {
+		var raw []byte
+		var err error
+		if digest, _ := img.Digest(); digest == h {
+			raw, err = img.RawManifest()
+		} else if config, _ := img.ConfigName(); config == h {
+			raw, err = img.RawConfigFile()
+		} else if layer, lerr := img.LayerByDigest(h); lerr == nil {
+			return layer.Compressed()
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	}
+	return nil, fmt.Errorf("blob %s: %w", h, ErrLocalNotFound)
+}
+
+// readBlob reads a blob, such as a manifest.
+func (li *localImages) readBlob(h v1.Hash) ([]byte, error) {
+	rc, err := li.blob(h)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// manifests returns the top-level manifests.
+func (li *localImages) manifests() []v1.Descriptor {
+	index, err := li.index.IndexManifest()
+	if err != nil {
+		return nil
+	}
+	return index.Manifests
+}
+
+// tagOf returns the tag of a manifest in an image layout. Layouts name
+// manifests by tag or by full reference.
+func tagOf(desc v1.Descriptor) string {
+	ref := desc.Annotations[refNameAnnotation]
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i+1:], "/") {
+		return ref[i+1:]
+	}
+	return ref
+}
+
+// tags returns the tags of the top-level manifests in index order.
+func (li *localImages) tags() []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, desc := range li.manifests() {
+		if tag := tagOf(desc); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// resolve returns the descriptor of the manifest a reference names. Digests
+// may name the platform children of indexes, too.
+func (li *localImages) resolve(r LocalReference) (v1.Descriptor, error) {
+	manifests := li.manifests()
+	switch {
+	case r.Tag != "":
+		for _, desc := range manifests {
+			if tagOf(desc) == r.Tag {
+				return desc, nil
+			}
+		}
+		return v1.Descriptor{}, fmt.Errorf("tag %q: %w", r.Tag, ErrLocalNotFound)
+	case r.Digest != "":
+		var found *v1.Descriptor
+		li.walk(func(desc v1.Descriptor) bool {
+			if desc.Digest.String() == r.Digest {
+				found = &desc
+			}
+			return found == nil
+		})
+		if found == nil {
+			return v1.Descriptor{}, fmt.Errorf("manifest %s: %w", r.Digest, ErrLocalNotFound)
+		}
+		return *found, nil
+	case len(manifests) == 1:
+		return manifests[0], nil
+	default:
+		return v1.Descriptor{}, fmt.Errorf(
+			"%s holds %d manifests: add a :tag or @digest to select one", r.Location(), len(manifests))
+	}
+}
+
+// walk calls fn with every manifest, descending into indexes, until fn
+// returns false. Unreadable indexes are skipped.
+func (li *localImages) walk(fn func(desc v1.Descriptor) bool) {
+	var visit func(index v1.ImageIndex) bool
+	visit = func(index v1.ImageIndex) bool {
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return true
+		}
+		for _, desc := range manifest.Manifests {
+			if !fn(desc) {
+				return false
+			}
+			if desc.MediaType.IsIndex() {
+				child, err := index.ImageIndex(desc.Digest)
+				if err == nil && !visit(child) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	visit(li.index)
+}
+
+// referrers returns the manifests whose subject is digest, optionally only
+// those of the given artifact type.
+func (li *localImages) referrers(digest v1.Hash, artifactType string) *v1.IndexManifest {
+	index := &v1.IndexManifest{SchemaVersion: 2, MediaType: types.OCIImageIndex, Manifests: []v1.Descriptor{}}
+	li.walk(func(desc v1.Descriptor) bool {
+		raw, err := li.readBlob(desc.Digest)
+		if err != nil {
+			return true
+		}
+		var manifest struct {
+			ArtifactType string            `json:"artifactType"`
+			Config       v1.Descriptor     `json:"config"`
+			Subject      *v1.Descriptor    `json:"subject"`
+			Annotations  map[string]string `json:"annotations"`
+		}
+		if json.Unmarshal(raw, &manifest) != nil || manifest.Subject == nil || manifest.Subject.Digest != digest {
+			return true
+		}
+		desc.ArtifactType = manifest.ArtifactType
+		if desc.ArtifactType == "" {
+			desc.ArtifactType = string(manifest.Config.MediaType)
+		}
+		desc.Annotations = manifest.Annotations
+		if artifactType == "" || desc.ArtifactType == artifactType {
+			index.Manifests = append(index.Manifests, desc)
+		}
+		return true
+	})
+	return index
+}
+
+// resolveLocal opens the layout or archive a local reference points at and
+// resolves the manifest it names.
+func (s *LocalStore) resolveLocal(imageRef string) (*localImages, v1.Descriptor, error) {
+	ref, err := ParseLocalReference(imageRef)
+	if err != nil {
+		return nil, v1.Descriptor{}, err
+	}
+	images, err := s.open(ref)
+	if err != nil {
+		return nil, v1.Descriptor{}, err
+	}
+	desc, err := images.resolve(ref)
+	return images, desc, err
+}
+
+// openLocation opens the layout or archive at a local location, ignoring any
+// tag or digest.
+func (s *LocalStore) openLocation(location string) (*localImages, error) {
+	ref, err := ParseLocalReference(location)
+	if err != nil {
+		return nil, err
+	}
+	ref.Tag, ref.Digest = "", ""
+	return s.open(ref)
+}
+
+// GetManifest returns the raw manifest or index a local reference names,
+// with its descriptor.
+func (s *LocalStore) GetManifest(_ context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	images, desc, err := s.resolveLocal(imageRef)
+	if err != nil {
+		return nil, v1.Descriptor{}, fmt.Errorf("fetching manifest: %w", err)
+	}
+	raw, err := images.readBlob(desc.Digest)
+	if err != nil {
+		return nil, v1.Descriptor{}, fmt.Errorf("fetching manifest: %w", err)
+	}
+	return raw, desc, nil
+}
+
+// GetBlob reads the blob with the given digest from the layout or archive at
+// location. It fails with an error wrapping ErrBlobTooLarge when the blob is
+// larger than maxBytes.
+func (s *LocalStore) GetBlob(_ context.Context, location, digest string, maxBytes int64) ([]byte, error) {
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, fmt.Errorf("parsing blob digest: %w", err)
+	}
+	images, err := s.openLocation(location)
+	if err != nil {
+		return nil, fmt.Errorf("fetching blob: %w", err)
+	}
+	rc, err := images.blob(hash)
+	if err != nil {
+		return nil, fmt.Errorf("fetching blob: %w", err)
+	}
+	defer rc.Close()
+	return readLimited(rc, digest, maxBytes)
+}
+
+// HeadReference returns the descriptor of the manifest a local reference
+// names. The returned reference is always nil.
+func (s *LocalStore) HeadReference(_ context.Context, imageRef string) (name.Reference, *v1.Descriptor, error) {
+	_, desc, err := s.resolveLocal(imageRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving image reference: %w", err)
+	}
+	return nil, &desc, nil
+}
+
+// ListTags lists the tags of the layout or archive at location.
+func (s *LocalStore) ListTags(_ context.Context, location string) ([]string, error) {
+	images, err := s.openLocation(location)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	return images.tags(), nil
+}
+
+// ListReferrers lists the manifests of a layout or archive referring to the
+// manifest a local reference names.
+func (s *LocalStore) ListReferrers(_ context.Context, imageRef, artifactType string) (*v1.IndexManifest, error) {
+	images, desc, err := s.resolveLocal(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers: %w", err)
+	}
+	return images.referrers(desc.Digest, artifactType), nil
+}
+
+// RepositoryOf returns the repository an image reference points into and its
+// registry host, resolving names with names. For local references, the
+// repository is the location of the image layout or archive and the registry
+// is empty.
+func RepositoryOf(names NameResolver, imageRef string) (repo, registry string, err error) {
+	if IsLocalReference(imageRef) {
+		ref, err := ParseLocalReference(imageRef)
+		return ref.Location(), "", err
+	}
+
+	ref, err := names.ParseReference(imageRef)
+	if err != nil {
+		return "", "", err
+	}
+	return ref.Context().Name(), ref.Context().RegistryStr(), nil
+}
+
+// localRouter serves local references from a LocalStore and everything else
+// from the Backend it wraps.
+type localRouter struct {
+	Backend
+	local *LocalStore
+}
+
+// WithLocalStore returns a Backend serving oci-layout:// and oci-archive://
+// references from store and all others from b. Local references must be
+// routed before their names are parsed, as go-containerregistry accepts them
+// as registry references.
+func WithLocalStore(b Backend, store *LocalStore) Backend {
+	return &localRouter{Backend: b, local: store}
+}
+
+// GetManifest implements Backend.
+func (r *localRouter) GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	if IsLocalReference(imageRef) {
+		return r.local.GetManifest(ctx, imageRef)
+	}
+	return r.Backend.GetManifest(ctx, imageRef)
+}
+
+// GetBlob implements Backend.
+func (r *localRouter) GetBlob(ctx context.Context, repo, digest string, maxBytes int64) ([]byte, error) {
+	if IsLocalReference(repo) {
+		return r.local.GetBlob(ctx, repo, digest, maxBytes)
+	}
+	return r.Backend.GetBlob(ctx, repo, digest, maxBytes)
+}
+
+// HeadReference implements Backend.
+func (r *localRouter) HeadReference(
+	ctx context.Context, imageRef string,
+) (name.Reference, *v1.Descriptor, error) {
+	if IsLocalReference(imageRef) {
+		return r.local.HeadReference(ctx, imageRef)
+	}
+	return r.Backend.HeadReference(ctx, imageRef)
+}
+
+// ListReferrers implements Backend.
+func (r *localRouter) ListReferrers(
+	ctx context.Context, imageRef, artifactTypeFilter string,
+) (*v1.IndexManifest, error) {
+	if IsLocalReference(imageRef) {
+		return r.local.ListReferrers(ctx, imageRef, artifactTypeFilter)
+	}
+	return r.Backend.ListReferrers(ctx, imageRef, artifactTypeFilter)
+}
+
+// ListTags implements Backend.
+func (r *localRouter) ListTags(ctx context.Context, repoName string) ([]string, error) {
+	if IsLocalReference(repoName) {
+		return r.local.ListTags(ctx, repoName)
+	}
+	return r.Backend.ListTags(ctx, repoName)
+}
@@ -0,0 +1,227 @@
+package oci
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestLayout writes an OCI image layout to dir holding img tagged v1 and
+// an untagged artifact referring to it, and returns the artifact.
+func writeTestLayout(t *testing.T, dir string, img v1.Image) v1.Image {
+	t.Helper()
+	path, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, path.AppendImage(img, layout.WithAnnotations(map[string]string{refNameAnnotation: "v1"})))
+
+	desc, err := partial.Descriptor(img)
+	require.NoError(t, err)
+	sig, err := random.Image(32, 1)
+	require.NoError(t, err)
+	sig = mutate.ConfigMediaType(sig, "application/vnd.example.sig")
+	sig = mutate.Subject(sig, *desc).(v1.Image)
+	require.NoError(t, path.AppendImage(sig))
+	return sig
+}
+
+// newLocalBackend returns a Backend serving local references from the given
+// roots.
+func newLocalBackend(t *testing.T, roots ...string) Backend {
+	t.Helper()
+	store := NewLocalStore(roots...)
+	t.Cleanup(func() { assert.NoError(t, store.Close()) })
+	return WithLocalStore(NewClient(), store)
+}
+
+// tarDir writes the contents of dir to a tar archive at path.
+func tarDir(t *testing.T, dir, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.AddFS(os.DirFS(dir)))
+	require.NoError(t, tw.Close())
+}
+
+func TestParseLocalReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    LocalReference
+		wantErr bool
+	}{
+		{ref: "oci-layout://./build", want: LocalReference{Scheme: LayoutScheme, Path: "./build"}},
+		{ref: "oci-layout://./build:v1", want: LocalReference{Scheme: LayoutScheme, Path: "./build", Tag: "v1"}},
+		{ref: "oci-archive:///tmp/app.tar:latest", want: LocalReference{Scheme: ArchiveScheme, Path: "/tmp/app.tar", Tag: "latest"}},
+		{
+			ref:  "oci-archive://app.tar@sha256:" + strings.Repeat("a", 64),
+			want: LocalReference{Scheme: ArchiveScheme, Path: "app.tar", Digest: "sha256:" + strings.Repeat("a", 64)},
+		},
+		{ref: "oci-layout://./build:v1/nested", want: LocalReference{Scheme: LayoutScheme, Path: "./build:v1/nested"}},
+		{ref: "oci-layout://", wantErr: true},
+		{ref: "oci-layout://build@sha256:bad", wantErr: true},
+		{ref: "docker.io/library/alpine", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseLocalReference(tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ref, got.String())
+		})
+	}
+}
+
+func TestLocal_Layout(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "layout")
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+	sig := writeTestLayout(t, dir, img)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	tarDir(t, dir, filepath.Join(root, "layout.tar"))
+
+	client := newLocalBackend(t, root)
+	for _, location := range []string{LayoutScheme + dir, ArchiveScheme + filepath.Join(root, "layout.tar")} {
+		t.Run(location, func(t *testing.T) {
+			got, err := GetImage(t.Context(), client, location+":v1")
+			require.NoError(t, err)
+			gotDigest, err := got.Digest()
+			require.NoError(t, err)
+			assert.Equal(t, digest, gotDigest)
+			layers, err := got.Layers()
+			require.NoError(t, err)
+			assert.Len(t, layers, 2)
+			_, err = GetImageConfig(t.Context(), client, location+"@"+digest.String())
+			require.NoError(t, err)
+
+			tags, err := client.ListTags(t.Context(), location)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"v1"}, tags)
+
+			repo, resolved, err := ResolveDigest(t.Context(), client, location+":v1")
+			require.NoError(t, err)
+			assert.Equal(t, location, repo)
+			assert.Equal(t, digest, resolved)
+
+			referrers, err := client.ListReferrers(t.Context(), location+":v1", "")
+			require.NoError(t, err)
+			require.Len(t, referrers.Manifests, 1)
+			sigDigest, err := sig.Digest()
+			require.NoError(t, err)
+			assert.Equal(t, sigDigest, referrers.Manifests[0].Digest)
+			assert.Equal(t, "application/vnd.example.sig", referrers.Manifests[0].ArtifactType)
+
+			_, err = GetImage(t.Context(), client, location+":missing")
+			assert.ErrorIs(t, err, ErrLocalNotFound)
+			assert.True(t, IsNotFound(err))
+		})
+	}
+}
+
+func TestLocal_DockerArchive(t *testing.T) {
+	root := t.TempDir()
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	tag, err := name.NewTag("example.com/app:v2")
+	require.NoError(t, err)
+	path := filepath.Join(root, "app.tar")
+	require.NoError(t, tarball.WriteToFile(path, tag, img))
+
+	client := newLocalBackend(t, root)
+	got, err := GetImage(t.Context(), client, ArchiveScheme+path)
+	require.NoError(t, err)
+	want, err := img.Digest()
+	require.NoError(t, err)
+	gotDigest, err := got.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, want, gotDigest)
+
+	cfg, err := GetImageConfig(t.Context(), client, ArchiveScheme+path+":v2")
+	require.NoError(t, err)
+	wantCfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, wantCfg.RootFS, cfg.RootFS)
+
+	layers, err := got.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	mediaType, err := layers[0].MediaType()
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerLayer, mediaType)
+	rc, err := layers[0].Compressed()
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+}
+
+func TestLocal_NotAllowed(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "layout")
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	writeTestLayout(t, dir, img)
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(dir, filepath.Join(outside, "link")))
+
+	for _, roots := range [][]string{nil, {outside + "-other"}} {
+		_, err := GetImage(t.Context(), newLocalBackend(t, roots...), LayoutScheme+dir+":v1")
+		assert.ErrorIs(t, err, ErrLocalNotAllowed)
+		assert.Equal(t, ErrorCategoryDenied, classifyError(err).Category)
+	}
+
+	// Symbolic links cannot escape the allowed roots.
+	client := newLocalBackend(t, outside)
+	_, err = GetImage(t.Context(), client, LayoutScheme+filepath.Join(outside, "link")+":v1")
+	assert.ErrorIs(t, err, ErrLocalNotAllowed)
+
+	_, err = GetImage(t.Context(), newLocalBackend(t, root), LayoutScheme+dir+":v1")
+	assert.NoError(t, err)
+}
+
+func TestLocalStore_Close(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "layout")
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	writeTestLayout(t, dir, img)
+	archive := filepath.Join(root, "layout.tar")
+	tarDir(t, dir, archive)
+
+	store := NewLocalStore(root)
+	_, err = store.ListTags(t.Context(), ArchiveScheme+archive)
+	require.NoError(t, err)
+	require.Len(t, store.archives, 1)
+	extracted := store.archives[archive].dir
+	assert.DirExists(t, extracted)
+
+	// The extracted layout is reused until the store is closed.
+	_, _, err = store.GetManifest(t.Context(), ArchiveScheme+archive+":v1")
+	require.NoError(t, err)
+	assert.Equal(t, extracted, store.archives[archive].dir)
+
+	require.NoError(t, store.Close())
+	assert.NoDirExists(t, extracted)
+	assert.Empty(t, store.archives)
+}
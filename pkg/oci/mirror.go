@@ -0,0 +1,232 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Mirror routes requests for an upstream registry to a mirror or pull-through
+// cache first. Endpoint is a registry host optionally followed by a path
+// prefix, e.g. "harbor.corp.example/dockerhub-proxy".
+type Mirror struct {
+	Upstream string
+	Endpoint string
+}
+
+// ParseMirrors parses a comma-separated list of upstream=endpoint pairs.
+// Several mirrors may be given for the same upstream; they are tried in order.
+func ParseMirrors(spec string) ([]Mirror, error) {
+	var mirrors []Mirror
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		upstream, endpoint, ok := strings.Cut(entry, "=")
+		upstream, endpoint = strings.TrimSpace(upstream), strings.Trim(strings.TrimSpace(endpoint), "/")
+		if !ok || upstream == "" || endpoint == "" {
+			return nil, fmt.Errorf("invalid registry mirror %q: expected upstream=endpoint", entry)
+		}
+		if _, err := name.NewRegistry(upstream); err != nil {
+			return nil, fmt.Errorf("invalid registry mirror %q: %w", entry, err)
+		}
+		mirrors = append(mirrors, Mirror{Upstream: upstream, Endpoint: endpoint})
+	}
+	return mirrors, nil
+}
+
+// mirrorsFor returns the mirror endpoints configured for a registry, in order.
+func (cfg *Config) mirrorsFor(registry name.Registry) []string {
+	if cfg == nil {
+		return nil
+	}
+
+	var endpoints []string
+	for _, m := range cfg.Mirrors {
+		upstream, err := name.NewRegistry(m.Upstream)
+		if err == nil && upstream.Name() == registry.Name() {
+			endpoints = append(endpoints, m.Endpoint)
+		}
+	}
+	return endpoints
+}
+
+// retarget returns ref with its repository replaced, keeping the tag or digest.
+func retarget(ref name.Reference, repo name.Repository) name.Reference {
+	if digest, ok := ref.(name.Digest); ok {
+		return repo.Digest(digest.DigestStr())
+	}
+	return repo.Tag(ref.Identifier())
+}
+
+// upstreamKey is the context key for the upstream registry a mirror serves
+// requests for.
+type upstreamKey struct{}
+
+// served records in the context's Trace that the registry of repo served a
+// request, on behalf of the upstream registry when repo is on a mirror.
+func served(ctx context.Context, repo name.Repository) {
+	upstream, _ := ctx.Value(upstreamKey{}).(string)
+	traceFromContext(ctx).recordEndpoint(EndpointUse{Registry: repo.RegistryStr(), Upstream: upstream})
+}
+
+// mirrorBackend reads the content of registries with mirrors from the mirrors
+// first, see WithMirrors.
+type mirrorBackend struct {
+	Backend
+	mirrors Backend
+	cfg     *Config
+}
+
+// WithMirrors returns a Backend routing the requests b gets for registries
+// with mirrors in cfg to the mirrors, see Config.Mirrors. Mirrors are read
+// through mirrors, which authenticates to each mirror with its own
+// credentials, so the credentials b sends upstream are never sent to a
+// mirror.
+//
+// Manifests, blobs and tag resolutions are read from the mirrors first,
+// falling back to the next mirror and then to b only when a mirror does not
+// have them. Since a pull-through cache only has the tags and referrers it
+// cached, tags and referrers are listed from b first, falling back to the
+// mirrors only when b fails; tags listed from a mirror are returned with an
+// error wrapping ErrIncomplete.
+func WithMirrors(b, mirrors Backend, cfg *Config) Backend {
+	return &mirrorBackend{Backend: b, mirrors: mirrors, cfg: cfg}
+}
+
+// mirrorRepos returns the repository of repo on each mirror of its
+// registry, in order, with the context to read them with.
+func (m *mirrorBackend) mirrorRepos(
+	ctx context.Context, repo name.Repository,
+) (context.Context, []name.Repository) {
+	var repos []name.Repository
+	for _, endpoint := range m.cfg.mirrorsFor(repo.Registry) {
+		mirrorRepo, err := m.mirrors.NewRepository(endpoint + "/" + repo.RepositoryStr())
+		if err == nil {
+			repos = append(repos, mirrorRepo)
+		}
+	}
+	return context.WithValue(ctx, upstreamKey{}, repo.RegistryStr()), repos
+}
+
+// firstMirror runs op against each mirror of repo until one has the
+// content, then against the upstream repository with upstream. Errors other
+// than not found are reported rather than moved on from.
+func firstMirror[T any](
+	ctx context.Context, m *mirrorBackend, repo name.Repository,
+	op func(context.Context, name.Repository) (T, error), upstream func() (T, error),
+) (T, error) {
+	mirrorCtx, repos := m.mirrorRepos(ctx, repo)
+	for _, mirrorRepo := range repos {
+		result, err := op(mirrorCtx, mirrorRepo)
+		if err == nil {
+			return result, nil
+		}
+		if !IsNotFound(err) {
+			return result, fmt.Errorf("mirror %s: %w", mirrorRepo, err)
+		}
+	}
+	return upstream()
+}
+
+// HeadReference implements Backend.
+func (m *mirrorBackend) HeadReference(
+	ctx context.Context, imageRef string,
+) (name.Reference, *v1.Descriptor, error) {
+	ref, err := m.ParseReference(imageRef)
+	if err != nil {
+		return m.Backend.HeadReference(ctx, imageRef)
+	}
+	desc, err := firstMirror(ctx, m, ref.Context(), func(ctx context.Context, repo name.Repository) (*v1.Descriptor, error) {
+		_, desc, err := m.mirrors.HeadReference(ctx, retarget(ref, repo).String())
+		return desc, err
+	}, func() (*v1.Descriptor, error) {
+		_, desc, err := m.Backend.HeadReference(ctx, imageRef)
+		return desc, err
+	})
+	return ref, desc, err
+}
+
+// GetManifest implements Backend.
+func (m *mirrorBackend) GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	ref, err := m.ParseReference(imageRef)
+	if err != nil {
+		return m.Backend.GetManifest(ctx, imageRef)
+	}
+	result, err := firstMirror(ctx, m, ref.Context(), func(ctx context.Context, repo name.Repository) (manifest, error) {
+		raw, desc, err := m.mirrors.GetManifest(ctx, retarget(ref, repo).String())
+		return manifest{raw: raw, desc: desc}, err
+	}, func() (manifest, error) {
+		raw, desc, err := m.Backend.GetManifest(ctx, imageRef)
+		return manifest{raw: raw, desc: desc}, err
+	})
+	return result.raw, result.desc, err
+}
+
+// GetBlob implements Backend.
+func (m *mirrorBackend) GetBlob(ctx context.Context, repo, digest string, maxBytes int64) ([]byte, error) {
+	r, err := m.NewRepository(repo)
+	if err != nil {
+		return m.Backend.GetBlob(ctx, repo, digest, maxBytes)
+	}
+	return firstMirror(ctx, m, r, func(ctx context.Context, mirrorRepo name.Repository) ([]byte, error) {
+		return m.mirrors.GetBlob(ctx, mirrorRepo.String(), digest, maxBytes)
+	}, func() ([]byte, error) {
+		return m.Backend.GetBlob(ctx, repo, digest, maxBytes)
+	})
+}
+
+// ListReferrers implements Backend.
+func (m *mirrorBackend) ListReferrers(
+	ctx context.Context, imageRef, artifactTypeFilter string,
+) (*v1.IndexManifest, error) {
+	referrers, err := m.Backend.ListReferrers(ctx, imageRef, artifactTypeFilter)
+	if err == nil {
+		return referrers, nil
+	}
+	ref, parseErr := m.ParseReference(imageRef)
+	if parseErr != nil {
+		return nil, err
+	}
+	// The mirrors are asked for the referrers of the digest, since tags may
+	// have moved upstream.
+	_, desc, headErr := m.HeadReference(ctx, imageRef)
+	if headErr != nil {
+		return nil, err
+	}
+	mirrorCtx, repos := m.mirrorRepos(ctx, ref.Context())
+	for _, repo := range repos {
+		if referrers, mirrorErr := m.mirrors.ListReferrers(
+			mirrorCtx, repo.Digest(desc.Digest.String()).String(), artifactTypeFilter,
+		); mirrorErr == nil {
+			return referrers, nil
+		}
+	}
+	return nil, err
+}
+
+// ListTags implements Backend.
+func (m *mirrorBackend) ListTags(ctx context.Context, repoName string) ([]string, error) {
+	tags, err := m.Backend.ListTags(ctx, repoName)
+	if err == nil || errors.Is(err, ErrIncomplete) {
+		return tags, err
+	}
+	repo, parseErr := m.NewRepository(repoName)
+	if parseErr != nil {
+		return nil, err
+	}
+	mirrorCtx, repos := m.mirrorRepos(ctx, repo)
+	for _, mirrorRepo := range repos {
+		mirrorTags, mirrorErr := m.mirrors.ListTags(mirrorCtx, mirrorRepo.String())
+		if mirrorErr == nil || errors.Is(mirrorErr, ErrIncomplete) {
+			return mirrorTags, fmt.Errorf("%w: listed from mirror %s, which may lack tags: %w",
+				ErrIncomplete, mirrorRepo.RegistryStr(), err)
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,155 @@
+package oci
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMirrors(t *testing.T) {
+	mirrors, err := ParseMirrors(" docker.io = mirror.example/hub/ ,ghcr.io=mirror.example/ghcr,")
+	require.NoError(t, err)
+	assert.Equal(t, []Mirror{
+		{Upstream: "docker.io", Endpoint: "mirror.example/hub"},
+		{Upstream: "ghcr.io", Endpoint: "mirror.example/ghcr"},
+	}, mirrors)
+
+	mirrors, err = ParseMirrors("")
+	require.NoError(t, err)
+	assert.Empty(t, mirrors)
+
+	for _, spec := range []string{"docker.io", "=mirror.example", "docker.io="} {
+		_, err := ParseMirrors(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMirrorsFor(t *testing.T) {
+	cfg := &Config{Mirrors: []Mirror{
+		{Upstream: "docker.io", Endpoint: "first.example"},
+		{Upstream: "ghcr.io", Endpoint: "ghcr-mirror.example"},
+		{Upstream: "index.docker.io", Endpoint: "second.example"},
+	}}
+
+	hub, err := name.NewRegistry("docker.io")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first.example", "second.example"}, cfg.mirrorsFor(hub))
+
+	quay, err := name.NewRegistry("quay.io")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.mirrorsFor(quay))
+	assert.Empty(t, (*Config)(nil).mirrorsFor(hub))
+}
+
+// authRegistry serves an in-memory registry asking for basic credentials,
+// recording the usernames requests authenticate with.
+func authRegistry(t *testing.T) (string, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var users []string
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if user, _, ok := r.BasicAuth(); ok && r.Method != http.MethodPut && r.Method != http.MethodPost &&
+			r.Method != http.MethodPatch {
+			mu.Lock()
+			users = append(users, user)
+			mu.Unlock()
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(users)
+	}
+}
+
+func TestWithMirrors(t *testing.T) {
+	upstream := httptest.NewServer(registry.New())
+	defer upstream.Close()
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+	mirrorHost, mirrorUsers := authRegistry(t)
+
+	// "cached" exists on both, "fresh" only upstream
+	for _, ref := range []string{
+		upstreamHost + "/app:cached",
+		upstreamHost + "/app:fresh",
+		mirrorHost + "/proxy/app:cached",
+	} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		parsed, err := name.ParseReference(ref)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(parsed, img, remote.WithAuth(&authn.Basic{Username: "pusher"})))
+	}
+
+	cfg := &Config{
+		Mirrors:        []Mirror{{Upstream: upstreamHost, Endpoint: mirrorHost + "/proxy"}},
+		MirrorKeychain: staticKeychain{&authn.Basic{Username: "mirror", Password: "secret"}},
+	}
+	backend := NewBackend(NewClientWithConfig(cfg).WithAuth(&authn.Basic{Username: "upstream", Password: "secret"}))
+
+	ctx, trace := WithTrace(t.Context())
+	_, _, err := backend.HeadReference(ctx, upstreamHost+"/app:cached")
+	require.NoError(t, err)
+	assert.Equal(t, []EndpointUse{{Registry: mirrorHost, Upstream: upstreamHost}}, trace.Endpoints())
+
+	ctx, trace = WithTrace(t.Context())
+	_, _, err = backend.HeadReference(ctx, upstreamHost+"/app:fresh")
+	require.NoError(t, err)
+	assert.Equal(t, []EndpointUse{{Registry: upstreamHost}}, trace.Endpoints())
+
+	_, _, err = backend.HeadReference(t.Context(), upstreamHost+"/app:missing")
+	require.Error(t, err)
+	assert.True(t, IsNotFound(err))
+
+	// The mirror only has the tags it cached, so tags are listed upstream.
+	tags, err := backend.ListTags(t.Context(), upstreamHost+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cached", "fresh"}, tags)
+
+	// The mirror is only sent its own credentials.
+	users := mirrorUsers()
+	require.NotEmpty(t, users)
+	for _, user := range users {
+		assert.Equal(t, "mirror", user)
+	}
+
+	// When the upstream fails, tags are listed from the mirror, as an
+	// incomplete listing.
+	upstream.Close()
+	ctx, trace = WithTrace(t.Context())
+	tags, err = backend.ListTags(ctx, upstreamHost+"/app")
+	require.ErrorIs(t, err, ErrIncomplete)
+	assert.Equal(t, []string{"cached"}, tags)
+	assert.Equal(t, []EndpointUse{{Registry: mirrorHost, Upstream: upstreamHost}}, trace.Endpoints())
+}
+
+func TestTrace_RecordsIntoParent(t *testing.T) {
+	ctx, parent := WithTrace(t.Context())
+	_, child := WithTrace(ctx)
+
+	use := EndpointUse{Registry: "mirror.example", Upstream: "docker.io"}
+	child.recordEndpoint(use)
+	child.recordEndpoint(use)
+
+	assert.Equal(t, []EndpointUse{use}, child.Endpoints())
+	assert.Equal(t, []EndpointUse{use}, parent.Endpoints())
+}
@@ -0,0 +1,245 @@
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Alias rewrites references starting with Prefix so they start with
+// Replacement instead, e.g. "internal/" to "registry.corp.example/".
+type Alias struct {
+	Prefix      string
+	Replacement string
+}
+
+// Config holds server-wide settings shared by every Client, independent of
+// the credentials used for a particular request.
+type Config struct {
+	// DefaultRegistry is used for references that do not name a registry.
+	// When empty, Docker Hub is used.
+	DefaultRegistry string
+	// Aliases are applied to references before they are parsed. The longest
+	// matching prefix wins.
+	Aliases []Alias
+	// Mirrors are tried before their upstream registry, falling back to the
+	// upstream when the mirror does not have the requested content, for
+	// backends created with NewBackend, see WithMirrors.
+	Mirrors []Mirror
+	// MirrorKeychain resolves the credentials for mirrors. The credentials
+	// of clients are never sent to mirrors. When nil, mirrors are accessed
+	// anonymously.
+	MirrorKeychain authn.Keychain
+	// Retry controls retries of failed registry requests.
+	Retry RetryPolicy
+	// InsecureRegistries are reached over plain HTTP instead of HTTPS.
+	InsecureRegistries []string
+	// Transport is the base transport for registry requests, such as one
+	// returned by NewTLSTransport. When nil, go-containerregistry's default
+	// transport is used.
+	Transport http.RoundTripper
+	// ContentCache caches manifests and blobs fetched by digest for backends
+	// created with NewBackend. When nil, content is not cached.
+	ContentCache *ContentCache
+	// TagCache briefly caches tag resolutions and tag lists for backends
+	// created with NewBackend. When nil, tags are resolved and listed on
+	// every call.
+	TagCache *TagCache
+
+	// aliasesOnce guards aliases, Aliases sorted longest prefix first on
+	// first use.
+	aliasesOnce sync.Once
+	aliases     []Alias
+
+	// sharedOnce guards shared, the transport built from the config on first
+	// use and shared by every Client created with it.
+	sharedOnce sync.Once
+	shared     http.RoundTripper
+	// inflightOps coalesces concurrent identical operations of every Client
+	// created with the config.
+	inflightOps flightGroup
+	// rateLimitProbeRef replaces dockerHubRateLimitProbe when set.
+	rateLimitProbeRef string
+}
+
+// ParseAliases parses a comma-separated list of prefix=replacement pairs.
+func ParseAliases(spec string) ([]Alias, error) {
+	var aliases []Alias
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, replacement, ok := strings.Cut(entry, "=")
+		prefix, replacement = strings.TrimSpace(prefix), strings.TrimSpace(replacement)
+		if !ok || prefix == "" || replacement == "" {
+			return nil, fmt.Errorf("invalid registry alias %q: expected prefix=replacement", entry)
+		}
+		aliases = append(aliases, Alias{Prefix: prefix, Replacement: replacement})
+	}
+	return aliases, nil
+}
+
+// nameOptions returns the name.Options implied by the config.
+func (cfg *Config) nameOptions() []name.Option {
+	if cfg == nil || cfg.DefaultRegistry == "" {
+		return nil
+	}
+	return []name.Option{name.WithDefaultRegistry(cfg.DefaultRegistry)}
+}
+
+// retryPolicy returns the configured retry policy, or the default one.
+func (cfg *Config) retryPolicy() RetryPolicy {
+	if cfg == nil {
+		return RetryPolicy{}.withDefaults()
+	}
+	return cfg.Retry.withDefaults()
+}
+
+// transport returns the configured base transport, or the default one.
+func (cfg *Config) transport() http.RoundTripper {
+	if cfg == nil || cfg.Transport == nil {
+		return remote.DefaultTransport
+	}
+	return cfg.Transport
+}
+
+// defaultRegistry returns the registry used for unqualified names as users write it.
+func (cfg *Config) defaultRegistry() string {
+	if cfg == nil || cfg.DefaultRegistry == "" {
+		return dockerHubRegistry
+	}
+	return cfg.DefaultRegistry
+}
+
+// expand applies the longest matching alias to ref, returning the rewritten
+// reference and the alias prefix that was applied, if any.
+func (cfg *Config) expand(ref string) (string, string) {
+	if cfg == nil || len(cfg.Aliases) == 0 {
+		return ref, ""
+	}
+
+	cfg.aliasesOnce.Do(func() {
+		cfg.aliases = slices.Clone(cfg.Aliases)
+		sort.SliceStable(cfg.aliases, func(i, j int) bool {
+			return len(cfg.aliases[i].Prefix) > len(cfg.aliases[j].Prefix)
+		})
+	})
+	for _, alias := range cfg.aliases {
+		if strings.HasPrefix(ref, alias.Prefix) {
+			return alias.Replacement + strings.TrimPrefix(ref, alias.Prefix), alias.Prefix
+		}
+	}
+	return ref, ""
+}
+
+// NameResolver resolves image, repository and registry names, applying a
+// default registry and aliases, without contacting a registry. Config and
+// Client implement it; a nil *Config resolves names like Docker does.
+type NameResolver interface {
+	ParseReference(imageRef string, opts ...name.Option) (name.Reference, error)
+	NewRepository(repoName string) (name.Repository, error)
+	NewRegistry(registryName string) (name.Registry, error)
+	// AnalyzeReference and SuggestReferenceFixes explain how a reference is
+	// parsed.
+	AnalyzeReference(imageRef string, strict bool) (*ReferenceInfo, error)
+	SuggestReferenceFixes(imageRef string, strict bool) []ReferenceSuggestion
+}
+
+var (
+	_ NameResolver = (*Config)(nil)
+	_ NameResolver = (*Client)(nil)
+)
+
+// ParseReference parses an image reference after applying aliases and the
+// default registry.
+func (cfg *Config) ParseReference(imageRef string, opts ...name.Option) (name.Reference, error) {
+	expanded, _ := cfg.expand(imageRef)
+	ref, err := name.ParseReference(expanded, append(cfg.nameOptions(), opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image reference: %w", err)
+	}
+	return cfg.reference(ref), nil
+}
+
+// NewRepository parses a repository name after applying aliases and the
+// default registry.
+func (cfg *Config) NewRepository(repoName string) (name.Repository, error) {
+	expanded, _ := cfg.expand(repoName)
+	repo, err := name.NewRepository(expanded, cfg.nameOptions()...)
+	if err != nil {
+		return name.Repository{}, fmt.Errorf("parsing repository name: %w", err)
+	}
+	return cfg.repository(repo), nil
+}
+
+// NewRegistry parses a registry name after applying aliases. Aliases are
+// matched against the name with a trailing slash, so an alias for "internal/"
+// also applies to the bare registry name "internal".
+func (cfg *Config) NewRegistry(registryName string) (name.Registry, error) {
+	expanded, _ := cfg.expand(strings.TrimSuffix(registryName, "/") + "/")
+	reg, err := name.NewRegistry(strings.TrimSuffix(expanded, "/"))
+	if err != nil {
+		return name.Registry{}, fmt.Errorf("parsing registry name: %w", err)
+	}
+	return cfg.registry(reg), nil
+}
+
+// AnalyzeReference parses an image reference like AnalyzeReference, applying
+// the config's aliases and default registry and reporting which alias was used.
+func (cfg *Config) AnalyzeReference(imageRef string, strict bool) (*ReferenceInfo, error) {
+	expanded, alias := cfg.expand(imageRef)
+
+	opts := cfg.nameOptions()
+	if strict {
+		opts = append(opts, name.StrictValidation)
+	}
+
+	info, err := AnalyzeReference(expanded, opts...)
+	if err != nil {
+		return nil, err
+	}
+	info.Alias = alias
+	return info, nil
+}
+
+// SuggestReferenceFixes returns candidate corrections for an invalid reference
+// using the config's default registry.
+func (cfg *Config) SuggestReferenceFixes(imageRef string, strict bool) []ReferenceSuggestion {
+	expanded, _ := cfg.expand(imageRef)
+	return suggestReferenceFixes(expanded, fixOptions{strict: strict, defaultRegistry: cfg.defaultRegistry()})
+}
+
+// ParseReference parses an image reference like Config.ParseReference.
+func (c *Client) ParseReference(imageRef string, opts ...name.Option) (name.Reference, error) {
+	return c.config.ParseReference(imageRef, opts...)
+}
+
+// NewRepository parses a repository name like Config.NewRepository.
+func (c *Client) NewRepository(repoName string) (name.Repository, error) {
+	return c.config.NewRepository(repoName)
+}
+
+// NewRegistry parses a registry name like Config.NewRegistry.
+func (c *Client) NewRegistry(registryName string) (name.Registry, error) {
+	return c.config.NewRegistry(registryName)
+}
+
+// AnalyzeReference explains how a reference is parsed like
+// Config.AnalyzeReference.
+func (c *Client) AnalyzeReference(imageRef string, strict bool) (*ReferenceInfo, error) {
+	return c.config.AnalyzeReference(imageRef, strict)
+}
+
+// SuggestReferenceFixes returns candidate corrections like
+// Config.SuggestReferenceFixes.
+func (c *Client) SuggestReferenceFixes(imageRef string, strict bool) []ReferenceSuggestion {
+	return c.config.SuggestReferenceFixes(imageRef, strict)
+}
@@ -0,0 +1,95 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAliases(t *testing.T) {
+	aliases, err := ParseAliases(" internal/=registry.corp.example/ , hub/=docker.io/,")
+	require.NoError(t, err)
+	assert.Equal(t, []Alias{
+		{Prefix: "internal/", Replacement: "registry.corp.example/"},
+		{Prefix: "hub/", Replacement: "docker.io/"},
+	}, aliases)
+
+	aliases, err = ParseAliases("")
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	_, err = ParseAliases("internal/")
+	assert.Error(t, err)
+}
+
+func TestConfigExpand_LongestPrefixWins(t *testing.T) {
+	cfg := &Config{Aliases: []Alias{
+		{Prefix: "internal/", Replacement: "registry.corp.example/"},
+		{Prefix: "internal/legacy/", Replacement: "old.corp.example/"},
+	}}
+
+	expanded, alias := cfg.expand("internal/legacy/app:v1")
+	assert.Equal(t, "old.corp.example/app:v1", expanded)
+	assert.Equal(t, "internal/legacy/", alias)
+
+	expanded, alias = cfg.expand("internal/api:v1")
+	assert.Equal(t, "registry.corp.example/api:v1", expanded)
+	assert.Equal(t, "internal/", alias)
+
+	expanded, alias = cfg.expand("alpine")
+	assert.Equal(t, "alpine", expanded)
+	assert.Empty(t, alias)
+
+	// The aliases are sorted once, leaving the configured order alone.
+	assert.Equal(t, "internal/", cfg.Aliases[0].Prefix)
+}
+
+func TestClientParseReference_DefaultRegistry(t *testing.T) {
+	client := NewClientWithConfig(&Config{DefaultRegistry: "registry.corp.example"})
+
+	ref, err := client.ParseReference("myteam/api")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.corp.example/myteam/api:latest", ref.Name())
+
+	// Bare names are not placed under library/ outside Docker Hub
+	repo, err := client.NewRepository("api")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.corp.example/api", repo.Name())
+
+	// Explicit registries are unaffected
+	ref, err = client.ParseReference("ghcr.io/org/app:v1")
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/org/app:v1", ref.Name())
+}
+
+func TestClientNewRegistry_Alias(t *testing.T) {
+	client := NewClientWithConfig(&Config{Aliases: []Alias{
+		{Prefix: "internal/", Replacement: "registry.corp.example/"},
+	}})
+
+	reg, err := client.NewRegistry("internal")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.corp.example", reg.Name())
+}
+
+func TestClientAnalyzeReference_Alias(t *testing.T) {
+	client := NewClientWithConfig(&Config{Aliases: []Alias{
+		{Prefix: "internal/", Replacement: "registry.corp.example/"},
+	}})
+
+	info, err := client.AnalyzeReference("internal/api:v1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "registry.corp.example", info.Registry)
+	assert.Equal(t, "api", info.Repository)
+	assert.Equal(t, "internal/", info.Alias)
+	assert.False(t, info.DefaultRegistryApplied)
+}
+
+func TestClientSuggestReferenceFixes_DefaultRegistry(t *testing.T) {
+	client := NewClientWithConfig(&Config{DefaultRegistry: "registry.corp.example"})
+
+	suggestions := client.SuggestReferenceFixes("api", true)
+	require.NotEmpty(t, suggestions)
+	assert.Equal(t, "registry.corp.example/api:latest", suggestions[0].Reference)
+}
@@ -0,0 +1,235 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Capability states reported by ProbeRegistry.
+const (
+	CapabilitySupported   = "supported"
+	CapabilityUnsupported = "unsupported"
+	CapabilityUnknown     = "unknown"
+	CapabilityNotChecked  = "not_checked"
+)
+
+// Auth schemes reported by ProbeRegistry besides those named in the registry's
+// WWW-Authenticate challenge.
+const authSchemeNone = "none"
+
+// referrersProbeDigest is the subject used to probe the Referrers API. The
+// API must answer with an empty index for subjects that do not exist, whereas
+// registries without it answer 404.
+const referrersProbeDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+// RegistryProbe describes the capabilities of a registry.
+type RegistryProbe struct {
+	Registry string
+	// APIVersion is the Docker-Distribution-API-Version header, if sent.
+	APIVersion string
+	// AuthScheme is the lowercased scheme from the /v2/ challenge, e.g.
+	// "bearer" or "basic", or "none" when /v2/ is open.
+	AuthScheme string
+	Realm      string
+	Service    string
+	// AnonymousAccess reports whether an anonymous client could list the
+	// tags of the given repository. It is only checked when a repository is
+	// given, and false otherwise.
+	AnonymousAccess bool
+	// Referrers reports whether the OCI 1.1 Referrers API is served natively;
+	// unsupported means clients fall back to the tag schema. It is only
+	// checked when a repository is given.
+	Referrers string
+	// Catalog reports whether the /v2/_catalog endpoint is enabled.
+	Catalog string
+	// Notes explain capabilities reported as unknown.
+	Notes []string
+}
+
+// ProbeRegistry checks a registry's authentication scheme and which optional
+// APIs it supports. Checking the Referrers API needs an existing repository,
+// since registries answer 404 for unknown repositories either way. Mirrors
+// are not used.
+func (c *Client) ProbeRegistry(ctx context.Context, registryName, repository string) (*RegistryProbe, error) {
+	reg, err := c.NewRegistry(registryName)
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &RegistryProbe{Registry: reg.Name(), Referrers: CapabilityNotChecked}
+	if err := c.probeAuth(ctx, reg, repository, probe); err != nil {
+		return nil, err
+	}
+
+	probe.Catalog = CapabilitySupported
+	if _, err := remote.CatalogPage(reg, "", 1, c.optionsWith(remote.WithContext(ctx))...); err != nil {
+		if isCatalogUnsupported(err) {
+			probe.Catalog = CapabilityUnsupported
+		} else {
+			probe.Catalog = CapabilityUnknown
+			probe.Notes = append(probe.Notes, fmt.Sprintf("catalog: %v", err))
+		}
+	}
+
+	if repository != "" {
+		probe.Referrers = c.probeReferrers(ctx, reg, repository, probe)
+	}
+
+	return probe, nil
+}
+
+// probeAuth pings /v2/ without credentials and records the auth challenge,
+// then checks whether the repository, when given, can be read anonymously.
+func (c *Client) probeAuth(ctx context.Context, reg name.Registry, repository string, probe *RegistryProbe) error {
+	httpClient := &http.Client{Transport: c.httpTransport()}
+
+	resp, err := get(ctx, httpClient, fmt.Sprintf("%s://%s/v2/", reg.Scheme(), reg.RegistryStr()))
+	if err != nil {
+		return fmt.Errorf("pinging registry: %w", err)
+	}
+	probe.APIVersion = resp.Header.Get("Docker-Distribution-API-Version")
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		probe.AuthScheme = authSchemeNone
+	case http.StatusUnauthorized:
+		scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+		probe.AuthScheme = scheme
+		probe.Realm = params["realm"]
+		probe.Service = params["service"]
+	default:
+		return fmt.Errorf("pinging registry: unexpected status %d", resp.StatusCode)
+	}
+
+	if repository != "" {
+		probe.AnonymousAccess = c.probeAnonymousPull(ctx, reg.Repo(repository), probe)
+	}
+	return nil
+}
+
+// probeAnonymousPull lists a tag of repo without credentials, going through
+// the registry's token service when it has one, and reports whether the
+// registry answered.
+func (c *Client) probeAnonymousPull(ctx context.Context, repo name.Repository, probe *RegistryProbe) bool {
+	rt, err := transport.NewWithContext(ctx, repo.Registry, authn.Anonymous, c.httpTransport(),
+		[]string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		probe.Notes = append(probe.Notes, fmt.Sprintf("anonymous access: %v", err))
+		return false
+	}
+
+	resp, err := get(ctx, &http.Client{Transport: rt},
+		fmt.Sprintf("%s://%s/v2/%s/tags/list?n=1", repo.Scheme(), repo.RegistryStr(), repo.RepositoryStr()))
+	if err != nil {
+		probe.Notes = append(probe.Notes, fmt.Sprintf("anonymous access: %v", err))
+		return false
+	}
+	return resp.StatusCode == http.StatusOK
+}
+
+// probeReferrers asks for the referrers of a nonexistent subject and checks
+// whether the Referrers API or the tag schema fallback answered.
+func (c *Client) probeReferrers(ctx context.Context, reg name.Registry, repository string, probe *RegistryProbe) string {
+	subject := reg.Repo(repository).Digest(referrersProbeDigest)
+
+	ctx, trace := WithTrace(ctx)
+	_, err := remote.Referrers(subject, c.optionsWith(remote.WithContext(ctx))...)
+	if native, ok := trace.ReferrersAPI(); ok {
+		if native {
+			return CapabilitySupported
+		}
+		return CapabilityUnsupported
+	}
+
+	if err != nil {
+		probe.Notes = append(probe.Notes, fmt.Sprintf("referrers: %v", err))
+	}
+	return CapabilityUnknown
+}
+
+// httpTransport returns the transport for requests made outside
+// go-containerregistry.
+func (c *Client) httpTransport() http.RoundTripper {
+	if c.transport != nil {
+		return c.transport
+	}
+	return remote.DefaultTransport
+}
+
+// get issues a GET request and discards the response body.
+func get(ctx context.Context, client *http.Client, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example/token",service="registry.example"`
+// into its lowercased scheme and parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = strings.TrimPrefix(strings.TrimSpace(value[end+2:]), ",")
+			continue
+		}
+
+		value, rest, _ = strings.Cut(value, ",")
+		params[key] = strings.TrimSpace(value)
+	}
+
+	return strings.ToLower(scheme), params
+}
+
+// isReferrersResponse reports whether a response answered a Referrers API
+// request and, if so, whether the API is served natively.
+func isReferrersResponse(req *http.Request, resp *http.Response) (native, ok bool) {
+	if req.Method != http.MethodGet {
+		return false, false
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "v2" || parts[len(parts)-2] != "referrers" {
+		return false, false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Content-Type") == string(types.OCIImageIndex), true
+	case http.StatusNotFound, http.StatusBadRequest, http.StatusNotAcceptable:
+		return false, true
+	default:
+		return false, false
+	}
+}
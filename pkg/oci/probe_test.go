@@ -0,0 +1,143 @@
+package oci
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(
+		`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:a/b:pull,push"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:a/b:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=Registry`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "Registry"}, params)
+
+	scheme, params = parseChallenge("")
+	assert.Empty(t, scheme)
+	assert.Empty(t, params)
+}
+
+// openRegistry starts an in-memory registry with an image in "app".
+func openRegistry(t *testing.T, opts ...registry.Option) string {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New(opts...))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(host + "/app:latest")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	return host
+}
+
+func TestProbeRegistry_Open(t *testing.T) {
+	host := openRegistry(t, registry.WithReferrersSupport(true))
+
+	probe, err := NewClient().ProbeRegistry(t.Context(), host, "app")
+	require.NoError(t, err)
+	assert.Equal(t, host, probe.Registry)
+	assert.Equal(t, "registry/2.0", probe.APIVersion)
+	assert.Equal(t, authSchemeNone, probe.AuthScheme)
+	assert.True(t, probe.AnonymousAccess)
+	assert.Equal(t, CapabilitySupported, probe.Referrers)
+	assert.Equal(t, CapabilitySupported, probe.Catalog)
+}
+
+func TestProbeRegistry_ReferrersFallback(t *testing.T) {
+	host := openRegistry(t)
+
+	probe, err := NewClient().ProbeRegistry(t.Context(), host, "app")
+	require.NoError(t, err)
+	assert.Equal(t, CapabilityUnsupported, probe.Referrers)
+
+	probe, err = NewClient().ProbeRegistry(t.Context(), host, "")
+	require.NoError(t, err)
+	assert.Equal(t, CapabilityNotChecked, probe.Referrers)
+}
+
+func TestProbeRegistry_BearerAuth(t *testing.T) {
+	// The realm names the server by host name, since go-containerregistry
+	// refuses token services on private IP addresses.
+	var realm string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			// The token service issues anonymous tokens for any scope.
+			assert.Equal(t, "probe-test", r.URL.Query().Get("service"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token":"anonymous"}`))
+		case r.Header.Get("Authorization") != "Bearer anonymous":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`",service="probe-test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/private/tags/list":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"public","tags":["v1"]}`))
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	realm = "http://localhost:" + server.URL[strings.LastIndex(server.URL, ":")+1:] + "/token"
+
+	probe, err := NewClient().ProbeRegistry(t.Context(), host, "")
+	require.NoError(t, err)
+	assert.Equal(t, "bearer", probe.AuthScheme)
+	assert.Equal(t, realm, probe.Realm)
+	assert.Equal(t, "probe-test", probe.Service)
+	assert.False(t, probe.AnonymousAccess, "not checked without a repository")
+
+	probe, err = NewClient().ProbeRegistry(t.Context(), host, "public")
+	require.NoError(t, err)
+	assert.True(t, probe.AnonymousAccess)
+
+	// An anonymous token alone does not mean the repository can be read.
+	probe, err = NewClient().ProbeRegistry(t.Context(), host, "private")
+	require.NoError(t, err)
+	assert.False(t, probe.AnonymousAccess)
+}
+
+func TestProbeRegistry_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	client := NewClientWithConfig(&Config{Retry: RetryPolicy{MaxAttempts: 1}})
+	_, err := client.ProbeRegistry(t.Context(), host, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pinging registry")
+}
+
+func TestProbeRegistry_BasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Registry Realm"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	probe, err := NewClient().ProbeRegistry(t.Context(), strings.TrimPrefix(server.URL, "http://"), "")
+	require.NoError(t, err)
+	assert.Equal(t, "basic", probe.AuthScheme)
+	assert.Equal(t, "Registry Realm", probe.Realm)
+	assert.False(t, probe.AnonymousAccess)
+}
@@ -0,0 +1,104 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// dockerHubRateLimitProbe is the repository Docker documents for checking pull
+// rate limits; HEAD requests against it do not count towards the limit. It
+// names Docker Hub as name.DefaultRegistry, like every other Docker Hub
+// reference, so keychains resolve the same credentials for it.
+const dockerHubRateLimitProbe = name.DefaultRegistry + "/ratelimitpreview/test:latest"
+
+// Docker Hub rate-limit response headers.
+const (
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitSourceHeader    = "Docker-RateLimit-Source"
+)
+
+// RateLimit is a pull rate limit reported by a registry, as Docker Hub does in
+// its RateLimit-* response headers.
+type RateLimit struct {
+	Limit     int `json:"limit"`
+	Remaining int `json:"remaining"`
+	// WindowSeconds is the length of the window the limit applies to.
+	WindowSeconds int `json:"windowSeconds,omitempty"`
+	// Source identifies what the limit is tracked against: the client IP for
+	// anonymous pulls or the account ID for authenticated ones.
+	Source string `json:"source,omitempty"`
+}
+
+// parseRateLimit reads rate-limit headers such as "RateLimit-Limit: 100;w=21600".
+// It reports false when the response carries no rate limit.
+func parseRateLimit(header http.Header) (*RateLimit, bool) {
+	limit, window, ok := parseRateLimitValue(header.Get(rateLimitLimitHeader))
+	if !ok {
+		return nil, false
+	}
+	remaining, _, ok := parseRateLimitValue(header.Get(rateLimitRemainingHeader))
+	if !ok {
+		return nil, false
+	}
+
+	return &RateLimit{
+		Limit:         limit,
+		Remaining:     remaining,
+		WindowSeconds: window,
+		Source:        header.Get(rateLimitSourceHeader),
+	}, true
+}
+
+// parseRateLimitValue parses a "<count>;w=<seconds>" header value.
+func parseRateLimitValue(value string) (count, window int, ok bool) {
+	countStr, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+	count, err := strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	for _, param := range strings.Split(params, ";") {
+		key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key == "w" {
+			window, _ = strconv.Atoi(val)
+		}
+	}
+	return count, window, true
+}
+
+// DockerHubRateLimit checks the Docker Hub pull rate limit for the client's
+// credentials using the HEAD request Docker documents for the purpose, which
+// does not itself count as a pull. It bypasses mirrors and returns nil when
+// Docker Hub reports no limit, as it does for unlimited accounts.
+func (c *Client) DockerHubRateLimit(ctx context.Context) (*RateLimit, error) {
+	ref, err := name.ParseReference(c.config.rateLimitProbe())
+	if err != nil {
+		return nil, fmt.Errorf("parsing rate limit probe reference: %w", err)
+	}
+
+	ctx, trace := WithTrace(ctx)
+	if _, err := remote.Head(ref, c.optionsWith(remote.WithContext(ctx))...); err != nil {
+		// An exhausted limit fails the probe with 429 but still reports it.
+		if limit := trace.RateLimit(); limit != nil {
+			return limit, nil
+		}
+		return nil, fmt.Errorf("probing Docker Hub rate limit: %w", err)
+	}
+
+	return trace.RateLimit(), nil
+}
+
+// rateLimitProbe returns the reference DockerHubRateLimit checks.
+func (cfg *Config) rateLimitProbe() string {
+	if cfg == nil || cfg.rateLimitProbeRef == "" {
+		return dockerHubRateLimitProbe
+	}
+	return cfg.rateLimitProbeRef
+}
@@ -0,0 +1,114 @@
+package oci
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	header := http.Header{}
+	header.Set("RateLimit-Limit", "100;w=21600")
+	header.Set("RateLimit-Remaining", "76;w=21600")
+	header.Set("Docker-RateLimit-Source", "203.0.113.7")
+
+	limit, ok := parseRateLimit(header)
+	require.True(t, ok)
+	assert.Equal(t, &RateLimit{Limit: 100, Remaining: 76, WindowSeconds: 21600, Source: "203.0.113.7"}, limit)
+
+	header.Del("RateLimit-Remaining")
+	_, ok = parseRateLimit(header)
+	assert.False(t, ok)
+
+	_, ok = parseRateLimit(http.Header{})
+	assert.False(t, ok)
+}
+
+// withRateLimitProbe returns a config pointing the Docker Hub rate limit probe
+// at a test server that reports the given remaining count and responds with
+// status.
+func withRateLimitProbe(t *testing.T, remaining string, status int) *Config {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		assert.Equal(t, http.MethodHead, r.Method)
+		if remaining != "" {
+			w.Header().Set("RateLimit-Limit", "100;w=21600")
+			w.Header().Set("RateLimit-Remaining", remaining+";w=21600")
+			w.Header().Set("Docker-RateLimit-Source", "203.0.113.7")
+		}
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+		w.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat("a", 64))
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return &Config{rateLimitProbeRef: strings.TrimPrefix(server.URL, "http://") + "/ratelimitpreview/test:latest"}
+}
+
+func TestDockerHubRateLimit(t *testing.T) {
+	cfg := withRateLimitProbe(t, "76", http.StatusOK)
+
+	limit, err := NewClientWithConfig(cfg).DockerHubRateLimit(t.Context())
+	require.NoError(t, err)
+	require.NotNil(t, limit)
+	assert.Equal(t, 100, limit.Limit)
+	assert.Equal(t, 76, limit.Remaining)
+	assert.Equal(t, "203.0.113.7", limit.Source)
+}
+
+func TestDockerHubRateLimit_Exhausted(t *testing.T) {
+	cfg := withRateLimitProbe(t, "0", http.StatusTooManyRequests)
+	cfg.Retry = RetryPolicy{MaxAttempts: 1}
+
+	client := NewClientWithConfig(cfg)
+	limit, err := client.DockerHubRateLimit(t.Context())
+	require.NoError(t, err)
+	require.NotNil(t, limit)
+	assert.Zero(t, limit.Remaining)
+}
+
+func TestDockerHubRateLimit_Unlimited(t *testing.T) {
+	cfg := withRateLimitProbe(t, "", http.StatusOK)
+
+	limit, err := NewClientWithConfig(cfg).DockerHubRateLimit(t.Context())
+	require.NoError(t, err)
+	assert.Nil(t, limit)
+}
+
+func TestDockerHubRateLimit_ProbeRegistry(t *testing.T) {
+	// The probe names Docker Hub the way other references do, so keychains
+	// resolve the same credentials for it.
+	ref, err := name.ParseReference((*Config)(nil).rateLimitProbe())
+	require.NoError(t, err)
+	assert.Equal(t, name.DefaultRegistry, ref.Context().RegistryStr())
+}
+
+func TestTrace_RecordsRateLimitFromResponses(t *testing.T) {
+	host, _ := flakyRegistry(t, 0, http.StatusOK, "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Limit", "200;w=21600")
+		w.Header().Set("RateLimit-Remaining", "12;w=21600")
+		http.Redirect(w, r, "http://"+host+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	ctx, trace := WithTrace(t.Context())
+	_, err := NewClient().ListTags(ctx, strings.TrimPrefix(server.URL, "http://")+"/app")
+	require.NoError(t, err)
+
+	limit := trace.RateLimit()
+	require.NotNil(t, limit)
+	assert.Equal(t, 200, limit.Limit)
+	assert.Equal(t, 12, limit.Remaining)
+}
@@ -0,0 +1,254 @@
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// dockerHubRegistry is the registry name users write for Docker Hub; the
+// canonical name used by go-containerregistry is name.DefaultRegistry.
+const dockerHubRegistry = "docker.io"
+
+// ReferenceInfo describes a parsed image reference and which defaults were
+// applied to fully qualify it.
+type ReferenceInfo struct {
+	Reference  name.Reference
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+
+	// DefaultRegistryApplied is set when the input named no registry.
+	DefaultRegistryApplied bool
+	// DefaultNamespaceApplied is set when "library/" was added to a Docker Hub name.
+	DefaultNamespaceApplied bool
+	// DefaultTagApplied is set when the input had neither a tag nor a digest.
+	DefaultTagApplied bool
+	// Alias is the configured alias prefix that was expanded, if any.
+	Alias string
+}
+
+// ReferenceSuggestion is a candidate correction for a reference that failed to
+// parse. Reference is empty when the problem cannot be fixed mechanically.
+type ReferenceSuggestion struct {
+	Reference string
+	Reason    string
+}
+
+// splitReference splits a reference into its name, tag and digest parts
+// without validating them, following go-containerregistry's delimiter rules.
+func splitReference(ref string) (base, tag, digest string) {
+	base = ref
+	if i := strings.Index(base, "@"); i >= 0 {
+		base, digest = base[:i], base[i+1:]
+	}
+	if i := strings.LastIndex(base, ":"); i >= 0 && !strings.Contains(base[i+1:], "/") {
+		base, tag = base[:i], base[i+1:]
+	}
+	return base, tag, digest
+}
+
+// splitRegistry splits a name into its registry and repository parts. The
+// first path component is only a registry if it contains a '.' or ':'.
+func splitRegistry(base string) (registry, repo string) {
+	parts := strings.SplitN(base, "/", 2)
+	if len(parts) == 2 && strings.ContainsAny(parts[0], ".:") {
+		return parts[0], parts[1]
+	}
+	return "", base
+}
+
+// AnalyzeReference parses an image reference and reports which defaults were
+// applied. Pass name.StrictValidation to reject references relying on defaults.
+func AnalyzeReference(imageRef string, opts ...name.Option) (*ReferenceInfo, error) {
+	ref, err := name.ParseReference(imageRef, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image reference: %w", err)
+	}
+
+	base, tag, digest := splitReference(imageRef)
+	registry, repo := splitRegistry(base)
+
+	info := &ReferenceInfo{
+		Reference:              ref,
+		Registry:               ref.Context().RegistryStr(),
+		Repository:             ref.Context().RepositoryStr(),
+		Tag:                    tag,
+		Digest:                 digest,
+		DefaultRegistryApplied: registry == "",
+		DefaultNamespaceApplied: ref.Context().RegistryStr() == name.DefaultRegistry &&
+			!strings.Contains(repo, "/"),
+	}
+
+	if t, ok := ref.(name.Tag); ok && tag == "" {
+		info.Tag = t.TagStr()
+		info.DefaultTagApplied = true
+	}
+
+	return info, nil
+}
+
+// fixOptions controls which corrections SuggestReferenceFixes may propose.
+type fixOptions struct {
+	strict          bool
+	defaultRegistry string
+}
+
+// referenceFix attempts one mechanical correction of a reference, returning the
+// corrected reference and a reason when it applies.
+type referenceFix func(ref string, opts fixOptions) (string, string, bool)
+
+// referenceFixes are applied in order, each to the output of the previous one.
+var referenceFixes = []referenceFix{
+	trimReference,
+	stripScheme,
+	lowercaseRepository,
+	sanitizeTag,
+	addDigestAlgorithm,
+	addDefaultRegistry,
+	addDefaultTag,
+}
+
+// hexPattern matches a string of hexadecimal characters.
+var hexPattern = regexp.MustCompile(`^[a-fA-F0-9]+$`)
+
+// SuggestReferenceFixes returns candidate corrections for a reference that
+// fails to parse, such as lowercasing the repository, adding a missing
+// registry or tag under strict validation, or fixing a digest.
+func SuggestReferenceFixes(imageRef string, strict bool) []ReferenceSuggestion {
+	return suggestReferenceFixes(imageRef, fixOptions{strict: strict, defaultRegistry: dockerHubRegistry})
+}
+
+// suggestReferenceFixes implements SuggestReferenceFixes for the given options.
+func suggestReferenceFixes(imageRef string, fixOpts fixOptions) []ReferenceSuggestion {
+	var opts []name.Option
+	if fixOpts.strict {
+		opts = append(opts, name.StrictValidation)
+	}
+
+	candidate := imageRef
+	var reasons []string
+	for _, fix := range referenceFixes {
+		if fixed, reason, ok := fix(candidate, fixOpts); ok {
+			candidate = fixed
+			reasons = append(reasons, reason)
+		}
+	}
+
+	var suggestions []ReferenceSuggestion
+	if candidate != imageRef {
+		if _, err := name.ParseReference(candidate, opts...); err == nil {
+			suggestions = append(suggestions, ReferenceSuggestion{
+				Reference: candidate,
+				Reason:    strings.Join(reasons, "; "),
+			})
+		}
+	}
+
+	if _, _, digest := splitReference(candidate); digest != "" {
+		algorithm, hex, _ := strings.Cut(digest, ":")
+		if algorithm == "sha256" && len(hex) != 64 {
+			suggestions = append(suggestions, ReferenceSuggestion{
+				Reason: fmt.Sprintf("sha256 digests must have 64 hexadecimal characters, got %d", len(hex)),
+			})
+		}
+	}
+
+	return suggestions
+}
+
+// trimReference removes surrounding whitespace.
+func trimReference(ref string, _ fixOptions) (string, string, bool) {
+	trimmed := strings.TrimSpace(ref)
+	return trimmed, "removed surrounding whitespace", trimmed != ref
+}
+
+// stripScheme removes URL schemes and trailing slashes, which are not part of
+// image references.
+func stripScheme(ref string, _ fixOptions) (string, string, bool) {
+	stripped := ref
+	for _, scheme := range []string{"https://", "http://", "docker://", "oci://"} {
+		stripped = strings.TrimPrefix(stripped, scheme)
+	}
+	stripped = strings.TrimRight(stripped, "/")
+	return stripped, "removed URL scheme", stripped != ref
+}
+
+// lowercaseRepository lowercases the registry and repository; tags may contain
+// uppercase letters but repository names may not.
+func lowercaseRepository(ref string, _ fixOptions) (string, string, bool) {
+	base, tag, digest := splitReference(ref)
+	lowered := strings.ToLower(base)
+	if lowered == base {
+		return ref, "", false
+	}
+	return joinReference(lowered, tag, digest), "repository names must be lowercase", true
+}
+
+// sanitizeTag replaces '+' in tags, commonly from semver build metadata, with
+// '_' since '+' is not a valid tag character.
+func sanitizeTag(ref string, _ fixOptions) (string, string, bool) {
+	base, tag, digest := splitReference(ref)
+	if !strings.Contains(tag, "+") {
+		return ref, "", false
+	}
+	return joinReference(base, strings.ReplaceAll(tag, "+", "_"), digest),
+		"tags may not contain '+'; registries conventionally use '_' for build metadata", true
+}
+
+// addDigestAlgorithm prefixes a bare 64-character hex digest with "sha256:".
+func addDigestAlgorithm(ref string, _ fixOptions) (string, string, bool) {
+	base, tag, digest := splitReference(ref)
+	if digest == "" || strings.Contains(digest, ":") || len(digest) != 64 || !hexPattern.MatchString(digest) {
+		return ref, "", false
+	}
+	return joinReference(base, tag, "sha256:"+strings.ToLower(digest)), "added missing sha256: digest algorithm", true
+}
+
+// addDefaultRegistry qualifies a name without a registry with the default
+// registry, which strict validation requires to be explicit.
+func addDefaultRegistry(ref string, opts fixOptions) (string, string, bool) {
+	base, tag, digest := splitReference(ref)
+	if !opts.strict || base == "" {
+		return ref, "", false
+	}
+
+	registry, repo := splitRegistry(base)
+	if registry == "" {
+		registry = opts.defaultRegistry
+	}
+	if registry == dockerHubRegistry && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+
+	qualified := registry + "/" + repo
+	if qualified == base {
+		return ref, "", false
+	}
+	return joinReference(qualified, tag, digest), "strict validation requires an explicit registry and namespace", true
+}
+
+// addDefaultTag appends ":latest" to a reference without a tag or digest,
+// which strict validation requires to be explicit.
+func addDefaultTag(ref string, opts fixOptions) (string, string, bool) {
+	base, tag, digest := splitReference(ref)
+	if !opts.strict || tag != "" || digest != "" || base == "" {
+		return ref, "", false
+	}
+	return joinReference(base, name.DefaultTag, ""), "strict validation requires an explicit tag or digest", true
+}
+
+// joinReference reassembles a reference from its name, tag and digest parts.
+func joinReference(base, tag, digest string) string {
+	ref := base
+	if tag != "" {
+		ref += ":" + tag
+	}
+	if digest != "" {
+		ref += "@" + digest
+	}
+	return ref
+}
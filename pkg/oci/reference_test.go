@@ -0,0 +1,91 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeReference(t *testing.T) {
+	tests := []struct {
+		input      string
+		registry   string
+		repository string
+		tag        string
+		defaults   [3]bool // registry, namespace, tag
+	}{
+		{"alpine", name.DefaultRegistry, "library/alpine", "latest", [3]bool{true, true, true}},
+		{"alpine:3.19", name.DefaultRegistry, "library/alpine", "3.19", [3]bool{true, true, false}},
+		{"myteam/api:v1", name.DefaultRegistry, "myteam/api", "v1", [3]bool{true, false, false}},
+		{"docker.io/alpine", name.DefaultRegistry, "library/alpine", "latest", [3]bool{false, true, true}},
+		{"ghcr.io/org/app:v2", "ghcr.io", "org/app", "v2", [3]bool{false, false, false}},
+		{"localhost:5000/app", "localhost:5000", "app", "latest", [3]bool{false, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			info, err := AnalyzeReference(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.registry, info.Registry)
+			assert.Equal(t, tt.repository, info.Repository)
+			assert.Equal(t, tt.tag, info.Tag)
+			assert.Equal(t, tt.defaults, [3]bool{
+				info.DefaultRegistryApplied, info.DefaultNamespaceApplied, info.DefaultTagApplied,
+			})
+		})
+	}
+}
+
+func TestAnalyzeReference_Digest(t *testing.T) {
+	digest := "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	info, err := AnalyzeReference("ghcr.io/org/app:v1@" + digest)
+	require.NoError(t, err)
+	assert.Equal(t, digest, info.Digest)
+	assert.Equal(t, "v1", info.Tag)
+	assert.False(t, info.DefaultTagApplied)
+}
+
+func TestAnalyzeReference_Strict(t *testing.T) {
+	_, err := AnalyzeReference("alpine", name.StrictValidation)
+	require.Error(t, err)
+
+	_, err = AnalyzeReference("docker.io/library/alpine:3.19", name.StrictValidation)
+	require.NoError(t, err)
+}
+
+func TestSuggestReferenceFixes(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		strict bool
+		want   string
+	}{
+		{"uppercase repository", "ghcr.io/Org/App:V1", false, "ghcr.io/org/app:V1"},
+		{"url scheme", "https://ghcr.io/org/app:v1", false, "ghcr.io/org/app:v1"},
+		{"plus in tag", "ghcr.io/org/app:1.0.0+build.5", false, "ghcr.io/org/app:1.0.0_build.5"},
+		{"missing registry strict", "alpine", true, "docker.io/library/alpine:latest"},
+		{"missing tag strict", "ghcr.io/org/app", true, "ghcr.io/org/app:latest"},
+		{
+			"bare digest", "ghcr.io/org/app@e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", false,
+			"ghcr.io/org/app@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := SuggestReferenceFixes(tt.input, tt.strict)
+			require.NotEmpty(t, suggestions)
+			assert.Equal(t, tt.want, suggestions[0].Reference)
+			assert.NotEmpty(t, suggestions[0].Reason)
+		})
+	}
+}
+
+func TestSuggestReferenceFixes_ShortDigest(t *testing.T) {
+	suggestions := SuggestReferenceFixes("ghcr.io/org/app@sha256:abc123", false)
+	require.Len(t, suggestions, 1)
+	assert.Empty(t, suggestions[0].Reference)
+	assert.Contains(t, suggestions[0].Reason, "64 hexadecimal characters, got 6")
+}
@@ -0,0 +1,180 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Retry defaults used when a Config does not override them.
+const (
+	defaultRetryMaxAttempts = 4
+	defaultRetryBaseDelay   = 500 * time.Millisecond
+	defaultRetryMaxDelay    = 10 * time.Second
+	defaultRetryMaxWait     = 30 * time.Second
+)
+
+// RetryPolicy controls how registry requests are retried after server errors,
+// connection errors and rate limiting.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per request, including the
+	// first. 1 disables retries; 0 uses the default.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubling on each
+	// subsequent one. 0 uses the default.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts. 0 uses the default.
+	MaxDelay time.Duration
+	// MaxWait is the longest Retry-After a request waits for before trying
+	// again. A registry asking for a longer wait fails the request right
+	// away, reporting the Retry-After. 0 uses the default of 30 seconds.
+	MaxWait time.Duration
+}
+
+// withDefaults returns the policy with zero fields replaced by defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	if p.MaxWait <= 0 {
+		p.MaxWait = defaultRetryMaxWait
+	}
+	return p
+}
+
+// backoff returns the jittered delay before retry number n, counting from 0.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.MaxDelay
+	if n < 32 && p.BaseDelay<<n < p.MaxDelay {
+		delay = p.BaseDelay << n
+	}
+	// Equal jitter: half the delay is fixed, half random, so concurrent
+	// callers do not retry in lockstep.
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryTransport retries idempotent requests that failed with a connection
+// error, a 5xx status or 429, honouring Retry-After up to the policy's
+// MaxWait. It never sleeps past the request context's deadline and draws
+// retries from the context's retry budget, if any, so a single tool call
+// cannot multiply its load on a struggling registry.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+// newRetryTransport wraps base with retries according to policy.
+func newRetryTransport(base http.RoundTripper, policy RetryPolicy) http.RoundTripper {
+	return &retryTransport{base: base, policy: policy.withDefaults()}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := t.policy.backoff(attempt - 1)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > t.policy.MaxWait {
+					return resp, err
+				}
+				delay = retryAfter
+			}
+		}
+		if !canWait(ctx, delay) || !retryBudgetFromContext(ctx).take() {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		traceFromContext(ctx).recordRetry()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether a response or transport error is worth retrying.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// canWait reports whether ctx leaves time to wait delay and try again.
+func canWait(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+// retryBudget limits the total number of retries made on behalf of one
+// operation, shared across all of its requests.
+type retryBudget struct {
+	remaining atomic.Int64
+}
+
+// take consumes one retry, reporting false when the budget is exhausted. A nil
+// budget is unlimited.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	return b.remaining.Add(-1) >= 0
+}
+
+// retryBudgetKey is the context key for the active retryBudget.
+type retryBudgetKey struct{}
+
+// WithRetryBudget returns a context limiting the total number of retries made
+// by Client methods called with it to n, in addition to the per-request limit
+// of the client's RetryPolicy.
+func WithRetryBudget(ctx context.Context, n int) context.Context {
+	budget := &retryBudget{}
+	budget.remaining.Store(int64(n))
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+// retryBudgetFromContext returns the retryBudget attached to ctx, or nil.
+func retryBudgetFromContext(ctx context.Context) *retryBudget {
+	budget, _ := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	return budget
+}
@@ -0,0 +1,142 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetry retries quickly so tests do not wait on real backoff.
+var fastRetry = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// flakyRegistry serves /v2/ and fails tag listing with status until it has
+// been called failures times, then succeeds.
+func flakyRegistry(t *testing.T, failures int32, status int, retryAfter string) (string, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if calls.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"app","tags":["v1"]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://"), &calls
+}
+
+func TestRetryTransport_RetriesServerErrors(t *testing.T) {
+	host, calls := flakyRegistry(t, 2, http.StatusBadGateway, "")
+	client := NewClientWithConfig(&Config{Retry: fastRetry})
+
+	ctx, trace := WithTrace(t.Context())
+	tags, err := client.ListTags(ctx, host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, 2, trace.Retries())
+}
+
+func TestRetryTransport_GivesUpAfterMaxAttempts(t *testing.T) {
+	host, calls := flakyRegistry(t, 10, http.StatusServiceUnavailable, "")
+	client := NewClientWithConfig(&Config{Retry: fastRetry})
+
+	_, err := client.ListTags(t.Context(), host+"/app")
+	require.Error(t, err)
+	assert.Equal(t, int32(fastRetry.MaxAttempts), calls.Load())
+	assert.Equal(t, ErrorCategoryUnavailable, ClassifyError(t.Context(), err).Category)
+}
+
+func TestRetryTransport_HonoursRetryAfter(t *testing.T) {
+	host, calls := flakyRegistry(t, 1, http.StatusTooManyRequests, "1")
+	client := NewClientWithConfig(&Config{Retry: fastRetry})
+
+	start := time.Now()
+	_, err := client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestRetryTransport_CapsRetryAfter(t *testing.T) {
+	host, calls := flakyRegistry(t, 10, http.StatusTooManyRequests, "2")
+	policy := fastRetry
+	policy.MaxWait = time.Second
+	client := NewClientWithConfig(&Config{Retry: policy})
+
+	// Without a deadline, a Retry-After beyond MaxWait fails immediately
+	// rather than waiting as long as the registry asks.
+	ctx, _ := WithTrace(t.Context())
+	start := time.Now()
+	_, err := client.ListTags(ctx, host+"/app")
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 2*time.Second, ClassifyError(ctx, err).RetryAfter)
+}
+
+func TestRetryTransport_StopsAtDeadline(t *testing.T) {
+	host, calls := flakyRegistry(t, 10, http.StatusTooManyRequests, "60")
+	client := NewClientWithConfig(&Config{Retry: fastRetry})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	ctx, _ = WithTrace(ctx)
+
+	start := time.Now()
+	_, err := client.ListTags(ctx, host+"/app")
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second, "a Retry-After beyond the deadline fails immediately")
+	assert.Equal(t, int32(1), calls.Load())
+
+	class := ClassifyError(ctx, err)
+	assert.Equal(t, ErrorCategoryRateLimited, class.Category)
+	assert.Equal(t, time.Minute, class.RetryAfter)
+}
+
+func TestRetryTransport_Budget(t *testing.T) {
+	host, calls := flakyRegistry(t, 10, http.StatusInternalServerError, "")
+	client := NewClientWithConfig(&Config{Retry: fastRetry})
+
+	_, err := client.ListTags(WithRetryBudget(t.Context(), 1), host+"/app")
+	require.Error(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryTransport_DoesNotRetryClientErrors(t *testing.T) {
+	host, calls := flakyRegistry(t, 10, http.StatusNotFound, "")
+	client := NewClientWithConfig(&Config{Retry: fastRetry})
+
+	_, err := client.ListTags(t.Context(), host+"/app")
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+	for n, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+		800 * time.Millisecond, time.Second, time.Second} {
+		delay := policy.backoff(n)
+		assert.GreaterOrEqual(t, delay, want/2, "retry %d", n)
+		assert.LessOrEqual(t, delay, want, "retry %d", n)
+	}
+	assert.Equal(t, defaultRetryMaxAttempts, policy.MaxAttempts)
+	assert.LessOrEqual(t, policy.backoff(100), time.Second)
+}
@@ -0,0 +1,202 @@
+package oci
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// maxSuggestions is the maximum number of alternatives suggested for a
+	// reference that was not found.
+	maxSuggestions = 5
+
+	// suggestionCatalogPageSize bounds how much of the catalog is read when
+	// suggesting repositories, so a typo on a large registry fails quickly.
+	suggestionCatalogPageSize = 1000
+)
+
+// SuggestAlternatives returns existing references close to an image reference
+// the registry reported as not found: the nearest tags in the same repository
+// or, when the repository itself does not exist, references into the nearest
+// repositories in the registry's catalog. It returns nil when nothing close
+// exists or the registry cannot be listed.
+func (c *Client) SuggestAlternatives(ctx context.Context, imageRef string) []string {
+	ref, err := c.ParseReference(imageRef)
+	if err != nil {
+		return nil
+	}
+	repo := ref.Context()
+
+	tags, err := c.listTags(ctx, repo)
+	if err != nil {
+		if !IsNotFound(err) {
+			return nil
+		}
+		var suggestions []string
+		for _, candidate := range c.closestRepositories(ctx, repo) {
+			suggestions = append(suggestions, retarget(ref, candidate).String())
+		}
+		return suggestions
+	}
+
+	tag, ok := ref.(name.Tag)
+	if !ok {
+		return nil
+	}
+
+	var suggestions []string
+	for _, candidate := range ClosestTags(tag.TagStr(), tags, maxSuggestions) {
+		suggestions = append(suggestions, repo.Tag(candidate).String())
+	}
+	return suggestions
+}
+
+// SuggestRepositories returns the repositories in the registry's catalog
+// closest to a repository that was not found.
+func (c *Client) SuggestRepositories(ctx context.Context, repoName string) []string {
+	repo, err := c.NewRepository(repoName)
+	if err != nil {
+		return nil
+	}
+
+	var suggestions []string
+	for _, candidate := range c.closestRepositories(ctx, repo) {
+		suggestions = append(suggestions, candidate.String())
+	}
+	return suggestions
+}
+
+// closestRepositories returns the repositories from the first catalog page of
+// repo's registry that are closest to repo.
+func (c *Client) closestRepositories(ctx context.Context, repo name.Repository) []name.Repository {
+	catalog, err := remote.CatalogPage(repo.Registry, "", suggestionCatalogPageSize,
+		c.optionsWith(remote.WithContext(ctx))...)
+	if err != nil {
+		return nil
+	}
+
+	var repos []name.Repository
+	for _, candidate := range ClosestNames(repo.RepositoryStr(), catalog, maxSuggestions) {
+		repos = append(repos, repo.Registry.Repo(candidate))
+	}
+	return repos
+}
+
+// suggestionCandidate is a name being ranked against a target.
+type suggestionCandidate struct {
+	name     string
+	score    int
+	distance int
+}
+
+// ClosestTags returns up to n tags closest to target. Tags are ranked by edit
+// distance, except that semver tags sharing target's major and minor version
+// (or, failing that, its major version) count as near misses regardless of
+// how they are spelled, so "3.19.9" still suggests "3.19.1".
+func ClosestTags(target string, tags []string, n int) []string {
+	return closest(target, tags, n, semverProximity)
+}
+
+// ClosestNames returns up to n names closest to target by edit distance.
+func ClosestNames(target string, names []string, n int) []string {
+	return closest(target, names, n, nil)
+}
+
+// closest ranks candidates by their score, the smaller of the edit distance
+// and the optional proximity, dropping those too far from target to be a
+// plausible typo.
+func closest(target string, candidates []string, n int, proximity func(a, b string) (int, bool)) []string {
+	limit := max(2, len(target)/3)
+
+	var ranked []suggestionCandidate
+	for _, candidate := range candidates {
+		if candidate == target {
+			continue
+		}
+		distance := levenshtein(target, candidate)
+		score := distance
+		if proximity != nil {
+			if p, ok := proximity(target, candidate); ok && p < score {
+				score = p
+			}
+		}
+		if score <= limit {
+			ranked = append(ranked, suggestionCandidate{name: candidate, score: score, distance: distance})
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score < ranked[j].score
+		}
+		if ranked[i].distance != ranked[j].distance {
+			return ranked[i].distance < ranked[j].distance
+		}
+		return ranked[i].name < ranked[j].name
+	})
+
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	names := make([]string, len(ranked))
+	for i, c := range ranked {
+		names[i] = c.name
+	}
+	return names
+}
+
+// semverProximity scores how close two semver tags are: 1 when they share a
+// major and minor version, 2 when they share a major version. It reports false
+// when either tag is not semver or the major versions differ.
+func semverProximity(a, b string) (int, bool) {
+	va, vb := semverTag(a), semverTag(b)
+	if !semver.IsValid(va) || !semver.IsValid(vb) {
+		return 0, false
+	}
+
+	switch {
+	case semver.MajorMinor(va) == semver.MajorMinor(vb):
+		return 1, true
+	case semver.Major(va) == semver.Major(vb):
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+// semverTag adds the "v" prefix golang.org/x/mod/semver requires.
+func semverTag(tag string) string {
+	if strings.HasPrefix(tag, "v") {
+		return tag
+	}
+	return "v" + tag
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,93 @@
+package oci
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"latest", "latest", 0},
+		{"latest", "lastest", 1},
+		{"3.19", "3.91", 2},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, levenshtein(tt.a, tt.b), "%q vs %q", tt.a, tt.b)
+		assert.Equal(t, tt.want, levenshtein(tt.b, tt.a), "%q vs %q", tt.b, tt.a)
+	}
+}
+
+func TestClosestTags(t *testing.T) {
+	tags := []string{"latest", "3.18.4", "3.19.0", "3.19.1", "4.0.0", "edge", "nightly"}
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"typo", "lastest", []string{"latest"}},
+		{"same minor first", "3.19.9", []string{"3.19.0", "3.19.1", "3.18.4"}},
+		{"v prefix", "v3.19.1", []string{"3.19.1", "3.19.0", "3.18.4"}},
+		{"same major", "3.91", []string{"3.19.1", "3.19.0", "3.18.4"}},
+		{"nothing close", "production", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClosestTags(tt.target, tags, maxSuggestions)
+			if tt.want == nil {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Len(t, ClosestTags("3.19.9", tags, 1), 1)
+}
+
+func TestClosestNames(t *testing.T) {
+	repos := []string{"team/api", "team/web", "team/worker", "other/db"}
+	assert.Equal(t, []string{"team/api"}, ClosestNames("team/apl", repos, maxSuggestions))
+	assert.Equal(t, []string{"team/web"}, ClosestNames("teams/web", repos, maxSuggestions))
+	assert.Empty(t, ClosestNames("billing", repos, maxSuggestions))
+}
+
+func TestSuggestAlternatives(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	for _, ref := range []string{"team/api:1.2.0", "team/api:1.2.1", "team/api:latest", "team/web:latest"} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		parsed, err := name.ParseReference(host + "/" + ref)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(parsed, img))
+	}
+
+	client := NewClient()
+
+	assert.Equal(t, []string{host + "/team/api:1.2.0", host + "/team/api:1.2.1"},
+		client.SuggestAlternatives(t.Context(), host+"/team/api:1.2.3"))
+	assert.Equal(t, []string{host + "/team/api:latest"},
+		client.SuggestAlternatives(t.Context(), host+"/team/apl:latest"),
+		"a missing repository suggests the closest repositories from the catalog")
+	assert.Empty(t, client.SuggestAlternatives(t.Context(), host+"/team/api@sha256:"+strings.Repeat("0", 64)),
+		"digests have no near misses")
+
+	assert.Equal(t, []string{host + "/team/web"}, client.SuggestRepositories(t.Context(), host+"/teams/web"))
+}
@@ -0,0 +1,251 @@
+package oci
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// defaultTagCacheTTL is how long tag resolutions and tag lists are reused
+	// when TagCache is created without a TTL.
+	defaultTagCacheTTL = 30 * time.Second
+
+	// maxTagCacheEntries bounds the number of cached resolutions and tag lists.
+	maxTagCacheEntries = 4096
+)
+
+// TagCache briefly caches what tags resolve to and the tags of repositories.
+// Unlike digests, tags move, so entries expire after a short TTL; a context
+// created with WithNoCache bypasses the cache to force fresh results. Entries
+// are partitioned by scope, see WithTagCache.
+type TagCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu          sync.Mutex
+	resolutions map[tagCacheKey]expiring[v1.Descriptor]
+	lists       map[tagCacheKey]expiring[[]string]
+}
+
+// tagCacheKey identifies a cached tag or repository by the scope it was
+// fetched in and its fully qualified name.
+type tagCacheKey struct {
+	scope string
+	name  string
+}
+
+// expiring is a cached value and the time it expires.
+type expiring[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// NewTagCache returns a tag cache whose entries expire after ttl. A
+// non-positive ttl uses 30 seconds.
+func NewTagCache(ttl time.Duration) *TagCache {
+	if ttl <= 0 {
+		ttl = defaultTagCacheTTL
+	}
+	return &TagCache{
+		ttl:         ttl,
+		now:         time.Now,
+		resolutions: map[tagCacheKey]expiring[v1.Descriptor]{},
+		lists:       map[tagCacheKey]expiring[[]string]{},
+	}
+}
+
+// lookup returns the unexpired value cached under key.
+func lookup[V any](tc *TagCache, entries map[tagCacheKey]expiring[V], key tagCacheKey) (V, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	entry, ok := entries[key]
+	if !ok || !tc.now().Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// store caches value under key until the cache's TTL elapses.
+func store[V any](tc *TagCache, entries map[tagCacheKey]expiring[V], key tagCacheKey, value V) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	now := tc.now()
+	if len(entries) >= maxTagCacheEntries {
+		for k, entry := range entries {
+			if !now.Before(entry.expires) {
+				delete(entries, k)
+			}
+		}
+	}
+	if len(entries) < maxTagCacheEntries {
+		entries[key] = expiring[V]{value: value, expires: now.Add(tc.ttl)}
+	}
+}
+
+// Invalidate drops the cached resolutions of tags of repo and its cached tag
+// list, whichever scope fetched them. Without tags, the resolutions of
+// all tags of repo are dropped.
+func (tc *TagCache) Invalidate(repo name.Repository, tags ...string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for key := range tc.resolutions {
+		tag, ok := strings.CutPrefix(key.name, repo.Name()+":")
+		if ok && (len(tags) == 0 || slices.Contains(tags, tag)) {
+			delete(tc.resolutions, key)
+		}
+	}
+	for key := range tc.lists {
+		if key.name == repo.Name() {
+			delete(tc.lists, key)
+		}
+	}
+}
+
+// noCacheKey is the context key marking requests that must not be served
+// from the tag cache.
+type noCacheKey struct{}
+
+// WithNoCache returns a context whose Backend calls fetch tag resolutions and
+// tag lists from the registry instead of the tag cache. The fresh results
+// still replace the cached ones.
+func WithNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// tagCacheBackend serves tag resolutions and tag lists from a TagCache,
+// fetching them through the Backend it wraps on a miss.
+type tagCacheBackend struct {
+	Backend
+	cache *TagCache
+	scope CacheScope
+}
+
+// WithTagCache returns a Backend resolving tags and listing the tags of
+// repositories through cache, with entries partitioned by scope, and
+// fetching everything else from b. Requests to registries without a scope
+// bypass the cache. Manifests fetched by a recently resolved tag are fetched
+// by digest, so b can serve them from a content cache.
+func WithTagCache(b Backend, cache *TagCache, scope CacheScope) Backend {
+	return &tagCacheBackend{Backend: b, cache: cache, scope: scope}
+}
+
+// key returns the cache key of the named tag or repository of registry, and
+// false when the registry has no scope.
+func (t *tagCacheBackend) key(ctx context.Context, registry name.Registry, qualified string) (tagCacheKey, bool) {
+	scope, ok := t.scope(ctx, registry)
+	return tagCacheKey{scope: scope, name: qualified}, ok
+}
+
+// cachedTag returns the cached descriptor ref resolves to when ref is a tag.
+func (t *tagCacheBackend) cachedTag(ctx context.Context, ref name.Reference) (*v1.Descriptor, bool) {
+	tag, ok := ref.(name.Tag)
+	if !ok {
+		return nil, false
+	}
+	key, ok := t.key(ctx, tag.Registry, tag.Name())
+	if !ok {
+		return nil, false
+	}
+	if bypass, _ := ctx.Value(noCacheKey{}).(bool); bypass {
+		traceFromContext(ctx).recordCache(false)
+		return nil, false
+	}
+
+	desc, ok := lookup(t.cache, t.cache.resolutions, key)
+	traceFromContext(ctx).recordCache(ok)
+	return &desc, ok
+}
+
+// storeTag caches the descriptor ref resolved to when ref is a tag.
+func (t *tagCacheBackend) storeTag(ctx context.Context, ref name.Reference, desc v1.Descriptor) {
+	tag, ok := ref.(name.Tag)
+	if !ok {
+		return
+	}
+	if key, ok := t.key(ctx, tag.Registry, tag.Name()); ok {
+		store(t.cache, t.cache.resolutions, key, desc)
+	}
+}
+
+// HeadReference implements Backend.
+func (t *tagCacheBackend) HeadReference(
+	ctx context.Context, imageRef string,
+) (name.Reference, *v1.Descriptor, error) {
+	if ref, err := t.ParseReference(imageRef); err == nil {
+		if desc, ok := t.cachedTag(ctx, ref); ok {
+			return ref, desc, nil
+		}
+	}
+
+	ref, desc, err := t.Backend.HeadReference(ctx, imageRef)
+	if err == nil && ref != nil {
+		t.storeTag(ctx, ref, *desc)
+	}
+	return ref, desc, err
+}
+
+// GetManifest implements Backend.
+func (t *tagCacheBackend) GetManifest(ctx context.Context, imageRef string) ([]byte, v1.Descriptor, error) {
+	ref, err := t.ParseReference(imageRef)
+	if err != nil {
+		return t.Backend.GetManifest(ctx, imageRef)
+	}
+	if desc, ok := t.cachedTag(ctx, ref); ok {
+		return t.Backend.GetManifest(ctx, ref.Context().Digest(desc.Digest.String()).String())
+	}
+
+	raw, desc, err := t.Backend.GetManifest(ctx, imageRef)
+	if err == nil {
+		t.storeTag(ctx, ref, desc)
+	}
+	return raw, desc, err
+}
+
+// ListReferrers implements Backend. Tags are resolved through the cache.
+func (t *tagCacheBackend) ListReferrers(
+	ctx context.Context, imageRef, artifactTypeFilter string,
+) (*v1.IndexManifest, error) {
+	if ref, err := t.ParseReference(imageRef); err == nil {
+		if _, ok := ref.(name.Tag); ok {
+			if _, desc, err := t.HeadReference(ctx, imageRef); err == nil {
+				imageRef = ref.Context().Digest(desc.Digest.String()).String()
+			}
+		}
+	}
+	return t.Backend.ListReferrers(ctx, imageRef, artifactTypeFilter)
+}
+
+// ListTags implements Backend. Only complete tag lists are cached.
+func (t *tagCacheBackend) ListTags(ctx context.Context, repoName string) ([]string, error) {
+	repo, err := t.NewRepository(repoName)
+	if err != nil {
+		return t.Backend.ListTags(ctx, repoName)
+	}
+	key, ok := t.key(ctx, repo.Registry, repo.Name())
+	if !ok {
+		return t.Backend.ListTags(ctx, repoName)
+	}
+
+	if bypass, _ := ctx.Value(noCacheKey{}).(bool); bypass {
+		traceFromContext(ctx).recordCache(false)
+	} else {
+		tags, ok := lookup(t.cache, t.cache.lists, key)
+		traceFromContext(ctx).recordCache(ok)
+		if ok {
+			return slices.Clone(tags), nil
+		}
+	}
+
+	tags, err := t.Backend.ListTags(ctx, repoName)
+	if err == nil {
+		store(t.cache, t.cache.lists, key, slices.Clone(tags))
+	}
+	return tags, err
+}
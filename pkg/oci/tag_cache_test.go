@@ -0,0 +1,239 @@
+package oci
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRegistry serves an in-memory registry, counting the requests it
+// serves for manifests by tag and for tag lists. Pushes are counted too, so
+// tests reset the count after pushing.
+func countingRegistry(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list") ||
+			(strings.Contains(r.URL.Path, "/manifests/") && !strings.Contains(r.URL.Path, "sha256:")) {
+			requests.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), &requests
+}
+
+// pushRandom pushes a random image to ref and returns its digest.
+func pushRandom(t *testing.T, ref string) string {
+	t.Helper()
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(parsed, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return digest.String()
+}
+
+func TestTagCache_Resolutions(t *testing.T) {
+	host, requests := countingRegistry(t)
+	first := pushRandom(t, host+"/app:latest")
+	requests.Store(0)
+
+	cache := NewTagCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	cfg := &Config{TagCache: cache}
+	client := NewBackend(NewClientWithConfig(cfg))
+
+	_, digest, err := ResolveDigest(t.Context(), client, host+"/app:latest")
+	require.NoError(t, err)
+	assert.Equal(t, first, digest.String())
+	assert.Equal(t, int32(1), requests.Load())
+
+	// The tag moves, but the cached resolution is reused until it expires.
+	second := pushRandom(t, host+"/app:latest")
+	requests.Store(0)
+
+	ctx, trace := WithTrace(t.Context())
+	_, desc, err := client.HeadReference(ctx, host+"/app:latest")
+	require.NoError(t, err)
+	assert.Equal(t, first, desc.Digest.String())
+	assert.Equal(t, int32(0), requests.Load())
+	hits, _ := trace.Cache()
+	assert.Equal(t, 1, hits)
+
+	// Other credentials and WithNoCache resolve the tag again.
+	caller := NewBackend(NewClientWithConfig(cfg).WithAuth(&authn.Basic{Username: "caller"}))
+	_, digest, err = ResolveDigest(t.Context(), caller, host+"/app:latest")
+	require.NoError(t, err)
+	assert.Equal(t, second, digest.String())
+
+	_, digest, err = ResolveDigest(WithNoCache(t.Context()), client, host+"/app:latest")
+	require.NoError(t, err)
+	assert.Equal(t, second, digest.String())
+	assert.Equal(t, int32(2), requests.Load())
+
+	// The fresh resolution replaced the cached one.
+	_, digest, err = ResolveDigest(t.Context(), client, host+"/app:latest")
+	require.NoError(t, err)
+	assert.Equal(t, second, digest.String())
+	assert.Equal(t, int32(2), requests.Load())
+
+	third := pushRandom(t, host+"/app:latest")
+	now = now.Add(time.Minute)
+	_, digest, err = ResolveDigest(t.Context(), client, host+"/app:latest")
+	require.NoError(t, err)
+	assert.Equal(t, third, digest.String(), "expired resolutions are refreshed")
+}
+
+func TestTagCache_Scopes(t *testing.T) {
+	host, requests := countingRegistry(t)
+	pushRandom(t, host+"/app:v1")
+	requests.Store(0)
+
+	cfg := &Config{TagCache: NewTagCache(time.Minute)}
+	basic := &authn.Basic{Username: "caller", Password: "secret"}
+	resolve := func(client *Client) {
+		t.Helper()
+		_, _, err := ResolveDigest(t.Context(), NewBackend(client), host+"/app:v1")
+		require.NoError(t, err)
+	}
+
+	// Clients with the same credentials share cached resolutions.
+	resolve(NewClientWithConfig(cfg).WithAuth(basic))
+	resolve(NewClientWithConfig(cfg).WithAuth(&authn.Basic{Username: "caller", Password: "secret"}))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Credentials resolved from a keychain are scoped like the same
+	// credentials given directly.
+	resolve(NewClientWithConfig(cfg).WithKeychain(staticKeychain{basic}))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Other credentials, and anonymous clients, get their own entries.
+	resolve(NewClientWithConfig(cfg).WithAuth(&authn.Basic{Username: "other", Password: "secret"}))
+	resolve(NewClientWithConfig(cfg))
+	assert.Equal(t, int32(3), requests.Load())
+
+	// Clients with remote options may authenticate in ways the client cannot
+	// see, so they are never cached.
+	opaque := NewClientWithConfig(cfg, WithBasicAuth("caller", "secret"))
+	resolve(opaque)
+	resolve(opaque)
+	assert.Equal(t, int32(5), requests.Load())
+}
+
+// staticKeychain resolves every registry to the same credentials.
+type staticKeychain struct {
+	auth authn.Authenticator
+}
+
+// Resolve implements authn.Keychain.
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return k.auth, nil
+}
+
+func TestTagCache_GetImage(t *testing.T) {
+	host, requests := countingRegistry(t)
+	want := pushRandom(t, host+"/app:v1")
+	requests.Store(0)
+
+	client := NewBackend(NewClientWithConfig(&Config{TagCache: NewTagCache(time.Minute)}))
+	for range 2 {
+		img, err := GetImage(t.Context(), client, host+"/app:v1")
+		require.NoError(t, err)
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		assert.Equal(t, want, digest.String())
+	}
+	assert.Equal(t, int32(1), requests.Load(), "second fetch is by digest")
+}
+
+func TestTagCache_ListTags(t *testing.T) {
+	host, requests := countingRegistry(t)
+	pushRandom(t, host+"/app:v1")
+	pushRandom(t, host+"/app:v2")
+	requests.Store(0)
+
+	client := NewBackend(NewClientWithConfig(&Config{TagCache: NewTagCache(time.Minute)}))
+	tags, err := client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2"}, tags)
+	tags[0] = "modified"
+
+	pushRandom(t, host+"/app:v3")
+	requests.Store(0)
+	tags, err = client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2"}, tags, "cached list is not affected by callers")
+	assert.Equal(t, int32(0), requests.Load())
+
+	tags, err = client.ListTags(WithNoCache(t.Context()), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2", "v3"}, tags)
+}
+
+func TestTagCache_Invalidate(t *testing.T) {
+	host, requests := countingRegistry(t)
+	pushRandom(t, host+"/app:v1")
+	pushRandom(t, host+"/app:v2")
+	pushRandom(t, host+"/other:v1")
+	requests.Store(0)
+
+	cache := NewTagCache(time.Minute)
+	cfg := &Config{TagCache: cache}
+	client := NewBackend(NewClientWithConfig(cfg))
+	scoped := NewBackend(NewClientWithConfig(cfg).WithAuth(&authn.Basic{Username: "caller"}))
+	for _, ref := range []string{"app:v1", "app:v2", "other:v1"} {
+		_, _, err := ResolveDigest(t.Context(), client, host+"/"+ref)
+		require.NoError(t, err)
+	}
+	_, _, err := ResolveDigest(t.Context(), scoped, host+"/app:v1")
+	require.NoError(t, err)
+	_, err = client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	requests.Store(0)
+
+	// The pushed tag is dropped for every scope, with the repository's tag list.
+	moved := pushRandom(t, host+"/app:v1")
+	requests.Store(0)
+	repo, err := name.NewRepository(host + "/app")
+	require.NoError(t, err)
+	cache.Invalidate(repo, "v1")
+
+	for _, c := range []Backend{client, scoped} {
+		_, digest, err := ResolveDigest(t.Context(), c, host+"/app:v1")
+		require.NoError(t, err)
+		assert.Equal(t, moved, digest.String())
+	}
+	for _, ref := range []string{"app:v2", "other:v1"} {
+		_, _, err := ResolveDigest(t.Context(), client, host+"/"+ref)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), requests.Load(), "other tags stay cached")
+
+	_, err = client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// Without tags, every tag of the repository is dropped.
+	cache.Invalidate(repo)
+	_, _, err = ResolveDigest(t.Context(), client, host+"/app:v2")
+	require.NoError(t, err)
+	_, _, err = ResolveDigest(t.Context(), client, host+"/other:v1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), requests.Load())
+}
@@ -0,0 +1,204 @@
+package oci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// RegistryTLS configures TLS for connections to one registry host.
+type RegistryTLS struct {
+	// Host is the registry host as it appears in request URLs, including the
+	// port when it is not the default, e.g. "harbor.corp.example:8443".
+	Host string
+	// CAFiles are PEM bundles trusted in addition to the system roots.
+	CAFiles []string
+	// CertFile and KeyFile are a PEM client certificate and key presented for
+	// mutual TLS.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables certificate verification for the host.
+	InsecureSkipVerify bool
+}
+
+// LoadCertsDir reads per-registry TLS settings from a directory laid out like
+// Docker's /etc/docker/certs.d: one subdirectory per registry host holding CA
+// bundles named *.crt and client certificates named *.cert with a matching
+// *.key.
+func LoadCertsDir(dir string) ([]RegistryTLS, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading certs directory: %w", err)
+	}
+
+	var settings []RegistryTLS
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		hostDir := filepath.Join(dir, entry.Name())
+		files, err := os.ReadDir(hostDir)
+		if err != nil {
+			return nil, fmt.Errorf("reading certs directory: %w", err)
+		}
+
+		s := RegistryTLS{Host: entry.Name()}
+		for _, f := range files {
+			path := filepath.Join(hostDir, f.Name())
+			switch filepath.Ext(f.Name()) {
+			case ".crt":
+				s.CAFiles = append(s.CAFiles, path)
+			case ".cert":
+				if s.CertFile != "" {
+					return nil, fmt.Errorf("%s: more than one client certificate", hostDir)
+				}
+				s.CertFile = path
+				s.KeyFile = strings.TrimSuffix(path, ".cert") + ".key"
+			}
+		}
+		if len(s.CAFiles) > 0 || s.CertFile != "" {
+			settings = append(settings, s)
+		}
+	}
+	return settings, nil
+}
+
+// NewTLSTransport returns a transport that applies the TLS settings of each
+// registry host to requests for it and uses go-containerregistry's default
+// transport for all other hosts. Settings for the same host are merged.
+func NewTLSTransport(settings []RegistryTLS) (http.RoundTripper, error) {
+	base, ok := remote.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("default transport is not an *http.Transport")
+	}
+
+	merged := map[string]*RegistryTLS{}
+	for _, s := range settings {
+		m, ok := merged[s.Host]
+		if !ok {
+			m = &RegistryTLS{Host: s.Host}
+			merged[s.Host] = m
+		}
+		m.CAFiles = append(m.CAFiles, s.CAFiles...)
+		if s.CertFile != "" {
+			m.CertFile, m.KeyFile = s.CertFile, s.KeyFile
+		}
+		m.InsecureSkipVerify = m.InsecureSkipVerify || s.InsecureSkipVerify
+	}
+
+	t := &hostTransport{base: base, hosts: map[string]http.RoundTripper{}}
+	for host, s := range merged {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("registry %s: %w", host, err)
+		}
+		hostBase := base.Clone()
+		hostBase.TLSClientConfig = tlsConfig
+		t.hosts[host] = hostBase
+	}
+	return t, nil
+}
+
+// tlsConfig builds the client TLS configuration for the settings.
+func (s *RegistryTLS) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Only set for registries explicitly configured to skip verification.
+		InsecureSkipVerify: s.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if len(s.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range s.CAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("reading CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("reading CA bundle %s: no PEM certificates found", file)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+
+	if s.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// hostTransport routes requests to a per-host transport, falling back to base.
+type hostTransport struct {
+	base  http.RoundTripper
+	hosts map[string]http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// plainHTTP reports whether reg is configured to be reached over plain HTTP.
+func (cfg *Config) plainHTTP(reg name.Registry) bool {
+	if cfg == nil {
+		return false
+	}
+	for _, host := range cfg.InsecureRegistries {
+		if insecure, err := name.NewRegistry(host); err == nil && insecure.RegistryStr() == reg.RegistryStr() {
+			return true
+		}
+	}
+	return false
+}
+
+// registry returns reg marked insecure, so it is reached over plain HTTP, if
+// the config lists it in InsecureRegistries.
+func (cfg *Config) registry(reg name.Registry) name.Registry {
+	if !cfg.plainHTTP(reg) {
+		return reg
+	}
+	insecure, err := name.NewRegistry(reg.RegistryStr(), name.Insecure)
+	if err != nil {
+		return reg
+	}
+	return insecure
+}
+
+// repository returns repo with its registry adjusted by cfg.registry.
+func (cfg *Config) repository(repo name.Repository) name.Repository {
+	repo.Registry = cfg.registry(repo.Registry)
+	return repo
+}
+
+// reference returns ref with its registry adjusted by cfg.registry.
+func (cfg *Config) reference(ref name.Reference) name.Reference {
+	switch r := ref.(type) {
+	case name.Tag:
+		r.Repository = cfg.repository(r.Repository)
+		return r
+	case name.Digest:
+		r.Repository = cfg.repository(r.Repository)
+		return r
+	default:
+		return ref
+	}
+}
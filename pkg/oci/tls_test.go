@@ -0,0 +1,166 @@
+package oci
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagsHandler serves /v2/ and a single-page tag listing.
+var tagsHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"name":"app","tags":["v1"]}`))
+})
+
+// writePEM writes a PEM block to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, file, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, file)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// newClientCert issues a self-signed client certificate, returning it and the
+// DER encoding of its private key.
+func newClientCert(t *testing.T) (*x509.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ocireg-mcp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert, keyDER
+}
+
+func TestNewTLSTransport_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(tagsHandler)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	untrusted := NewClientWithConfig(&Config{Retry: RetryPolicy{MaxAttempts: 1}})
+	_, err := untrusted.ListTags(t.Context(), host+"/app")
+	require.Error(t, err)
+
+	caFile := writePEM(t, t.TempDir(), "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+	transport, err := NewTLSTransport([]RegistryTLS{{Host: host, CAFiles: []string{caFile}}})
+	require.NoError(t, err)
+
+	client := NewClientWithConfig(&Config{Transport: transport})
+	tags, err := client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+}
+
+func TestNewTLSTransport_ClientCertificate(t *testing.T) {
+	clientCert, clientKey := newClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(tagsHandler)
+	server.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	certsDir := t.TempDir()
+	hostDir := filepath.Join(certsDir, host)
+	require.NoError(t, os.Mkdir(hostDir, 0o700))
+	caFile := writePEM(t, hostDir, "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+
+	transport, err := NewTLSTransport([]RegistryTLS{{Host: host, CAFiles: []string{caFile}}})
+	require.NoError(t, err)
+	withoutCert := NewClientWithConfig(&Config{Transport: transport, Retry: RetryPolicy{MaxAttempts: 1}})
+	_, err = withoutCert.ListTags(t.Context(), host+"/app")
+	require.Error(t, err)
+
+	writePEM(t, hostDir, "client.cert", "CERTIFICATE", clientCert.Raw)
+	writePEM(t, hostDir, "client.key", "EC PRIVATE KEY", clientKey)
+
+	settings, err := LoadCertsDir(certsDir)
+	require.NoError(t, err)
+	require.Len(t, settings, 1)
+	assert.Equal(t, host, settings[0].Host)
+	assert.Equal(t, filepath.Join(hostDir, "client.key"), settings[0].KeyFile)
+
+	transport, err = NewTLSTransport(settings)
+	require.NoError(t, err)
+	client := NewClientWithConfig(&Config{Transport: transport})
+	tags, err := client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+}
+
+func TestNewTLSTransport_SkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(tagsHandler)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	transport, err := NewTLSTransport([]RegistryTLS{{Host: host, InsecureSkipVerify: true}})
+	require.NoError(t, err)
+
+	client := NewClientWithConfig(&Config{Transport: transport})
+	tags, err := client.ListTags(t.Context(), host+"/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+}
+
+func TestNewTLSTransport_InvalidCABundle(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+
+	_, err := NewTLSTransport([]RegistryTLS{{Host: "harbor.corp.example", CAFiles: []string{caFile}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "harbor.corp.example")
+}
+
+func TestInsecureRegistries(t *testing.T) {
+	client := NewClientWithConfig(&Config{
+		InsecureRegistries: []string{"dev.corp.example:5000"},
+	})
+
+	ref, err := client.ParseReference("dev.corp.example:5000/app:v1")
+	require.NoError(t, err)
+	assert.Equal(t, "http", ref.Context().Scheme())
+
+	repo, err := client.NewRepository("registry.corp.example/app")
+	require.NoError(t, err)
+	assert.Equal(t, "https", repo.Scheme())
+
+	reg, err := client.NewRegistry("dev.corp.example:5000")
+	require.NoError(t, err)
+	assert.Equal(t, "http", reg.Scheme())
+}
@@ -0,0 +1,219 @@
+package oci
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// EndpointUse records a registry endpoint that served part of a tool call.
+type EndpointUse struct {
+	Registry string `json:"registry"`
+	// Upstream is set when Registry is a mirror serving content on behalf of
+	// the named upstream registry.
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Trace records how the registry requests made on behalf of a single tool call
+// were served, so tools can report it in result metadata. It is safe for
+// concurrent use.
+type Trace struct {
+	parent     *Trace
+	mu         sync.Mutex
+	endpoints  []EndpointUse
+	retryAfter time.Duration
+	retries    int
+	rateLimit  *RateLimit
+	// referrersAPI is nil until a Referrers API response is seen.
+	referrersAPI *bool
+	cacheHits    int
+	cacheMisses  int
+}
+
+// traceKey is the context key for the active Trace.
+type traceKey struct{}
+
+// WithTrace returns a context carrying a new Trace that Client methods called
+// with the context record into. A Trace already attached to ctx keeps
+// receiving everything recorded into the new one, so a tool can trace each
+// item of a batch while still reporting the call as a whole.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	trace := &Trace{parent: traceFromContext(ctx)}
+	return context.WithValue(ctx, traceKey{}, trace), trace
+}
+
+// traceFromContext returns the Trace attached to ctx, or nil. Recording into a
+// nil Trace is a no-op.
+func traceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// recordEndpoint notes that an endpoint served a request, ignoring duplicates.
+func (t *Trace) recordEndpoint(use EndpointUse) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	seen := false
+	for _, existing := range t.endpoints {
+		if existing == use {
+			seen = true
+			break
+		}
+	}
+	if !seen {
+		t.endpoints = append(t.endpoints, use)
+	}
+	t.mu.Unlock()
+
+	t.parent.recordEndpoint(use)
+}
+
+// Endpoints returns the endpoints that served requests, in first-use order.
+func (t *Trace) Endpoints() []EndpointUse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]EndpointUse(nil), t.endpoints...)
+}
+
+// recordRetryAfter notes a Retry-After delay requested by a registry, keeping
+// the longest one seen.
+func (t *Trace) recordRetryAfter(delay time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.retryAfter = max(t.retryAfter, delay)
+	t.mu.Unlock()
+
+	t.parent.recordRetryAfter(delay)
+}
+
+// RetryAfter returns the longest Retry-After delay requested by a registry,
+// or zero if none was. It is safe to call on a nil Trace.
+func (t *Trace) RetryAfter() time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retryAfter
+}
+
+// recordRetry notes that a request was retried.
+func (t *Trace) recordRetry() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.retries++
+	t.mu.Unlock()
+
+	t.parent.recordRetry()
+}
+
+// Retries returns the number of retried requests. It is safe to call on a nil Trace.
+func (t *Trace) Retries() int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retries
+}
+
+// recordRateLimit notes a rate limit reported by a registry, keeping the one
+// with the fewest remaining requests.
+func (t *Trace) recordRateLimit(limit *RateLimit) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if t.rateLimit == nil || limit.Remaining <= t.rateLimit.Remaining {
+		t.rateLimit = limit
+	}
+	t.mu.Unlock()
+
+	t.parent.recordRateLimit(limit)
+}
+
+// RateLimit returns the most constrained rate limit reported by a registry, or
+// nil if none was. It is safe to call on a nil Trace.
+func (t *Trace) RateLimit() *RateLimit {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rateLimit == nil {
+		return nil
+	}
+	limit := *t.rateLimit
+	return &limit
+}
+
+// recordReferrersAPI notes whether a registry served the Referrers API natively.
+func (t *Trace) recordReferrersAPI(native bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.referrersAPI = &native
+	t.mu.Unlock()
+
+	t.parent.recordReferrersAPI(native)
+}
+
+// ReferrersAPI reports whether the Referrers API was served natively, and
+// false for ok if no Referrers API request was made. It is safe to call on a
+// nil Trace.
+func (t *Trace) ReferrersAPI() (native, ok bool) {
+	if t == nil {
+		return false, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.referrersAPI == nil {
+		return false, false
+	}
+	return *t.referrersAPI, true
+}
+
+// recordCache notes a content cache lookup for a cacheable request.
+func (t *Trace) recordCache(hit bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	if hit {
+		t.cacheHits++
+	} else {
+		t.cacheMisses++
+	}
+	t.mu.Unlock()
+
+	t.parent.recordCache(hit)
+}
+
+// Cache returns the number of content cache hits and misses. It is safe to
+// call on a nil Trace.
+func (t *Trace) Cache() (hits, misses int) {
+	if t == nil {
+		return 0, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cacheHits, t.cacheMisses
+}
@@ -0,0 +1,65 @@
+package oci
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// traceTransport records details of registry responses into the Trace attached
+// to each request's context. It sits beneath go-containerregistry's own
+// transports, so it sees every response including token requests.
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+// newTraceTransport wraps base so responses are recorded into request traces.
+func newTraceTransport(base http.RoundTripper) http.RoundTripper {
+	return &traceTransport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	trace := traceFromContext(req.Context())
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			trace.recordRetryAfter(delay)
+		}
+	}
+	if limit, ok := parseRateLimit(resp.Header); ok {
+		trace.recordRateLimit(limit)
+	}
+	if native, ok := isReferrersResponse(req, resp); ok {
+		trace.recordReferrersAPI(native)
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header, given either as a number of
+// seconds or as an HTTP date, into a delay relative to now.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
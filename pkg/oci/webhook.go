@@ -0,0 +1,260 @@
+package oci
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrUnknownWebhook is returned by ParseWebhook for payloads in none of the
+// supported formats.
+var ErrUnknownWebhook = errors.New("unrecognized webhook payload")
+
+// EventAction is what a registry event did to a manifest.
+type EventAction string
+
+// Actions of registry events.
+const (
+	EventPush   EventAction = "push"
+	EventDelete EventAction = "delete"
+)
+
+// RegistryEvent is a manifest push or delete reported by a registry webhook.
+type RegistryEvent struct {
+	Action     EventAction
+	Repository name.Repository
+	// Tag is the pushed or deleted tag, empty for manifests deleted by digest.
+	Tag string
+	// Digest is the manifest's digest, zero if the event does not name it.
+	Digest v1.Hash
+}
+
+// githubEventHeader names the event of GitHub webhooks, which report GHCR
+// pushes as "package" or "registry_package" events.
+const githubEventHeader = "X-GitHub-Event"
+
+// githubSignatureHeader carries the HMAC-SHA256 signature of GitHub webhooks.
+const githubSignatureHeader = "X-Hub-Signature-256"
+
+// VerifyWebhook reports whether a webhook was sent by a registry sharing
+// secret: either its Authorization header is the secret, optionally as a
+// bearer token (Distribution and Harbor send a configured header verbatim), or
+// it is signed with the secret like GitHub webhooks.
+func VerifyWebhook(header http.Header, body []byte, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if signature, ok := strings.CutPrefix(header.Get(githubSignatureHeader), "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		want := hex.EncodeToString(mac.Sum(nil))
+		return subtle.ConstantTimeCompare([]byte(signature), []byte(want)) == 1
+	}
+	auth := header.Get("Authorization")
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(secret)) == 1 ||
+		subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+secret)) == 1
+}
+
+// ParseWebhook returns the manifest pushes and deletes reported by a
+// Distribution notification, a Harbor webhook or a GitHub package event for
+// GHCR. Other events, such as pulls and blob pushes, are skipped.
+func ParseWebhook(header http.Header, body []byte) ([]RegistryEvent, error) {
+	var payload struct {
+		Events          json.RawMessage `json:"events"`
+		Type            string          `json:"type"`
+		EventData       json.RawMessage `json:"event_data"`
+		Package         json.RawMessage `json:"package"`
+		RegistryPackage json.RawMessage `json:"registry_package"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownWebhook, err)
+	}
+
+	switch {
+	case header.Get(githubEventHeader) != "" || payload.Package != nil || payload.RegistryPackage != nil:
+		return parseGitHubEvent(body)
+	case payload.Events != nil:
+		return parseDistributionEvents(payload.Events)
+	case payload.Type != "" && payload.EventData != nil:
+		return parseHarborEvent(payload.Type, payload.EventData)
+	default:
+		return nil, ErrUnknownWebhook
+	}
+}
+
+// parseDistributionEvents parses the events of a Distribution notification
+// envelope.
+func parseDistributionEvents(raw json.RawMessage) ([]RegistryEvent, error) {
+	var events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Digest     string `json:"digest"`
+			Tag        string `json:"tag"`
+			URL        string `json:"url"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(raw, &events); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownWebhook, err)
+	}
+
+	var parsed []RegistryEvent
+	for _, event := range events {
+		action := EventAction(event.Action)
+		// Blobs and manifests pushed by digest are announced without a tag.
+		if action != EventDelete && (action != EventPush || event.Target.Tag == "") {
+			continue
+		}
+		// The target URL names the registry as configured, whereas the request
+		// host is whatever address the pushing client used.
+		host := event.Request.Host
+		if u, err := url.Parse(event.Target.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		ev, err := newRegistryEvent(action, host+"/"+event.Target.Repository, event.Target.Tag, event.Target.Digest)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, ev)
+	}
+	return parsed, nil
+}
+
+// parseHarborEvent parses a Harbor webhook in its default JSON format.
+func parseHarborEvent(eventType string, raw json.RawMessage) ([]RegistryEvent, error) {
+	var action EventAction
+	switch eventType {
+	case "PUSH_ARTIFACT":
+		action = EventPush
+	case "DELETE_ARTIFACT":
+		action = EventDelete
+	default:
+		return nil, nil
+	}
+
+	var data struct {
+		Resources []struct {
+			Digest      string `json:"digest"`
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+		Repository struct {
+			RepoFullName string `json:"repo_full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownWebhook, err)
+	}
+
+	var parsed []RegistryEvent
+	for _, resource := range data.Resources {
+		// The resource URL is the pulled reference, whose host is the registry.
+		host, _, _ := strings.Cut(resource.ResourceURL, "/")
+		ev, err := newRegistryEvent(action, host+"/"+data.Repository.RepoFullName, resource.Tag, resource.Digest)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, ev)
+	}
+	return parsed, nil
+}
+
+// parseGitHubEvent parses a GitHub package or registry_package event for a
+// container published to GHCR.
+func parseGitHubEvent(body []byte) ([]RegistryEvent, error) {
+	type githubPackage struct {
+		Name           string `json:"name"`
+		PackageType    string `json:"package_type"`
+		PackageVersion struct {
+			Version           string `json:"version"`
+			PackageURL        string `json:"package_url"`
+			ContainerMetadata struct {
+				Tag struct {
+					Name   string `json:"name"`
+					Digest string `json:"digest"`
+				} `json:"tag"`
+			} `json:"container_metadata"`
+		} `json:"package_version"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	}
+	var payload struct {
+		Action          string         `json:"action"`
+		Package         *githubPackage `json:"package"`
+		RegistryPackage *githubPackage `json:"registry_package"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownWebhook, err)
+	}
+	pkg := payload.Package
+	if pkg == nil {
+		pkg = payload.RegistryPackage
+	}
+	if pkg == nil || !strings.EqualFold(pkg.PackageType, "container") {
+		return nil, nil
+	}
+
+	var action EventAction
+	switch payload.Action {
+	case "published", "updated":
+		action = EventPush
+	case "deleted":
+		action = EventDelete
+	default:
+		return nil, nil
+	}
+
+	version := pkg.PackageVersion
+	tag := version.ContainerMetadata.Tag.Name
+	if action == EventPush && tag == "" {
+		return nil, nil
+	}
+	digest := version.ContainerMetadata.Tag.Digest
+	if digest == "" {
+		digest = version.Version
+	}
+	// The package URL is the pulled reference, e.g. ghcr.io/org/app:v1.
+	repository := "ghcr.io/" + pkg.Owner.Login + "/" + pkg.Name
+	if ref, err := name.ParseReference(version.PackageURL); err == nil {
+		repository = ref.Context().Name()
+	}
+	ev, err := newRegistryEvent(action, strings.ToLower(repository), tag, digest)
+	if err != nil {
+		return nil, err
+	}
+	return []RegistryEvent{ev}, nil
+}
+
+// newRegistryEvent returns the event for a repository, tag and digest as
+// reported by a webhook.
+func newRegistryEvent(action EventAction, repository, tag, digest string) (RegistryEvent, error) {
+	repo, err := name.NewRepository(repository)
+	if err != nil {
+		return RegistryEvent{}, fmt.Errorf("%w: invalid repository %q: %w", ErrUnknownWebhook, repository, err)
+	}
+	ev := RegistryEvent{Action: action, Repository: repo, Tag: tag}
+	if digest != "" {
+		if ev.Digest, err = v1.NewHash(digest); err != nil {
+			return RegistryEvent{}, fmt.Errorf("%w: invalid digest %q: %w", ErrUnknownWebhook, digest, err)
+		}
+	}
+	if ev.Tag == "" && ev.Digest == (v1.Hash{}) {
+		return RegistryEvent{}, fmt.Errorf("%w: %s event for %s names neither a tag nor a digest",
+			ErrUnknownWebhook, action, repository)
+	}
+	return ev, nil
+}
@@ -0,0 +1,141 @@
+package oci
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var webhookDigest = "sha256:" + strings.Repeat("b", 64)
+
+// distributionNotification is a Distribution notification envelope with a
+// tagged manifest push, a blob push, a pull and a manifest delete.
+var distributionNotification = `{"events":[
+	{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","repository":"team/app",
+		"digest":"` + webhookDigest + `","tag":"stable","url":"https://registry.corp.example/v2/team/app/manifests/stable"},
+		"request":{"host":"registry.corp.example:5000"}},
+	{"action":"push","target":{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","repository":"team/app",
+		"digest":"` + webhookDigest + `"},"request":{"host":"registry.corp.example:5000"}},
+	{"action":"pull","target":{"repository":"team/app","digest":"` + webhookDigest + `","tag":"stable"},
+		"request":{"host":"registry.corp.example:5000"}},
+	{"action":"delete","target":{"repository":"team/app","digest":"` + webhookDigest + `",
+		"url":"https://registry.corp.example/v2/team/app/manifests/` + webhookDigest + `"}}
+]}`
+
+// harborWebhook is a Harbor PUSH_ARTIFACT webhook.
+var harborWebhook = `{"type":"PUSH_ARTIFACT","occur_at":1700000000,"operator":"admin","event_data":{
+	"resources":[{"digest":"` + webhookDigest + `","tag":"v1.2","resource_url":"harbor.corp.example/library/app:v1.2"}],
+	"repository":{"name":"app","namespace":"library","repo_full_name":"library/app","repo_type":"private"}}}`
+
+// githubPackageEvent is a GitHub package event for a container pushed to GHCR.
+var githubPackageEvent = `{"action":"published","package":{"name":"App","package_type":"CONTAINER",
+	"owner":{"login":"Org"},"package_version":{"version":"` + webhookDigest + `",
+	"package_url":"ghcr.io/org/app:latest","container_metadata":{"tag":{"name":"latest","digest":"` + webhookDigest + `"}}}}}`
+
+func TestParseWebhook(t *testing.T) {
+	digest, err := v1.NewHash(webhookDigest)
+	require.NoError(t, err)
+	repo := func(s string) name.Repository {
+		r, err := name.NewRepository(s)
+		require.NoError(t, err)
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   []RegistryEvent
+	}{
+		{
+			name: "distribution",
+			body: distributionNotification,
+			want: []RegistryEvent{
+				{Action: EventPush, Repository: repo("registry.corp.example/team/app"), Tag: "stable", Digest: digest},
+				{Action: EventDelete, Repository: repo("registry.corp.example/team/app"), Digest: digest},
+			},
+		},
+		{
+			name: "harbor",
+			body: harborWebhook,
+			want: []RegistryEvent{
+				{Action: EventPush, Repository: repo("harbor.corp.example/library/app"), Tag: "v1.2", Digest: digest},
+			},
+		},
+		{
+			name:   "ghcr",
+			header: http.Header{"X-Github-Event": {"package"}},
+			body:   githubPackageEvent,
+			want: []RegistryEvent{
+				{Action: EventPush, Repository: repo("ghcr.io/org/app"), Tag: "latest", Digest: digest},
+			},
+		},
+		{
+			name: "harbor events other than pushes and deletes",
+			body: `{"type":"PULL_ARTIFACT","event_data":{"resources":[]}}`,
+		},
+		{
+			name: "ghcr packages other than containers",
+			body: `{"action":"published","package":{"name":"lib","package_type":"npm"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			events, err := ParseWebhook(header, []byte(tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, events)
+		})
+	}
+
+	for _, body := range []string{
+		`not json`,
+		`{"hello":"world"}`,
+		`{"events":[{"action":"push","target":{"repository":"app","tag":"v1"},"request":{"host":"UPPER CASE"}}]}`,
+		`{"events":[{"action":"delete","target":{"repository":"app"},"request":{"host":"registry.corp.example"}}]}`,
+	} {
+		_, err := ParseWebhook(http.Header{}, []byte(body))
+		assert.ErrorIs(t, err, ErrUnknownWebhook, body)
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(harborWebhook)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		secret string
+		want   bool
+	}{
+		{"authorization header", http.Header{"Authorization": {"s3cret"}}, "s3cret", true},
+		{"bearer token", http.Header{"Authorization": {"Bearer s3cret"}}, "s3cret", true},
+		{"github signature", http.Header{"X-Hub-Signature-256": {signature}}, "s3cret", true},
+		{"wrong secret", http.Header{"Authorization": {"Bearer guess"}}, "s3cret", false},
+		{"wrong signature", http.Header{"X-Hub-Signature-256": {signature}}, "other", false},
+		{"signature takes precedence", http.Header{
+			"X-Hub-Signature-256": {"sha256=00"}, "Authorization": {"s3cret"},
+		}, "s3cret", false},
+		{"no credentials", http.Header{}, "s3cret", false},
+		{"no secret", http.Header{"Authorization": {""}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyWebhook(tt.header, body, tt.secret))
+		})
+	}
+}